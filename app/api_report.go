@@ -12,7 +12,11 @@ import (
 // Raw report handler
 func makeRawReportHandler(rep Reporter) CtxHandlerFunc {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-		timestamp := deserializeTimestamp(r.URL.Query().Get("timestamp"))
+		timestamp, err := deserializeTimestamp(r.URL.Query().Get("timestamp"))
+		if err != nil {
+			respondWith(ctx, w, http.StatusBadRequest, err)
+			return
+		}
 		rawReport, err := rep.Report(ctx, timestamp)
 		if err != nil {
 			respondWith(ctx, w, http.StatusInternalServerError, err)
@@ -56,6 +56,14 @@ var (
 			{Value: "hide", Label: "Hide storage", filter: render.IsPodComponent, filterPseudo: false},
 		},
 	}
+	remoteServicesOption = APITopologyOptionGroup{
+		ID:      "services",
+		Default: "hosts",
+		Options: []APITopologyOption{
+			{Value: "hosts", Label: "Remote services by host", filter: nil, filterPseudo: false},
+			{Value: "domains", Label: "Remote services by domain", filter: nil, filterPseudo: false, transformer: render.GroupServicesByDomain},
+		},
+	}
 	snapshotFilter = APITopologyOptionGroup{
 		ID:      "snapshot",
 		Default: "hide",
@@ -188,6 +196,7 @@ func MakeRegistry() *Registry {
 				{Value: "hide", Label: "Hide uncontained", filter: render.IsNotPseudo, filterPseudo: true},
 			},
 		},
+		remoteServicesOption,
 	}
 
 	unconnectedFilter := []APITopologyOptionGroup{
@@ -199,6 +208,7 @@ func MakeRegistry() *Registry {
 				{Value: "hide", Label: "Hide unconnected", filter: render.IsConnected, filterPseudo: false},
 			},
 		},
+		remoteServicesOption,
 	}
 
 	// Topology option labels should tell the current state. The first item must
@@ -246,7 +256,7 @@ func MakeRegistry() *Registry {
 			renderer:    render.PodRenderer,
 			Name:        "Pods",
 			Rank:        3,
-			Options:     []APITopologyOptionGroup{snapshotFilter, storageFilter, unmanagedFilter},
+			Options:     []APITopologyOptionGroup{snapshotFilter, storageFilter, unmanagedFilter, remoteServicesOption},
 			HideIfEmpty: true,
 		},
 		APITopologyDesc{
@@ -294,6 +304,7 @@ func MakeRegistry() *Registry {
 			renderer: render.HostRenderer,
 			Name:     "Hosts",
 			Rank:     4,
+			Options:  []APITopologyOptionGroup{remoteServicesOption},
 		},
 		APITopologyDesc{
 			id:       weaveID,
@@ -343,8 +354,8 @@ type APITopologyOptionGroup struct {
 	NoneLabel string `json:"noneLabel,omitempty"`
 }
 
-// Get the render filters to use for this option group, if any, or nil otherwise.
-func (g APITopologyOptionGroup) filter(value string) render.FilterFunc {
+// selected returns the options picked by value.
+func (g APITopologyOptionGroup) selected(value string) []APITopologyOption {
 	var values []string
 	switch g.SelectType {
 	case "", "one":
@@ -355,32 +366,52 @@ func (g APITopologyOptionGroup) filter(value string) render.FilterFunc {
 		log.Errorf("Invalid select type %s for option group %s, ignoring option", g.SelectType, g.ID)
 		return nil
 	}
-	filters := []render.FilterFunc{}
+	var options []APITopologyOption
 	for _, opt := range g.Options {
 		for _, v := range values {
-			if v != opt.Value {
-				continue
+			if v == opt.Value {
+				options = append(options, opt)
 			}
-			var filter render.FilterFunc
-			if opt.filter == nil {
-				// No filter means match everything (pseudo doesn't matter)
-				filter = func(n report.Node) bool { return true }
-			} else if opt.filterPseudo {
-				// Apply filter to pseudo topologies also
-				filter = opt.filter
-			} else {
-				// Allow all pseudo topology nodes, only apply filter to non-pseudo
-				filter = render.AnyFilterFunc(render.IsPseudoTopology, opt.filter)
-			}
-			filters = append(filters, filter)
 		}
 	}
+	return options
+}
+
+// Get the render filters to use for this option group, if any, or nil otherwise.
+func (g APITopologyOptionGroup) filter(value string) render.FilterFunc {
+	filters := []render.FilterFunc{}
+	for _, opt := range g.selected(value) {
+		var filter render.FilterFunc
+		if opt.filter == nil {
+			// No filter means match everything (pseudo doesn't matter)
+			filter = func(n report.Node) bool { return true }
+		} else if opt.filterPseudo {
+			// Apply filter to pseudo topologies also
+			filter = opt.filter
+		} else {
+			// Allow all pseudo topology nodes, only apply filter to non-pseudo
+			filter = render.AnyFilterFunc(render.IsPseudoTopology, opt.filter)
+		}
+		filters = append(filters, filter)
+	}
 	if len(filters) == 0 {
 		return nil
 	}
 	return render.AnyFilterFunc(filters...)
 }
 
+// Get the transformers to apply to the rendered nodes before filtering them
+// for this option group.
+func (g APITopologyOptionGroup) transformers(value string) []render.Transformer {
+	var transformers []render.Transformer
+	for _, opt := range g.selected(value) {
+		if opt.transformer != nil {
+			transformers = append(transformers, opt.transformer)
+		}
+	}
+	return transformers
+}
+
 // APITopologyOption describes a &param=value to a given topology.
 type APITopologyOption struct {
 	Value string `json:"value"`
@@ -388,6 +419,8 @@ type APITopologyOption struct {
 
 	filter       render.FilterFunc
 	filterPseudo bool
+	// transformer, if set, rewrites the rendered nodes, e.g. to group them
+	transformer render.Transformer
 }
 
 type topologyStats struct {
@@ -398,16 +431,16 @@ type topologyStats struct {
 }
 
 // deserializeTimestamp converts the ISO8601 query param into a proper timestamp.
-func deserializeTimestamp(timestamp string) time.Time {
+func deserializeTimestamp(timestamp string) (time.Time, error) {
 	if timestamp != "" {
 		result, err := time.Parse(time.RFC3339, timestamp)
 		if err != nil {
-			log.Errorf("Error parsing timestamp '%s' - make sure the time format is correct", timestamp)
+			return time.Time{}, fmt.Errorf("Error parsing timestamp '%s' - make sure the time format is correct", timestamp)
 		}
-		return result
+		return result, nil
 	}
 	// Default to current time if no timestamp is provided.
-	return time.Now()
+	return time.Now(), nil
 }
 
 // AddContainerFilters adds to the default Registry (topologyRegistry)'s containerFilters
@@ -473,7 +506,11 @@ func (r *Registry) walk(f func(APITopologyDesc)) {
 // makeTopologyList returns a handler that yields an APITopologyList.
 func (r *Registry) makeTopologyList(rep Reporter) CtxHandlerFunc {
 	return func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
-		timestamp := deserializeTimestamp(req.URL.Query().Get("timestamp"))
+		timestamp, err := deserializeTimestamp(req.URL.Query().Get("timestamp"))
+		if err != nil {
+			respondWith(ctx, w, http.StatusBadRequest, err)
+			return
+		}
 		report, err := rep.Report(ctx, timestamp)
 		if err != nil {
 			respondWith(ctx, w, http.StatusInternalServerError, err)
@@ -541,18 +578,25 @@ func (r *Registry) RendererForTopology(topologyID string, values url.Values, rpt
 		return topology.renderer, render.FilterUnconnectedPseudo, nil
 	}
 
-	var filters []render.FilterFunc
+	var (
+		transformers []render.Transformer
+		filters      []render.FilterFunc
+	)
 	for _, group := range topology.Options {
 		value := group.Default
 		if vs := values[group.ID]; len(vs) > 0 {
 			value = vs[0]
 		}
+		transformers = append(transformers, group.transformers(value)...)
 		if filter := group.filter(value); filter != nil {
 			filters = append(filters, filter)
 		}
 	}
 	if len(filters) > 0 {
-		return topology.renderer, render.Transformers([]render.Transformer{render.ComposeFilterFuncs(filters...), render.FilterUnconnectedPseudo}), nil
+		transformers = append(transformers, render.ComposeFilterFuncs(filters...))
+	}
+	if len(transformers) > 0 {
+		return topology.renderer, render.Transformers(append(transformers, render.FilterUnconnectedPseudo)), nil
 	}
 	return topology.renderer, render.FilterUnconnectedPseudo, nil
 }
@@ -567,14 +611,16 @@ func captureReporter(rep Reporter, f reporterHandler) CtxHandlerFunc {
 
 func (r *Registry) captureRenderer(rep Reporter, f rendererHandler) CtxHandlerFunc {
 	return func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
-		var (
-			topologyID = mux.Vars(req)["topology"]
-			timestamp  = deserializeTimestamp(req.URL.Query().Get("timestamp"))
-		)
+		topologyID := mux.Vars(req)["topology"]
 		if _, ok := r.get(topologyID); !ok {
 			http.NotFound(w, req)
 			return
 		}
+		timestamp, err := deserializeTimestamp(req.URL.Query().Get("timestamp"))
+		if err != nil {
+			respondWith(ctx, w, http.StatusBadRequest, err)
+			return
+		}
 		rpt, err := rep.Report(ctx, timestamp)
 		if err != nil {
 			respondWith(ctx, w, http.StatusInternalServerError, err)
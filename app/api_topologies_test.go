@@ -70,6 +70,22 @@ func TestAPITopology(t *testing.T) {
 	}
 }
 
+func TestAPITopologyBadTimestamp(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	for _, path := range []string{
+		"/api/topology",
+		"/api/topology/containers",
+		"/api/report",
+	} {
+		res, _ := checkGet(t, ts, path+"?timestamp=yesterday")
+		if res.StatusCode != 400 {
+			t.Errorf("%s: expected status 400, got %d", path, res.StatusCode)
+		}
+	}
+}
+
 func TestContainerLabelFilter(t *testing.T) {
 	topologySummaries, err := getTestContainerLabelFilterTopologySummary(t, false)
 	if err != nil {
@@ -159,6 +175,24 @@ func TestRendererForTopologyNoFiltering(t *testing.T) {
 	}
 }
 
+func TestRendererForTopologyGroupingServicesByDomain(t *testing.T) {
+	input := fixture.Report.Copy()
+	input.DNS = report.DNSRecords{fixture.GoogleIP: {Forward: report.MakeStringSet("dns.googleapis.com")}}
+
+	for value, want := range map[string]string{
+		"hosts":   render.ServiceNodeIDPrefix + "dns.googleapis.com",
+		"domains": render.ServiceNodeIDPrefix + "googleapis.com",
+	} {
+		renderer, filter, err := app.MakeRegistry().RendererForTopology("hosts", url.Values{"services": []string{value}}, input)
+		if err != nil {
+			t.Fatalf("Topology Registry Report error: %s", err)
+		}
+		if _, ok := render.Render(context.Background(), input, renderer, filter).Nodes[want]; !ok {
+			t.Errorf("services=%s: expected node %s", value, want)
+		}
+	}
+}
+
 func getTestContainerLabelFilterTopologySummary(t *testing.T, exclude bool) (detailed.NodeSummaries, error) {
 	ts := topologyServer()
 	defer ts.Close()
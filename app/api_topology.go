@@ -2,14 +2,18 @@ package app
 
 import (
 	"context"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
+	"camlistore.org/pkg/lru"
 	"github.com/gorilla/mux"
 	ot "github.com/opentracing/opentracing-go"
 	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/weaveworks/scope/common/xfer"
@@ -20,8 +24,24 @@ import (
 
 const (
 	websocketLoop = 1 * time.Second
+
+	// websocketStreamCacheSize bounds how many recently closed topology
+	// streams can be resumed.
+	websocketStreamCacheSize = 1000
 )
 
+// websocketStreams holds the last topology sent on each recent websocket
+// stream, keyed by stream ID, so a client that reconnects with its stream
+// ID and last sequence number gets an incremental diff instead of a reset.
+var websocketStreams = lru.New(websocketStreamCacheSize)
+
+type websocketStream struct {
+	topologyID string
+	options    string
+	seq        uint64
+	topo       detailed.NodeSummaries
+}
+
 // APITopology is returned by the /api/topology/{name} handler.
 type APITopology struct {
 	Nodes detailed.NodeSummaries `json:"nodes"`
@@ -82,7 +102,27 @@ func handleNode(ctx context.Context, renderer render.Renderer, transformer rende
 	respondWith(ctx, w, http.StatusOK, APINode{Node: detailed.CensorNode(rawNode, censorCfg)})
 }
 
+// streamOptions encodes the request parameters that determine what a
+// stream renders; a stream can only be resumed with the same ones.
+func streamOptions(values url.Values) string {
+	options := url.Values{}
+	for k, v := range values {
+		switch k {
+		case "stream", "seq", "t":
+		default:
+			options[k] = v
+		}
+	}
+	return options.Encode()
+}
+
 // Websocket for the full topology.
+//
+// Every diff carries the ID of the stream it was sent on and its sequence
+// number. A client that reconnects may pass them back as the "stream" and
+// "seq" query parameters: if the server still has the topology it last sent
+// on that stream with the same options, it resumes from there, otherwise the
+// first diff on the new stream is a reset.
 func handleWebsocket(
 	ctx context.Context,
 	rep Reporter,
@@ -101,6 +141,11 @@ func handleWebsocket(
 			return
 		}
 	}
+	startReportingAt, err := deserializeTimestamp(r.Form.Get("timestamp"))
+	if err != nil {
+		respondWith(ctx, w, http.StatusBadRequest, err)
+		return
+	}
 
 	conn, err := xfer.Upgrade(w, r, nil)
 	if err != nil {
@@ -108,6 +153,8 @@ func handleWebsocket(
 		return
 	}
 	defer conn.Close()
+	websocketClients.Inc()
+	defer websocketClients.Dec()
 
 	quit := make(chan struct{})
 	go func(c xfer.Websocket) {
@@ -125,12 +172,15 @@ func handleWebsocket(
 	wc := websocketState{
 		rep:              rep,
 		values:           r.Form,
+		options:          streamOptions(r.Form),
 		conn:             conn,
+		stream:           strconv.FormatInt(rand.Int63(), 10),
 		topologyID:       mux.Vars(r)["topology"],
-		startReportingAt: deserializeTimestamp(r.Form.Get("timestamp")),
+		startReportingAt: startReportingAt,
 		censorCfg:        report.GetCensorConfigFromRequest(r),
 		channelOpenedAt:  time.Now(),
 	}
+	wc.resume(r.Form.Get("stream"), r.Form.Get("seq"))
 
 	wait := make(chan struct{}, 1)
 	rep.WaitOn(ctx, wait)
@@ -155,8 +205,11 @@ func handleWebsocket(
 type websocketState struct {
 	rep              Reporter
 	values           url.Values
+	options          string
 	conn             xfer.Websocket
+	stream           string
 	previousTopo     detailed.NodeSummaries
+	seq              uint64
 	topologyID       string
 	startReportingAt time.Time
 	reportTimestamp  time.Time
@@ -164,10 +217,35 @@ type websocketState struct {
 	channelOpenedAt  time.Time
 }
 
+// resume picks up from the last topology sent on a previous stream, if the
+// client's sequence number matches it. A fresh stream ID is still used, so
+// a stale connection on the old stream can't interleave with this one.
+func (wc *websocketState) resume(stream, seq string) {
+	if stream == "" || seq == "" {
+		return
+	}
+	lastSeq, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return
+	}
+	v, ok := websocketStreams.Get(stream)
+	if !ok {
+		return
+	}
+	last := v.(websocketStream)
+	if last.topologyID != wc.topologyID || last.options != wc.options || last.seq != lastSeq {
+		return
+	}
+	wc.previousTopo = last.topo
+	wc.seq = last.seq
+}
+
 func (wc *websocketState) update(ctx context.Context) error {
 	span := ot.StartSpan("websocket.Render", ot.Tag{Key: "topology", Value: wc.topologyID})
 	defer span.Finish()
 	ctx = ot.ContextWithSpan(ctx, span)
+	timer := prometheus.NewTimer(websocketRenderDuration.WithLabelValues(wc.topologyID))
+	defer timer.ObserveDuration()
 	// We measure how much time has passed since the channel was opened
 	// and add it to the initial report timestamp to get the timestamp
 	// of the snapshot we want to report right now.
@@ -198,11 +276,21 @@ func (wc *websocketState) update(ctx context.Context) error {
 	)
 	diff := detailed.TopoDiff(wc.previousTopo, newTopo)
 	wc.previousTopo = newTopo
+	wc.seq++
+	diff.Stream = wc.stream
+	diff.Seq = wc.seq
 
 	if err := wc.conn.WriteJSON(diff); err != nil {
 		if !xfer.IsExpectedWSCloseError(err) {
 			return errors.Wrap(err, "cannot serialize topology diff")
 		}
+		return nil
 	}
+	websocketStreams.Add(wc.stream, websocketStream{
+		topologyID: wc.topologyID,
+		options:    wc.options,
+		seq:        wc.seq,
+		topo:       newTopo,
+	})
 	return nil
 }
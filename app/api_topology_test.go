@@ -140,6 +140,37 @@ func TestAPITopologyWebsocket(t *testing.T) {
 		t.Fatalf("want %d, have %d", want, have)
 	}
 
+	d := readDiff(t, ws)
+	equals(t, 6, len(d.Add))
+	equals(t, 0, len(d.Update))
+	equals(t, 0, len(d.Remove))
+	equals(t, true, d.Reset)
+	equals(t, uint64(1), d.Seq)
+	if d.Stream == "" {
+		t.Fatal("Expected a stream ID")
+	}
+	ws.Close()
+
+	// Reconnecting with the last sequence number resumes the stream
+	resumed, _, err := dialer.Dial(fmt.Sprintf("%s%s?stream=%s&seq=%d", ts.URL, url, d.Stream, d.Seq), nil)
+	ok(t, err)
+	defer resumed.Close()
+	r := readDiff(t, resumed)
+	equals(t, 0, len(r.Add))
+	equals(t, false, r.Reset)
+	equals(t, uint64(2), r.Seq)
+
+	// A sequence number the server didn't send last gets a reset
+	reset, _, err := dialer.Dial(fmt.Sprintf("%s%s?stream=%s&seq=%d", ts.URL, url, d.Stream, d.Seq+5), nil)
+	ok(t, err)
+	defer reset.Close()
+	r = readDiff(t, reset)
+	equals(t, 6, len(r.Add))
+	equals(t, true, r.Reset)
+	equals(t, uint64(1), r.Seq)
+}
+
+func readDiff(t *testing.T, ws *websocket.Conn) detailed.Diff {
 	_, p, err := ws.ReadMessage()
 	ok(t, err)
 	var d detailed.Diff
@@ -147,9 +178,7 @@ func TestAPITopologyWebsocket(t *testing.T) {
 	if err := decoder.Decode(&d); err != nil {
 		t.Fatalf("JSON parse error: %s", err)
 	}
-	equals(t, 6, len(d.Add))
-	equals(t, 0, len(d.Update))
-	equals(t, 0, len(d.Remove))
+	return d
 }
 
 func newu64(value uint64) *uint64 { return &value }
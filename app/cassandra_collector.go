@@ -0,0 +1,332 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	defaultCassandraTable     = "reports"
+	defaultCassandraRetention = 24 * time.Hour
+
+	// Reports are partitioned by the hour they were received in, to keep
+	// partitions small and let whole partitions expire together.
+	cassandraBucketDuration = time.Hour
+)
+
+// CassandraConfig says where and how the Cassandra collector stores reports.
+type CassandraConfig struct {
+	Hosts       []string
+	Keyspace    string
+	Table       string
+	Username    string
+	Password    string
+	Consistency gocql.Consistency
+	// Retention is the TTL reports are written with.
+	Retention time.Duration
+}
+
+// cqlIdentifier matches the keyspace and table names which can be used in
+// CQL without quoting.
+var cqlIdentifier = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,47}$`)
+
+// CassandraConfigFromURL parses a cassandra:// collector URL of the form
+// cassandra://[user:password@]host1[:port],host2[:port]/keyspace[?table=reports&retention=24h&consistency=quorum]
+// Hosts without a port of their own use the port of the last host, so
+// cassandra://host1,host2:9142/keyspace uses port 9142 for both. (URL
+// parsing insists on a port for the last host if any other has one.)
+func CassandraConfigFromURL(u *url.URL) (CassandraConfig, error) {
+	cfg := CassandraConfig{
+		Keyspace:    strings.TrimPrefix(u.Path, "/"),
+		Table:       defaultCassandraTable,
+		Consistency: gocql.Quorum,
+		Retention:   defaultCassandraRetention,
+	}
+	if u.Hostname() == "" {
+		return cfg, fmt.Errorf("Cassandra collector needs at least one host")
+	}
+	if !cqlIdentifier.MatchString(cfg.Keyspace) {
+		return cfg, fmt.Errorf("Invalid keyspace for Cassandra collector: %q", cfg.Keyspace)
+	}
+	hosts := strings.Split(u.Host, ",")
+	_, defaultPort, _ := net.SplitHostPort(hosts[len(hosts)-1])
+	for _, host := range hosts {
+		if host == "" {
+			return cfg, fmt.Errorf("Invalid host list for Cassandra collector: %q", u.Host)
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil && defaultPort != "" {
+			host = net.JoinHostPort(host, defaultPort)
+		}
+		cfg.Hosts = append(cfg.Hosts, host)
+	}
+	if u.User != nil {
+		cfg.Username = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+
+	query := u.Query()
+	if t := query.Get("table"); t != "" {
+		if !cqlIdentifier.MatchString(t) {
+			return cfg, fmt.Errorf("Invalid table for Cassandra collector: %q", t)
+		}
+		cfg.Table = t
+	}
+	if r := query.Get("retention"); r != "" {
+		retention, err := time.ParseDuration(r)
+		if err != nil || retention < time.Second {
+			return cfg, fmt.Errorf("Invalid retention for Cassandra collector: %q", r)
+		}
+		cfg.Retention = retention
+	}
+	if c := query.Get("consistency"); c != "" {
+		consistency, err := gocql.ParseConsistencyWrapper(c)
+		if err != nil {
+			return cfg, fmt.Errorf("Invalid consistency for Cassandra collector: %v", err)
+		}
+		cfg.Consistency = consistency
+	}
+	return cfg, nil
+}
+
+// cassandraSession is the part of a gocql session the Cassandra collector
+// uses, so that tests can stand in for Cassandra.
+type cassandraSession interface {
+	Exec(ctx context.Context, stmt string, values ...interface{}) error
+	// Scan reads the first row of the result into dest, returning
+	// gocql.ErrNotFound if there are no rows.
+	Scan(ctx context.Context, stmt string, values []interface{}, dest ...interface{}) error
+	// Blobs reads the single blob column of every row of the result.
+	Blobs(ctx context.Context, stmt string, values ...interface{}) ([][]byte, error)
+	Close()
+}
+
+type gocqlSession struct {
+	*gocql.Session
+}
+
+func (s gocqlSession) Exec(ctx context.Context, stmt string, values ...interface{}) error {
+	return s.Query(stmt, values...).WithContext(ctx).Exec()
+}
+
+func (s gocqlSession) Scan(ctx context.Context, stmt string, values []interface{}, dest ...interface{}) error {
+	return s.Query(stmt, values...).WithContext(ctx).Scan(dest...)
+}
+
+func (s gocqlSession) Blobs(ctx context.Context, stmt string, values ...interface{}) ([][]byte, error) {
+	var result [][]byte
+	iter := s.Query(stmt, values...).WithContext(ctx).Iter()
+	var buf []byte
+	for iter.Scan(&buf) {
+		result = append(result, buf)
+		buf = nil
+	}
+	return result, iter.Close()
+}
+
+// cassandraCollector is a collector which also writes every report it
+// receives to a Cassandra (or Scylla) table, where it expires after the
+// retention. As with the disk collector, reports older than the window are
+// read back from the table.
+type cassandraCollector struct {
+	*collector
+	cfg     CassandraConfig
+	session cassandraSession
+	merger  Merger
+
+	mtx    sync.Mutex
+	oldest time.Time // of the reports in the table, zero if there are none
+}
+
+// NewCassandraCollector returns a collector which persists reports in
+// Cassandra, creating the table if createTables is set. The keyspace must
+// already exist.
+func NewCassandraCollector(cfg CassandraConfig, window time.Duration, createTables bool) (Collector, error) {
+	cluster := gocql.NewCluster(cfg.Hosts...)
+	cluster.Keyspace = cfg.Keyspace
+	cluster.Consistency = cfg.Consistency
+	if cfg.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: cfg.Username, Password: cfg.Password}
+	}
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to Cassandra: %v", err)
+	}
+	c, err := newCassandraCollector(cfg, window, gocqlSession{session}, createTables)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newCassandraCollector(cfg CassandraConfig, window time.Duration, session cassandraSession, createTables bool) (*cassandraCollector, error) {
+	c := &cassandraCollector{
+		collector: NewCollector(window).(*collector),
+		cfg:       cfg,
+		session:   session,
+		merger:    NewFastMerger(),
+	}
+	if createTables {
+		if err := c.createTable(); err != nil {
+			session.Close()
+			return nil, err
+		}
+	}
+	if err := c.findOldest(); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *cassandraCollector) createTable() error {
+	// Time window compaction suits rows which are never updated and all
+	// expire after the same TTL.
+	return c.session.Exec(context.Background(), fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		bucket bigint,
+		ts timestamp,
+		id timeuuid,
+		report blob,
+		PRIMARY KEY (bucket, ts, id)
+	) WITH compaction = {
+		'class': 'TimeWindowCompactionStrategy',
+		'compaction_window_unit': 'HOURS',
+		'compaction_window_size': 1
+	}`, c.cfg.Table))
+}
+
+// findOldest finds the oldest report which hasn't expired yet, looking
+// through the buckets from the start of the retention onwards.
+func (c *cassandraCollector) findOldest() error {
+	now := mtime.Now()
+	for bucket := cassandraBucket(now.Add(-c.cfg.Retention)); bucket <= cassandraBucket(now); bucket++ {
+		var ts time.Time
+		err := c.session.Scan(context.Background(), fmt.Sprintf(`SELECT ts FROM %s WHERE bucket = ? LIMIT 1`, c.cfg.Table), []interface{}{bucket}, &ts)
+		if err == gocql.ErrNotFound {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("error reading reports from Cassandra: %v", err)
+		}
+		c.oldest = ts
+		break
+	}
+	return nil
+}
+
+func cassandraBucket(t time.Time) int64 {
+	return t.UnixNano() / int64(cassandraBucketDuration)
+}
+
+// Close closes the Cassandra session.
+func (c *cassandraCollector) Close() {
+	c.collector.Close()
+	c.session.Close()
+}
+
+// Add writes a report to Cassandra, and then adds it to the collector, so
+// that a probe retrying a report which failed to be written doesn't add it
+// twice. It implements Adder.
+func (c *cassandraCollector) Add(ctx context.Context, rpt report.Report, buf []byte) error {
+	if buf == nil {
+		b, err := rpt.WriteBinary()
+		if err != nil {
+			return err
+		}
+		buf = b.Bytes()
+	}
+
+	t := mtime.Now()
+	err := c.session.Exec(ctx, fmt.Sprintf(`INSERT INTO %s (bucket, ts, id, report) VALUES (?, ?, ?, ?) USING TTL ?`, c.cfg.Table),
+		cassandraBucket(t), t, gocql.UUIDFromTime(t), buf, int(c.cfg.Retention/time.Second))
+	if err != nil {
+		return fmt.Errorf("error writing report to Cassandra: %v", err)
+	}
+
+	c.mtx.Lock()
+	if c.oldest.IsZero() {
+		c.oldest = t
+	}
+	c.mtx.Unlock()
+	return c.collector.Add(ctx, rpt, buf)
+}
+
+// Report returns a merged report over the window up to timestamp. Reports
+// older than the window kept in memory are read from Cassandra. It
+// implements Reporter.
+func (c *cassandraCollector) Report(ctx context.Context, timestamp time.Time) (report.Report, error) {
+	if !timestamp.Before(mtime.Now().Add(-c.window)) {
+		return c.collector.Report(ctx, timestamp)
+	}
+
+	bufs, err := c.reportsBetween(ctx, timestamp.Add(-c.window), timestamp)
+	if err != nil {
+		return report.MakeReport(), err
+	}
+	reports := make([]report.Report, 0, len(bufs))
+	for _, buf := range bufs {
+		rpt, err := report.MakeFromBinary(ctx, bytes.NewReader(buf), true, true)
+		if err != nil {
+			return report.MakeReport(), fmt.Errorf("error decoding report from Cassandra: %v", err)
+		}
+		reports = append(reports, rpt.Upgrade())
+	}
+	return c.merger.Merge(reports), nil
+}
+
+// reportsBetween reads the serialised reports received in (from, to].
+func (c *cassandraCollector) reportsBetween(ctx context.Context, from, to time.Time) ([][]byte, error) {
+	var result [][]byte
+	for bucket := cassandraBucket(from); bucket <= cassandraBucket(to); bucket++ {
+		bufs, err := c.session.Blobs(ctx, fmt.Sprintf(`SELECT report FROM %s WHERE bucket = ? AND ts > ? AND ts <= ?`, c.cfg.Table),
+			bucket, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("error reading reports from Cassandra: %v", err)
+		}
+		result = append(result, bufs...)
+	}
+	return result, nil
+}
+
+// HasReports indicates whether the collector contains reports between
+// timestamp-app.window and timestamp.
+func (c *cassandraCollector) HasReports(ctx context.Context, timestamp time.Time) (bool, error) {
+	if !timestamp.Before(mtime.Now().Add(-c.window)) {
+		return c.collector.HasReports(ctx, timestamp)
+	}
+	from := timestamp.Add(-c.window)
+	for bucket := cassandraBucket(from); bucket <= cassandraBucket(timestamp); bucket++ {
+		var ts time.Time
+		err := c.session.Scan(ctx, fmt.Sprintf(`SELECT ts FROM %s WHERE bucket = ? AND ts > ? AND ts <= ? LIMIT 1`, c.cfg.Table),
+			[]interface{}{bucket, from, timestamp}, &ts)
+		if err == nil {
+			return true, nil
+		} else if err != gocql.ErrNotFound {
+			return false, fmt.Errorf("error reading reports from Cassandra: %v", err)
+		}
+	}
+	return false, nil
+}
+
+// HasHistoricReports indicates whether the collector contains reports
+// older than now-app.window.
+func (c *cassandraCollector) HasHistoricReports() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	now := mtime.Now()
+	if expired := now.Add(-c.cfg.Retention); c.oldest.Before(expired) && !c.oldest.IsZero() {
+		// Rows expire through their TTL, without us noticing
+		c.oldest = expired
+	}
+	return !c.oldest.IsZero() && c.oldest.Before(now.Add(-c.window))
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+type cassandraRow struct {
+	bucket int64
+	ts     time.Time
+	report []byte
+}
+
+// fakeCassandraSession answers the statements the Cassandra collector makes
+// from rows in memory.
+type fakeCassandraSession struct {
+	mtx  sync.Mutex
+	rows []cassandraRow
+	err  error // returned by every Exec, if set
+}
+
+func (s *fakeCassandraSession) Exec(_ context.Context, stmt string, values ...interface{}) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if strings.HasPrefix(stmt, "INSERT") {
+		s.rows = append(s.rows, cassandraRow{bucket: values[0].(int64), ts: values[1].(time.Time), report: values[3].([]byte)})
+	}
+	return nil
+}
+
+// between returns the rows in the bucket given by values[0], received in
+// (values[1], values[2]] if they are given.
+func (s *fakeCassandraSession) between(values []interface{}) []cassandraRow {
+	var result []cassandraRow
+	for _, row := range s.rows {
+		if row.bucket != values[0].(int64) {
+			continue
+		}
+		if len(values) == 3 && (!row.ts.After(values[1].(time.Time)) || row.ts.After(values[2].(time.Time))) {
+			continue
+		}
+		result = append(result, row)
+	}
+	return result
+}
+
+func (s *fakeCassandraSession) Scan(_ context.Context, _ string, values []interface{}, dest ...interface{}) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	rows := s.between(values)
+	if len(rows) == 0 {
+		return gocql.ErrNotFound
+	}
+	*dest[0].(*time.Time) = rows[0].ts
+	return nil
+}
+
+func (s *fakeCassandraSession) Blobs(_ context.Context, _ string, values ...interface{}) ([][]byte, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	var result [][]byte
+	for _, row := range s.between(values) {
+		result = append(result, row.report)
+	}
+	return result, nil
+}
+
+func (s *fakeCassandraSession) Close() {}
+
+func TestCassandraCollector(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	ctx := context.Background()
+	window := 10 * time.Second
+	session := &fakeCassandraSession{}
+	cfg := CassandraConfig{Table: defaultCassandraTable, Retention: time.Hour}
+	c, err := newCassandraCollector(cfg, window, session, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A report which can't be written isn't kept in memory either, so a
+	// retry doesn't add it twice
+	session.err = errors.New("unavailable")
+	if err := c.Add(ctx, report.MakeReport(), nil); err == nil {
+		t.Error("Expected an error writing to Cassandra")
+	}
+	if ok, _ := c.HasReports(ctx, now); ok {
+		t.Error("Expected no reports after a failed write")
+	}
+
+	session.err = nil
+	if err := c.Add(ctx, report.MakeReport(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.HasReports(ctx, now); !ok {
+		t.Error("Expected reports in memory")
+	}
+	if len(session.rows) != 1 {
+		t.Errorf("Expected 1 row, have %d", len(session.rows))
+	}
+
+	// Once the report has dropped out of the window it is read back from
+	// the table
+	mtime.NowForce(now.Add(time.Minute))
+	if !c.HasHistoricReports() {
+		t.Error("Expected historic reports")
+	}
+	if ok, err := c.HasReports(ctx, now); err != nil || !ok {
+		t.Errorf("Expected reports in Cassandra, have %v (%v)", ok, err)
+	}
+	if _, err := c.Report(ctx, now); err != nil {
+		t.Error(err)
+	}
+	if ok, _ := c.HasReports(ctx, now.Add(-time.Minute)); ok {
+		t.Error("Expected no reports before the first was added")
+	}
+
+	// A new collector finds the reports already in the table
+	c, err = newCassandraCollector(cfg, window, session, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.HasHistoricReports() {
+		t.Error("Expected historic reports after restart")
+	}
+}
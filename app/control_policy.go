@@ -0,0 +1,116 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"context"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+// ErrControlForbidden is returned when the control policy doesn't allow a
+// user to invoke a control.
+var ErrControlForbidden = errors.New("not allowed to invoke this control")
+
+// ControlPolicy says which users may invoke which controls. Users are
+// identified by a request header, set by an authenticating proxy in front of
+// the app (e.g. oauth2-proxy's X-Forwarded-User).
+type ControlPolicy struct {
+	UserHeader string `json:"userHeader"`
+	// ReadOnly disables all controls, whatever Allow says.
+	ReadOnly bool `json:"readOnly"`
+	// Allow maps a user, or "*" for any user, to the controls they may
+	// invoke, as "<topology>:<control>" rules. The topology is the node ID
+	// tag, e.g. "container" or "pod", and either side may be a glob, as in
+	// "container:docker_*" or "*:*".
+	Allow map[string][]string `json:"allow"`
+}
+
+// LoadControlPolicy reads a JSON control policy from a file.
+func LoadControlPolicy(filename string) (ControlPolicy, error) {
+	var policy ControlPolicy
+	f, err := os.Open(filename)
+	if err != nil {
+		return policy, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&policy); err != nil {
+		return policy, fmt.Errorf("error parsing control policy %s: %v", filename, err)
+	}
+	for user, rules := range policy.Allow {
+		for _, rule := range rules {
+			topology, control, ok := splitControlRule(rule)
+			if !ok {
+				return policy, fmt.Errorf("invalid control rule %q for user %q, want <topology>:<control>", rule, user)
+			}
+			if _, err := path.Match(topology, ""); err != nil {
+				return policy, fmt.Errorf("invalid control rule %q for user %q: %v", rule, user, err)
+			}
+			if _, err := path.Match(control, ""); err != nil {
+				return policy, fmt.Errorf("invalid control rule %q for user %q: %v", rule, user, err)
+			}
+		}
+	}
+	return policy, nil
+}
+
+func splitControlRule(rule string) (topology, control string, ok bool) {
+	pos := strings.IndexByte(rule, ':')
+	if pos == -1 {
+		return "", "", false
+	}
+	return rule[:pos], rule[pos+1:], true
+}
+
+// Allowed says whether user may invoke control on the node with the given ID.
+func (p ControlPolicy) Allowed(user, nodeID, control string) bool {
+	if p.ReadOnly {
+		return false
+	}
+	topology := ""
+	if _, tag, ok := report.ParseNodeID(nodeID); ok {
+		topology = strings.TrimSuffix(strings.TrimPrefix(tag, "<"), ">")
+	}
+	for _, rules := range [][]string{p.Allow["*"], p.Allow[user]} {
+		for _, rule := range rules {
+			topologyPattern, controlPattern, _ := splitControlRule(rule)
+			if ok, _ := path.Match(topologyPattern, topology); !ok {
+				continue
+			}
+			if ok, _ := path.Match(controlPattern, control); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewAuthorizingControlRouter makes a ControlRouter which only passes on
+// control requests allowed by the policy, returning ErrControlForbidden for
+// the others.
+func NewAuthorizingControlRouter(cr ControlRouter, policy ControlPolicy) ControlRouter {
+	return authorizingControlRouter{ControlRouter: cr, policy: policy}
+}
+
+type authorizingControlRouter struct {
+	ControlRouter
+	policy ControlPolicy
+}
+
+func (a authorizingControlRouter) Handle(ctx context.Context, probeID string, req xfer.Request) (xfer.Response, error) {
+	user := ""
+	if r, ok := ctx.Value(RequestCtxKey).(*http.Request); ok && r != nil && a.policy.UserHeader != "" {
+		user = r.Header.Get(a.policy.UserHeader)
+	}
+	if !a.policy.Allowed(user, req.NodeID, req.Control) {
+		return xfer.Response{}, ErrControlForbidden
+	}
+	return a.ControlRouter.Handle(ctx, probeID, req)
+}
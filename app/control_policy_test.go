@@ -0,0 +1,83 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"context"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+func TestControlPolicyAllowed(t *testing.T) {
+	policy := app.ControlPolicy{
+		Allow: map[string][]string{
+			"*":     {"container:docker_attach_container"},
+			"alice": {"*:*"},
+			"bob":   {"container:docker_*", "pod:kubernetes_delete_pod"},
+		},
+	}
+	for _, c := range []struct {
+		user, nodeID, control string
+		want                  bool
+	}{
+		{"", "abc;<container>", "docker_attach_container", true},
+		{"", "abc;<container>", "docker_stop_container", false},
+		{"alice", "host1;<host>", "host_exec", true},
+		{"bob", "abc;<container>", "docker_stop_container", true},
+		{"bob", "ns/pod;<pod>", "kubernetes_delete_pod", true},
+		{"bob", "host1;<host>", "host_exec", false},
+	} {
+		if have := policy.Allowed(c.user, c.nodeID, c.control); have != c.want {
+			t.Errorf("Allowed(%q, %q, %q): want %v, have %v", c.user, c.nodeID, c.control, c.want, have)
+		}
+	}
+
+	policy.ReadOnly = true
+	if policy.Allowed("alice", "host1;<host>", "host_exec") {
+		t.Errorf("Expected read-only policy to forbid all controls")
+	}
+}
+
+func TestAuthorizingControlRouter(t *testing.T) {
+	cr := app.NewLocalControlRouter()
+	if _, err := cr.Register(context.Background(), "probe", func(xfer.Request) xfer.Response {
+		return xfer.Response{Value: "ok"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	app.RegisterControlRoutes(router, app.NewAuthorizingControlRouter(cr, app.ControlPolicy{
+		UserHeader: "X-Forwarded-User",
+		Allow:      map[string][]string{"alice": {"*:*"}},
+	}))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for user, want := range map[string]int{
+		"alice": http.StatusOK,
+		"bob":   http.StatusForbidden,
+		"":      http.StatusForbidden,
+	} {
+		req, err := http.NewRequest("POST", server.URL+"/api/control/probe/host1;<host>/host_exec", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user != "" {
+			req.Header.Set("X-Forwarded-User", user)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("User %q: want status %d, have %d", user, want, resp.StatusCode)
+		}
+	}
+}
@@ -51,7 +51,10 @@ func handleControl(cr ControlRouter) CtxHandlerFunc {
 			Control:     control,
 			ControlArgs: controlArgs,
 		})
-		if err != nil {
+		if err == ErrControlForbidden {
+			respondWith(ctx, w, http.StatusForbidden, err.Error())
+			return
+		} else if err != nil {
 			respondWith(ctx, w, http.StatusBadRequest, err.Error())
 			return
 		}
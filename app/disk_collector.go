@@ -0,0 +1,328 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	diskReportExt          = ".msgpack.gz"
+	diskCompactionInterval = time.Minute
+)
+
+// diskCollector is a collector which also writes every report it receives to
+// a directory, so that history survives restarts of the app and is retained
+// for longer than the window. Files are named by their timestamp in
+// nanoseconds since the epoch, as read by NewFileCollector.
+//
+// In the background, the files of reports which have dropped out of the
+// window are compacted: those in each window-long bucket of time are merged
+// into one file, named for the end of the bucket.
+type diskCollector struct {
+	*collector
+	dir       string
+	retention time.Duration
+	merger    Merger
+	quit      chan struct{}
+
+	mtx    sync.Mutex
+	files  []time.Time // timestamps of the files in dir, oldest first
+	latest time.Time   // of the last file written, or being written
+}
+
+// NewDiskCollector returns a collector which persists reports in dir,
+// keeping them for the given retention. Reports for times older than the
+// window are read back from disk, so the UI can travel back in time up to
+// the retention. Reports in dir from a previous run are loaded on startup.
+func NewDiskCollector(dir string, window, retention time.Duration) (Collector, error) {
+	if retention < window {
+		return nil, fmt.Errorf("disk collector retention %v is shorter than the window %v", retention, window)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &diskCollector{
+		collector: NewCollector(window).(*collector),
+		dir:       dir,
+		retention: retention,
+		merger:    NewFastMerger(),
+		quit:      make(chan struct{}),
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), diskReportExt) {
+			continue
+		}
+		t, err := timestampFromFilepath(info.Name())
+		if err != nil {
+			log.Warnf("Disk collector: ignoring %s: %v", info.Name(), err)
+			continue
+		}
+		c.files = append(c.files, t)
+	}
+	sort.Slice(c.files, func(i, j int) bool { return c.files[i].Before(c.files[j]) })
+	if n := len(c.files); n > 0 {
+		c.latest = c.files[n-1]
+	}
+	c.prune()
+
+	// Pick up where the last run left off
+	for _, t := range c.filesBetween(mtime.Now().Add(-window), mtime.Now()) {
+		rpt, err := c.load(t)
+		if err != nil {
+			log.Warnf("Disk collector: error reading report from disk: %v", err)
+			continue
+		}
+		c.collector.reports = append(c.collector.reports, rpt.Upgrade())
+		c.collector.timestamps = append(c.collector.timestamps, t)
+	}
+	go c.loop()
+	return c, nil
+}
+
+// Close stops compacting files. It implements Collector.
+func (c *diskCollector) Close() {
+	close(c.quit)
+	c.collector.Close()
+}
+
+func (c *diskCollector) loop() {
+	ticker := time.NewTicker(diskCompactionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.compact()
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// Add adds a report to the collector and writes it to disk. It implements
+// Adder.
+func (c *diskCollector) Add(ctx context.Context, rpt report.Report, buf []byte) error {
+	if err := c.collector.Add(ctx, rpt, buf); err != nil {
+		return err
+	}
+	// buf, if given, is the report as gzipped msgpack already
+	if buf == nil {
+		b, err := rpt.WriteBinary()
+		if err != nil {
+			return err
+		}
+		buf = b.Bytes()
+	}
+
+	c.mtx.Lock()
+	t := mtime.Now()
+	if !t.After(c.latest) {
+		// Keep file names unique and in order
+		t = c.latest.Add(time.Nanosecond)
+	}
+	c.latest = t
+	c.mtx.Unlock()
+
+	// The file isn't read until its timestamp is in c.files
+	if err := ioutil.WriteFile(c.path(t), buf, 0644); err != nil {
+		return fmt.Errorf("error writing report to disk: %v", err)
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	// Concurrent writes may finish out of order
+	i := sort.Search(len(c.files), func(i int) bool { return c.files[i].After(t) })
+	c.files = append(c.files, time.Time{})
+	copy(c.files[i+1:], c.files[i:])
+	c.files[i] = t
+	c.prune()
+	return nil
+}
+
+// Report returns a merged report over the window up to timestamp. Reports
+// older than the window kept in memory are read from disk. It implements
+// Reporter.
+func (c *diskCollector) Report(ctx context.Context, timestamp time.Time) (report.Report, error) {
+	if !timestamp.Before(mtime.Now().Add(-c.window)) {
+		return c.collector.Report(ctx, timestamp)
+	}
+
+	c.mtx.Lock()
+	files := c.filesBetween(timestamp.Add(-c.window), timestamp)
+	c.mtx.Unlock()
+	reports := make([]report.Report, 0, len(files))
+	for _, t := range files {
+		rpt, err := c.load(t)
+		if os.IsNotExist(err) {
+			// Pruned or compacted since we listed the files
+			continue
+		} else if err != nil {
+			return report.MakeReport(), fmt.Errorf("error reading report from disk: %v", err)
+		}
+		reports = append(reports, rpt.Upgrade())
+	}
+	return c.merger.Merge(reports), nil
+}
+
+// HasReports indicates whether the collector contains reports between
+// timestamp-app.window and timestamp.
+func (c *diskCollector) HasReports(ctx context.Context, timestamp time.Time) (bool, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.filesBetween(timestamp.Add(-c.window), timestamp)) > 0, nil
+}
+
+// HasHistoricReports indicates whether the collector contains reports
+// older than now-app.window.
+func (c *diskCollector) HasHistoricReports() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.files) > 0 && c.files[0].Before(mtime.Now().Add(-c.window))
+}
+
+func (c *diskCollector) path(t time.Time) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%d%s", t.UnixNano(), diskReportExt))
+}
+
+func (c *diskCollector) load(t time.Time) (report.Report, error) {
+	rpt, err := report.MakeFromFile(context.Background(), c.path(t))
+	if err != nil {
+		return report.MakeReport(), err
+	}
+	return *rpt, nil
+}
+
+// compact merges the files in each bucket which ended at least a window ago
+// into a single file, named for the end of the bucket. Reports for times in
+// the bucket before its end are no longer available once it is compacted.
+func (c *diskCollector) compact() {
+	cutoff := mtime.Now().Add(-2 * c.window)
+	buckets := map[time.Time][]time.Time{}
+	c.mtx.Lock()
+	for _, t := range c.files {
+		end := c.bucketEnd(t)
+		if end.After(cutoff) {
+			break
+		}
+		buckets[end] = append(buckets[end], t)
+	}
+	c.mtx.Unlock()
+
+	for end, files := range buckets {
+		if len(files) == 1 && files[0].Equal(end) {
+			continue // already compacted
+		}
+		if err := c.compactBucket(end, files); err != nil {
+			log.Warnf("Disk collector: error compacting reports: %v", err)
+		}
+	}
+}
+
+// bucketEnd returns the end of the window-long bucket t falls in. Buckets
+// include their end, as filesBetween does.
+func (c *diskCollector) bucketEnd(t time.Time) time.Time {
+	end := t.Truncate(c.window)
+	if end.Before(t) {
+		end = end.Add(c.window)
+	}
+	return end
+}
+
+func (c *diskCollector) compactBucket(end time.Time, files []time.Time) error {
+	reports := make([]report.Report, 0, len(files))
+	for _, t := range files {
+		rpt, err := c.load(t)
+		if os.IsNotExist(err) {
+			continue // pruned since we listed the files
+		} else if err != nil {
+			return err
+		}
+		reports = append(reports, rpt.Upgrade())
+	}
+	if len(reports) == 0 {
+		return nil
+	}
+	buf, err := c.merger.Merge(reports).WriteBinary()
+	if err != nil {
+		return err
+	}
+	// Write to a name NewDiskCollector ignores, so that readers only ever
+	// see the whole file
+	tmp := c.path(end) + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path(end)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	c.mtx.Lock()
+	compacted := map[int64]bool{}
+	for _, t := range files {
+		compacted[t.UnixNano()] = true
+	}
+	kept := c.files[:0]
+	for _, t := range c.files {
+		if !compacted[t.UnixNano()] {
+			kept = append(kept, t)
+		}
+	}
+	c.files = kept
+	i := sort.Search(len(c.files), func(i int) bool { return c.files[i].After(end) })
+	c.files = append(c.files, time.Time{})
+	copy(c.files[i+1:], c.files[i:])
+	c.files[i] = end
+	c.prune()
+	c.mtx.Unlock()
+
+	for _, t := range files {
+		if t.Equal(end) {
+			continue
+		}
+		if err := os.Remove(c.path(t)); err != nil && !os.IsNotExist(err) {
+			log.Warnf("Disk collector: error removing compacted report: %v", err)
+		}
+	}
+	return nil
+}
+
+// filesBetween returns the timestamps of the files in (from, to]. The caller
+// must hold c.mtx.
+func (c *diskCollector) filesBetween(from, to time.Time) []time.Time {
+	var result []time.Time
+	for _, t := range c.files {
+		if t.After(from) && !t.After(to) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// prune removes files older than the retention. The caller must hold c.mtx.
+func (c *diskCollector) prune() {
+	oldest := mtime.Now().Add(-c.retention)
+	i := 0
+	for ; i < len(c.files) && !c.files[i].After(oldest); i++ {
+		if err := os.Remove(c.path(c.files[i])); err != nil && !os.IsNotExist(err) {
+			log.Warnf("Disk collector: error removing expired report: %v", err)
+		}
+	}
+	c.files = c.files[i:]
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestDiskCollectorCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-disk-collector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	window := 10 * time.Second
+	now := time.Now().Truncate(window)
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	rc, err := NewDiskCollector(dir, window, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	c := rc.(*diskCollector)
+
+	// Three reports in the bucket ending at now+window
+	for i, id := range []string{"a", "b", "c"} {
+		mtime.NowForce(now.Add(time.Duration(i+1) * time.Second))
+		rpt := report.MakeReport()
+		rpt.Host.AddNode(report.MakeNode(report.MakeHostNodeID(id)))
+		if err := c.Add(ctx, rpt, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Not compacted while the bucket is recent
+	c.compact()
+	if have := readDiskReportNames(t, dir); len(have) != 3 {
+		t.Fatalf("Expected 3 files, have %v", have)
+	}
+
+	mtime.NowForce(now.Add(time.Minute))
+	c.compact()
+	end := now.Add(window)
+	if have, want := readDiskReportNames(t, dir), filepath.Base(c.path(end)); len(have) != 1 || have[0] != want {
+		t.Fatalf("Expected only %s, have %v", want, have)
+	}
+	rpt, err := c.Report(ctx, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := rpt.Host.Nodes; len(have) != 3 {
+		t.Errorf("Expected 3 hosts in compacted report, have %v", have)
+	}
+
+	// Compacting again leaves the file alone
+	c.compact()
+	if have := readDiskReportNames(t, dir); len(have) != 1 {
+		t.Errorf("Expected 1 file, have %v", have)
+	}
+}
+
+func readDiskReportNames(t *testing.T, dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	return names
+}
@@ -0,0 +1,95 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+)
+
+// TestDiskCollector passes the collector reports already serialised, and
+// doesn't read them back, so that it doesn't depend on the report codec.
+func TestDiskCollector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-disk-collector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	ctx := context.Background()
+	window := 10 * time.Second
+	retention := time.Hour
+	if _, err := app.NewDiskCollector(dir, retention, window); err == nil {
+		t.Error("Expected an error for a retention shorter than the window")
+	}
+	c, err := app.NewDiskCollector(dir, window, retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The serialised report is written as is
+	buf1 := []byte("report 1")
+	if err := c.Add(ctx, report.MakeReport(), buf1); err != nil {
+		t.Fatal(err)
+	}
+	if have := readDiskReports(t, dir); len(have) != 1 || !bytes.Equal(have[0], buf1) {
+		t.Errorf("Expected %q on disk, have %q", buf1, have)
+	}
+
+	// Once the report has dropped out of the window it is kept on disk
+	mtime.NowForce(now.Add(time.Minute))
+	if !c.HasHistoricReports() {
+		t.Error("Expected historic reports")
+	}
+
+	// A new collector on the same directory picks up the existing reports
+	c, err = app.NewDiskCollector(dir, window, retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if ok, _ := c.HasReports(ctx, now); !ok {
+		t.Error("Expected reports after restart")
+	}
+
+	// Reports older than the retention are removed
+	mtime.NowForce(now.Add(retention))
+	buf2 := []byte("report 2")
+	if err := c.Add(ctx, report.MakeReport(), buf2); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.HasReports(ctx, now); ok {
+		t.Error("Expected expired reports to be removed")
+	}
+	if have := readDiskReports(t, dir); len(have) != 1 || !bytes.Equal(have[0], buf2) {
+		t.Errorf("Expected only %q on disk, have %q", buf2, have)
+	}
+}
+
+func readDiskReports(t *testing.T, dir string) [][]byte {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result [][]byte
+	for _, f := range files {
+		buf, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, buf)
+	}
+	return result
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	reportPostSizeHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "scope",
+		Name:      "report_post_size_bytes",
+		Help:      "Distribution of the gzipped sizes of reports posted by probes; its count is the number of reports received.",
+		Buckets:   prometheus.ExponentialBuckets(4096, 2.0, 10),
+	})
+	websocketClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "scope",
+		Name:      "websocket_clients",
+		Help:      "Number of topology websockets currently open.",
+	})
+	websocketRenderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "scope",
+		Name:      "websocket_render_duration_seconds",
+		Help:      "Time in seconds spent rendering topology updates for websockets.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topology"})
+)
+
+// MustRegisterMetrics registers the app's metrics with Prometheus.
+func MustRegisterMetrics() {
+	prometheus.MustRegister(reportPostSizeHistogram)
+	prometheus.MustRegister(websocketClients)
+	prometheus.MustRegister(websocketRenderDuration)
+}
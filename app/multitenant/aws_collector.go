@@ -360,6 +360,20 @@ func (c *awsCollector) getReportKeys(ctx context.Context, userid string, start,
 }
 
 func (c *awsCollector) getReports(ctx context.Context, userid string, reportKeys []string) ([]report.Report, error) {
+	found, err := c.getReportsByKey(ctx, userid, reportKeys)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]report.Report, 0, len(found))
+	for _, report := range found {
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// getReportsByKey fetches reports from the in-process cache, memcached and
+// S3 in turn, and returns them keyed by their S3 key.
+func (c *awsCollector) getReportsByKey(ctx context.Context, userid string, reportKeys []string) (map[string]report.Report, error) {
 	missing := reportKeys
 
 	stores := []ReportStore{c.inProcess}
@@ -368,7 +382,7 @@ func (c *awsCollector) getReports(ctx context.Context, userid string, reportKeys
 	}
 	stores = append(stores, c.awsCfg.S3Store)
 
-	var reports []report.Report
+	reports := make(map[string]report.Report, len(reportKeys))
 	for _, store := range stores {
 		if store == nil {
 			continue
@@ -381,7 +395,7 @@ func (c *awsCollector) getReports(ctx context.Context, userid string, reportKeys
 		for key, report := range found {
 			report = c.massageReport(userid, report)
 			c.inProcess.StoreReport(key, report)
-			reports = append(reports, report)
+			reports[key] = report
 		}
 		if len(missing) == 0 {
 			return reports, nil
@@ -521,14 +535,21 @@ func (c *awsCollector) AdminSummary(ctx context.Context, timestamp time.Time) (s
 	if err != nil {
 		return "", err
 	}
-	reports, err := c.reportsForKeysInRange(ctx, userid, reportKeys, start.UnixNano(), end.UnixNano())
+	var keys []string
+	for _, k := range reportKeys {
+		if k.ts >= start.UnixNano() && k.ts < end.UnixNano() {
+			keys = append(keys, k.key)
+		}
+	}
+	reports, err := c.getReportsByKey(ctx, userid, keys)
 	if err != nil {
 		return "", err
 	}
 	var b strings.Builder
-	for i := range reports {
-		// TODO: print the key - note reports may be in a different order from reportKeys
-		b.WriteString(reports[i].Summary())
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteString(": ")
+		b.WriteString(reports[key].Summary())
 		b.WriteByte('\n')
 	}
 	return b.String(), nil
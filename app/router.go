@@ -89,6 +89,32 @@ func gzipHandler(h http.HandlerFunc) http.Handler {
 	return gziphandler.GzipHandler(h)
 }
 
+// RequireClientCert rejects the requests only probes make (publishing
+// reports, and connecting the control and pipe websockets) unless they come
+// with a verified TLS client certificate. The UI, and the UI end of pipes,
+// don't need one.
+func RequireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isProbeRequest(r) && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isProbeRequest(r *http.Request) bool {
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/api/report":
+		return true
+	case r.URL.Path == "/api/control/ws":
+		return true
+	case strings.HasPrefix(r.URL.Path, "/api/pipe/") && strings.HasSuffix(r.URL.Path, "/probe"):
+		return true
+	}
+	return false
+}
+
 // RegisterTopologyRoutes registers the various topology routes with a http mux.
 func RegisterTopologyRoutes(router *mux.Router, r Reporter, capabilities map[string]bool) {
 	get := router.Methods("GET").Subrouter()
@@ -153,6 +179,7 @@ func RegisterReportPostHandler(a Adder, router *mux.Router) {
 			respondWith(ctx, w, http.StatusInternalServerError, err)
 			return
 		}
+		reportPostSizeHistogram.Observe(float64(buf.Len()))
 		w.WriteHeader(http.StatusOK)
 	}))
 }
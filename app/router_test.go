@@ -2,6 +2,8 @@ package app_test
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -39,6 +41,36 @@ func TestURLMatcher(t *testing.T) {
 	test("/a/b/{c}", "/a/b/b%2Fb", true, v{"c": "b/b"})
 }
 
+func TestRequireClientCert(t *testing.T) {
+	handler := app.RequireClientCert(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	for _, c := range []struct {
+		method, path string
+		tls          *tls.ConnectionState
+		want         int
+	}{
+		{"POST", "/api/report", nil, http.StatusUnauthorized},
+		{"POST", "/api/report", &tls.ConnectionState{}, http.StatusUnauthorized},
+		{"POST", "/api/report", verified, http.StatusOK},
+		{"GET", "/api/control/ws", nil, http.StatusUnauthorized},
+		{"GET", "/api/pipe/pipe-1/probe", nil, http.StatusUnauthorized},
+		{"GET", "/api/pipe/pipe-1/probe", verified, http.StatusOK},
+		// The UI doesn't need a certificate
+		{"GET", "/api/report", nil, http.StatusOK},
+		{"GET", "/api/topology", nil, http.StatusOK},
+		{"GET", "/api/pipe/pipe-1", nil, http.StatusOK},
+		{"DELETE", "/api/pipe/pipe-1", nil, http.StatusOK},
+	} {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.TLS = c.tls
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != c.want {
+			t.Errorf("%s %s: want %d, have %d", c.method, c.path, c.want, w.Code)
+		}
+	}
+}
+
 func TestReportPostHandler(t *testing.T) {
 	test := func(contentType string, encoder func(interface{}) ([]byte, error)) {
 		router := mux.NewRouter()
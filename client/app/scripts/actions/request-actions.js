@@ -68,8 +68,12 @@ let continuePolling = true;
 let firstMessageOnWebsocketAt = null;
 let createWebsocketAt = null;
 let currentUrl = null;
+// Last stream ID and sequence number received, passed back on reconnect so
+// the app can resume the stream instead of resending the whole topology.
+let streamId = null;
+let streamSeq = 0;
 
-function createWebsocket(websocketUrl, getState, dispatch) {
+function createWebsocket(websocketUrl, getState, dispatch, resume = false) {
   if (socket) {
     socket.onclose = null;
     socket.onerror = null;
@@ -82,7 +86,12 @@ function createWebsocket(websocketUrl, getState, dispatch) {
   createWebsocketAt = new Date();
   firstMessageOnWebsocketAt = null;
 
-  socket = new WebSocket(websocketUrl);
+  if (resume && streamId) {
+    socket = new WebSocket(`${websocketUrl}&stream=${streamId}&seq=${streamSeq}`);
+  } else {
+    streamId = null;
+    socket = new WebSocket(websocketUrl);
+  }
 
   socket.onopen = () => {
     log(`Opening websocket to ${websocketUrl}`);
@@ -97,7 +106,7 @@ function createWebsocket(websocketUrl, getState, dispatch) {
 
     if (continuePolling && !isPausedSelector(getState())) {
       reconnectTimer = setTimeout(() => {
-        createWebsocket(websocketUrl, getState, dispatch);
+        createWebsocket(websocketUrl, getState, dispatch, true);
       }, reconnectTimerInterval);
     }
   };
@@ -109,6 +118,8 @@ function createWebsocket(websocketUrl, getState, dispatch) {
 
   socket.onmessage = (event) => {
     const msg = JSON.parse(event.data);
+    streamId = msg.stream;
+    streamSeq = msg.seq;
     dispatch(receiveNodesDelta(msg));
 
     // profiling (receiveNodesDelta triggers synchronous render)
@@ -0,0 +1,175 @@
+package xfer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a certificate and key pair read from files, reading
+// them again whenever either file is modified. This lets certificates which
+// are rotated on disk (e.g. by cert-manager) be picked up by both the app and
+// the probes without restarting them.
+type CertReloader struct {
+	certFile, keyFile string
+
+	mtx     sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertReloader makes a new CertReloader, loading the certificate once so
+// that misconfiguration is reported at startup.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load returns the current certificate, reloading it if either file has
+// changed since it was last read. If reloading fails, the previously loaded
+// certificate keeps being used, so a half-written rotation doesn't break
+// new connections.
+func (r *CertReloader) load() (*tls.Certificate, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	if r.cert != nil && modTime.Equal(r.modTime) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	r.cert, r.modTime = &cert, modTime
+	return r.cert, nil
+}
+
+func latestModTime(filenames ...string) (time.Time, error) {
+	var latest time.Time
+	for _, filename := range filenames {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate, for servers.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.load()
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate, for clients.
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.load()
+}
+
+// LoadCertPool reads a PEM file of CA certificates into a pool.
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// CertPoolReloader serves a pool of CA certificates read from a PEM file,
+// reading it again whenever it is modified, so CA bundles can be rotated
+// like certificates.
+type CertPoolReloader struct {
+	caFile string
+
+	mtx     sync.Mutex
+	pool    *x509.CertPool
+	modTime time.Time
+}
+
+// NewCertPoolReloader makes a new CertPoolReloader, loading the pool once so
+// that misconfiguration is reported at startup.
+func NewCertPoolReloader(caFile string) (*CertPoolReloader, error) {
+	r := &CertPoolReloader{caFile: caFile}
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load returns the current pool, reloading it if the file has changed since
+// it was last read. As with CertReloader, a failed reload keeps the previous
+// pool.
+func (r *CertPoolReloader) load() (*x509.CertPool, error) {
+	modTime, err := latestModTime(r.caFile)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if err != nil {
+		if r.pool != nil {
+			return r.pool, nil
+		}
+		return nil, err
+	}
+	if r.pool != nil && modTime.Equal(r.modTime) {
+		return r.pool, nil
+	}
+	pool, err := LoadCertPool(r.caFile)
+	if err != nil {
+		if r.pool != nil {
+			return r.pool, nil
+		}
+		return nil, err
+	}
+	r.pool, r.modTime = pool, modTime
+	return r.pool, nil
+}
+
+// Pool returns the current pool.
+func (r *CertPoolReloader) Pool() *x509.CertPool {
+	pool, _ := r.load()
+	return pool
+}
+
+// VerifyServer returns a tls.Config.VerifyConnection which verifies the
+// server is serverName, against the current pool. tls.Config.RootCAs is
+// fixed once a client is made, so clients set InsecureSkipVerify and verify
+// with this instead.
+func (r *CertPoolReloader) VerifyServer(serverName string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("no server certificate")
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range cs.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+			DNSName:       serverName,
+			Roots:         r.Pool(),
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
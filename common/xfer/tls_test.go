@@ -0,0 +1,152 @@
+package xfer_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+func writeCert(t *testing.T, dir, name string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for filename, block := range map[string]*pem.Block{
+		"tls.crt": {Type: "CERTIFICATE", Bytes: der},
+		"tls.key": {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		path := filepath.Join(dir, filename)
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func commonName(t *testing.T, r *xfer.CertReloader) string {
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Now()
+
+	if _, err := xfer.NewCertReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")); err == nil {
+		t.Fatal("Expected an error for missing files")
+	}
+
+	writeCert(t, dir, "first", now.Add(-time.Minute))
+	r, err := xfer.NewCertReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := commonName(t, r); have != "first" {
+		t.Errorf("Expected first certificate, got %q", have)
+	}
+
+	writeCert(t, dir, "second", now)
+	if have := commonName(t, r); have != "second" {
+		t.Errorf("Expected rotated certificate, got %q", have)
+	}
+
+	// A broken rotation keeps the last good certificate
+	if err := ioutil.WriteFile(filepath.Join(dir, "tls.key"), []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if have := commonName(t, r); have != "second" {
+		t.Errorf("Expected last good certificate, got %q", have)
+	}
+}
+
+func readCert(t *testing.T, filename string) *x509.Certificate {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(buf)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestCertPoolReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	caFile := filepath.Join(dir, "tls.crt")
+
+	if _, err := xfer.NewCertPoolReloader(caFile); err == nil {
+		t.Fatal("Expected an error for a missing file")
+	}
+
+	writeCert(t, dir, "first", now.Add(-time.Minute))
+	first := readCert(t, caFile)
+	r, err := xfer.NewCertPoolReloader(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Verify(x509.VerifyOptions{Roots: r.Pool()}); err != nil {
+		t.Errorf("Expected first CA to be trusted: %v", err)
+	}
+
+	writeCert(t, dir, "second", now)
+	second := readCert(t, caFile)
+	if _, err := first.Verify(x509.VerifyOptions{Roots: r.Pool()}); err == nil {
+		t.Errorf("Expected first CA to be dropped after rotation")
+	}
+	if _, err := second.Verify(x509.VerifyOptions{Roots: r.Pool()}); err != nil {
+		t.Errorf("Expected rotated CA to be trusted: %v", err)
+	}
+
+	// A broken rotation keeps the last good pool
+	if err := ioutil.WriteFile(caFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Verify(x509.VerifyOptions{Roots: r.Pool()}); err != nil {
+		t.Errorf("Expected last good CA to be trusted: %v", err)
+	}
+}
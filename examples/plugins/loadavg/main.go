@@ -0,0 +1,165 @@
+// Command loadavg is a minimal Scope probe plugin, written against the
+// protocol described in ../README.md rather than Scope's own packages.
+// It reports each host's one minute load average as a metric on the host
+// node.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	pluginID = "loadavg"
+	metricID = "loadavg_1m"
+)
+
+type sample struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+type metric struct {
+	Samples []sample `json:"samples"`
+	Min     float64  `json:"min"`
+	Max     float64  `json:"max"`
+}
+
+type node struct {
+	Metrics map[string]metric `json:"metrics"`
+}
+
+type metricTemplate struct {
+	ID       string  `json:"id"`
+	Label    string  `json:"label"`
+	Format   string  `json:"format,omitempty"`
+	Priority float64 `json:"priority"`
+}
+
+type topology struct {
+	Nodes           map[string]node           `json:"nodes"`
+	MetricTemplates map[string]metricTemplate `json:"metric_templates"`
+}
+
+type pluginSpec struct {
+	ID          string   `json:"id"`
+	Label       string   `json:"label"`
+	Description string   `json:"description,omitempty"`
+	Interfaces  []string `json:"interfaces"`
+	APIVersion  string   `json:"api_version,omitempty"`
+}
+
+type report struct {
+	Host    topology
+	Plugins []pluginSpec
+}
+
+// loadAverage reads the one minute load average.
+func loadAverage() (float64, error) {
+	buf, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(buf))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty /proc/loadavg")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+func handleReport(hostID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		load, err := loadAverage()
+		if err != nil {
+			log.Printf("error reading load average: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rpt := report{
+			Host: topology{
+				Nodes: map[string]node{
+					// Host node IDs are "<hostname>;<host>", see
+					// "Naming Nodes" in ../README.md
+					hostID + ";<host>": {
+						Metrics: map[string]metric{
+							metricID: {
+								Samples: []sample{{Date: time.Now(), Value: load}},
+								Min:     0,
+								Max:     load,
+							},
+						},
+					},
+				},
+				MetricTemplates: map[string]metricTemplate{
+					metricID: {ID: metricID, Label: "Load (1m)", Priority: 0.1},
+				},
+			},
+			Plugins: []pluginSpec{{
+				ID:          pluginID,
+				Label:       "Load average",
+				Description: "Reports the one minute load average of hosts",
+				Interfaces:  []string{"reporter"},
+				APIVersion:  "1",
+			}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(rpt); err != nil {
+			log.Printf("error encoding report: %v", err)
+		}
+	}
+}
+
+func main() {
+	var (
+		root     = flag.String("plugins.root", "/var/run/scope/plugins", "Directory the Scope probe searches for plugins")
+		hostname = flag.String("hostname", "", "Hostname the probe reports for this host (defaults to the system hostname)")
+	)
+	flag.Parse()
+	if *hostname == "" {
+		name, err := os.Hostname()
+		if err != nil {
+			log.Fatal(err)
+		}
+		*hostname = name
+	}
+
+	// The probe derives the plugin ID from the socket name, and keeps
+	// looking for it in sub-directories of the plugins root.
+	dir := filepath.Join(*root, pluginID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Fatal(err)
+	}
+	socket := filepath.Join(dir, pluginID+".sock")
+	os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+		listener.Close()
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/report", handleReport(*hostname))
+	log.Printf("listening on %s", socket)
+	if err := http.Serve(listener, mux); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+		log.Print(err)
+	}
+}
@@ -23,6 +23,7 @@ require (
 	github.com/evanphx/json-patch v0.0.0-20170719203123-944e07253867 // indirect
 	github.com/fatih/camelcase v1.0.0 // indirect
 	github.com/fsouza/go-dockerclient v1.3.0
+	github.com/gocql/gocql v1.0.0
 	github.com/gogo/protobuf v1.3.0
 	github.com/goji/httpauth v0.0.0-20160601135302-2da839ab0f4d
 	github.com/golang/groupcache v0.0.0-20171101203131-84a468cf14b4 // indirect
@@ -60,6 +61,8 @@ require (
 	github.com/peterbourgon/runsvinit v2.0.0+incompatible
 	github.com/pkg/errors v0.8.1
 	github.com/prometheus/client_golang v1.5.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.9.1
 	github.com/richo/GOSHOUT v0.0.0-20210103052837-9a2e452d4c18
 	github.com/russross/blackfriday v0.0.0-20151020174500-a18a46c9b943 // indirect
 	github.com/shurcooL/sanitized_anchor_name v0.0.0-20150822220530-244f5ac324cb // indirect
@@ -89,7 +92,6 @@ require (
 	golang.org/x/time v0.0.0-20180412165947-fbb02b2291d2
 	golang.org/x/tools v0.0.0-20190424220101-1e8e1cfdf96b
 	google.golang.org/grpc v1.19.0
-	gopkg.in/inf.v0 v0.9.0 // indirect
 	k8s.io/api v0.0.0-20181204000039-89a74a8d264d
 	k8s.io/apimachinery v0.0.0-20181127025237-2b1284ed4c93
 	k8s.io/cli-runtime v0.0.0-20181204004549-a04da5c88c07 // indirect
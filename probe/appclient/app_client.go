@@ -220,6 +220,10 @@ func (c *appClient) doWithBackoff(msg string, f func() (bool, error)) {
 			backoff = initialBackoff
 			continue
 		}
+		metrics.IncrCounterWithLabels([]string{"retries"}, 1, []metrics.Label{
+			{Name: "operation", Value: msg},
+			{Name: "destination", Value: c.hostname},
+		})
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			// The timeout period itself serves as a backoff that
 			// prevents thrashing. Hence there is no need to introduce
@@ -292,7 +296,6 @@ func (c *appClient) publish(r io.Reader) error {
 	}
 	req.Header.Set("Content-Encoding", "gzip")
 	req.Header.Set("Content-Type", "application/msgpack")
-	// req.Header.Set("Content-Type", "application/binary") // TODO: we should use http.DetectContentType(..) on the gob'ed
 
 	// Make sure this request is cancelled when we stop the client
 	req.Cancel = c.quit
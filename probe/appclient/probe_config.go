@@ -36,6 +36,11 @@ type ProbeConfig struct {
 	ProbeVersion string
 	ProbeID      string
 	Insecure     bool
+
+	// ClientCert, if set, is presented to the app for mutual TLS.
+	ClientCert *xfer.CertReloader
+	// RootCAs, if set, verifies the app instead of the standard CAs.
+	RootCAs *xfer.CertPoolReloader
 }
 
 func (pc ProbeConfig) authorizeHeaders(headers http.Header) {
@@ -71,6 +76,15 @@ func (pc ProbeConfig) getHTTPTransport(hostname string) *http.Transport {
 			RootCAs:    certPool,
 			ServerName: hostname,
 		}
+		if pc.RootCAs != nil {
+			// Verify against the CA file as it is now, not as it was when
+			// the transport was made
+			transport.TLSClientConfig.InsecureSkipVerify = true
+			transport.TLSClientConfig.VerifyConnection = pc.RootCAs.VerifyServer(hostname)
+		}
+	}
+	if pc.ClientCert != nil {
+		transport.TLSClientConfig.GetClientCertificate = pc.ClientCert.GetClientCertificate
 	}
 	return transport
 }
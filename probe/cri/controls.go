@@ -0,0 +1,172 @@
+package cri
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/scope/common/xfer"
+	client "github.com/weaveworks/scope/cri/runtime"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/report"
+)
+
+// Control IDs used by the CRI integration.
+const (
+	StopContainer   = "cri_stop_container"
+	RemoveContainer = "cri_remove_container"
+	ExecContainer   = "cri_exec_container"
+	ResizeExecTTY   = "cri_resize_exec_tty"
+
+	waitTime = 10
+)
+
+// ContainerControls are the controls of the containers the CRI probe reports.
+var ContainerControls = []report.Control{
+	{
+		ID:    ExecContainer,
+		Human: "Exec shell",
+		Icon:  "fa fa-terminal",
+		Rank:  2,
+	},
+	{
+		ID:    StopContainer,
+		Human: "Stop",
+		Icon:  "fa fa-stop",
+		Rank:  8,
+	},
+	{
+		ID:    RemoveContainer,
+		Human: "Remove",
+		Icon:  "far fa-trash-alt",
+		Rank:  9,
+	},
+}
+
+// activeControls are the controls available to a container in state.
+func activeControls(state client.ContainerState) []string {
+	switch state {
+	case client.ContainerState_CONTAINER_RUNNING:
+		return []string{StopContainer, ExecContainer}
+	case client.ContainerState_CONTAINER_CREATED, client.ContainerState_CONTAINER_EXITED:
+		return []string{RemoveContainer}
+	default:
+		return nil
+	}
+}
+
+func (r *Reporter) stopContainer(containerID string, _ xfer.Request) xfer.Response {
+	log.Infof("Stopping container %s", containerID)
+	_, err := r.cri.StopContainer(context.Background(), &client.StopContainerRequest{
+		ContainerId: containerID,
+		Timeout:     waitTime,
+	})
+	return xfer.ResponseError(err)
+}
+
+func (r *Reporter) removeContainer(containerID string, req xfer.Request) xfer.Response {
+	log.Infof("Removing container %s", containerID)
+	if _, err := r.cri.RemoveContainer(context.Background(), &client.RemoveContainerRequest{
+		ContainerId: containerID,
+	}); err != nil {
+		return xfer.ResponseError(err)
+	}
+	return xfer.Response{
+		RemovedNode: req.NodeID,
+	}
+}
+
+func (r *Reporter) execContainer(containerID string, req xfer.Request) xfer.Response {
+	// With a TTY, stderr is sent on stdout, and the runtime refuses both
+	exec, err := r.cri.Exec(context.Background(), &client.ExecRequest{
+		ContainerId: containerID,
+		Cmd:         []string{"/bin/sh", "-c", "TERM=xterm exec $( (type getent > /dev/null 2>&1  && getent passwd root | cut -d: -f7 2>/dev/null) || echo /bin/sh)"},
+		Tty:         true,
+		Stdin:       true,
+		Stdout:      true,
+	})
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	session, err := dialExec(exec.Url)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+
+	id, pipe, err := controls.NewPipe(r.pipes, req.AppID)
+	if err != nil {
+		session.close()
+		return xfer.ResponseError(err)
+	}
+
+	r.Lock()
+	r.pipeIDToExec[id] = session
+	r.Unlock()
+
+	pipe.OnClose(func() {
+		r.Lock()
+		delete(r.pipeIDToExec, id)
+		r.Unlock()
+		session.close()
+	})
+	local, _ := pipe.Ends()
+	go func() {
+		if err := session.copy(local); err != nil {
+			log.Errorf("Error in exec in container %s: %v", containerID, err)
+		}
+		pipe.Close()
+	}()
+	return xfer.Response{
+		Pipe:             id,
+		RawTTY:           true,
+		ResizeTTYControl: ResizeExecTTY,
+	}
+}
+
+func (r *Reporter) resizeExecTTY(pipeID string, height, width uint) xfer.Response {
+	r.Lock()
+	session, ok := r.pipeIDToExec[pipeID]
+	r.Unlock()
+
+	if !ok {
+		return xfer.ResponseErrorf("Unknown pipeID (%q)", pipeID)
+	}
+
+	if err := session.resize(height, width); err != nil {
+		return xfer.ResponseErrorf(
+			"Error setting terminal size (%d, %d) of pipe %s: %v",
+			height, width, pipeID, err)
+	}
+
+	return xfer.Response{}
+}
+
+func captureContainerID(f func(string, xfer.Request) xfer.Response) func(xfer.Request) xfer.Response {
+	return func(req xfer.Request) xfer.Response {
+		containerID, ok := report.ParseContainerNodeID(req.NodeID)
+		if !ok {
+			return xfer.ResponseErrorf("Invalid ID: %s", req.NodeID)
+		}
+		return f(containerID, req)
+	}
+}
+
+func (r *Reporter) registerControls() {
+	controls := map[string]xfer.ControlHandlerFunc{
+		StopContainer:   captureContainerID(r.stopContainer),
+		RemoveContainer: captureContainerID(r.removeContainer),
+		ExecContainer:   captureContainerID(r.execContainer),
+		ResizeExecTTY:   xfer.ResizeTTYControlWrapper(r.resizeExecTTY),
+	}
+	r.handlerRegistry.Batch(nil, controls)
+}
+
+func (r *Reporter) deregisterControls() {
+	controls := []string{
+		StopContainer,
+		RemoveContainer,
+		ExecContainer,
+		ResizeExecTTY,
+	}
+	r.handlerRegistry.Batch(controls, nil)
+}
@@ -0,0 +1,133 @@
+package cri_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+
+	"github.com/weaveworks/scope/common/xfer"
+	client "github.com/weaveworks/scope/cri/runtime"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/cri"
+	"github.com/weaveworks/scope/report"
+)
+
+type mockControlsClient struct {
+	mockCRIClient
+	stopped, removed []string
+	execURL          string
+}
+
+func (m *mockControlsClient) StopContainer(_ context.Context, req *client.StopContainerRequest, _ ...grpc.CallOption) (*client.StopContainerResponse, error) {
+	m.stopped = append(m.stopped, req.ContainerId)
+	return &client.StopContainerResponse{}, nil
+}
+
+func (m *mockControlsClient) RemoveContainer(_ context.Context, req *client.RemoveContainerRequest, _ ...grpc.CallOption) (*client.RemoveContainerResponse, error) {
+	m.removed = append(m.removed, req.ContainerId)
+	return &client.RemoveContainerResponse{}, nil
+}
+
+func (m *mockControlsClient) Exec(_ context.Context, req *client.ExecRequest, _ ...grpc.CallOption) (*client.ExecResponse, error) {
+	return &client.ExecResponse{Url: m.execURL}, nil
+}
+
+func TestControls(t *testing.T) {
+	mock := &mockControlsClient{}
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := cri.NewReporter(mock, nil, "probe-id", hr)
+	defer reporter.Stop()
+
+	nodeID := report.MakeContainerNodeID("ping")
+	if have := hr.HandleControlRequest(xfer.Request{Control: cri.StopContainer, NodeID: nodeID}); !reflect.DeepEqual(have, xfer.Response{}) {
+		t.Errorf("stop: %v", have)
+	}
+	if have := hr.HandleControlRequest(xfer.Request{Control: cri.RemoveContainer, NodeID: nodeID}); !reflect.DeepEqual(have, xfer.Response{RemovedNode: nodeID}) {
+		t.Errorf("remove: %v", have)
+	}
+	if want := []string{"ping"}; !reflect.DeepEqual(mock.stopped, want) || !reflect.DeepEqual(mock.removed, want) {
+		t.Errorf("want ping stopped and removed, have %v and %v", mock.stopped, mock.removed)
+	}
+}
+
+func TestExec(t *testing.T) {
+	// A streaming server which echoes stdin until it reads "exit"
+	upgrader := websocket.Upgrader{Subprotocols: []string{"v4.channel.k8s.io"}}
+	resized := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			switch {
+			case msg[0] == 4:
+				resized <- string(msg[1:])
+			case string(msg[1:]) == "exit":
+				conn.WriteMessage(websocket.BinaryMessage, append([]byte{3}, `{"status":"Success"}`...))
+				return
+			default:
+				conn.WriteMessage(websocket.BinaryMessage, append([]byte{1}, msg[1:]...))
+			}
+		}
+	}))
+	defer server.Close()
+
+	oldNewPipe := controls.NewPipe
+	defer func() { controls.NewPipe = oldNewPipe }()
+	pipe := xfer.NewPipe()
+	controls.NewPipe = func(_ controls.PipeClient, _ string) (string, xfer.Pipe, error) {
+		return "pipeid", pipe, nil
+	}
+
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := cri.NewReporter(&mockControlsClient{execURL: server.URL + "/exec/token"}, nil, "probe-id", hr)
+	defer reporter.Stop()
+
+	resp := hr.HandleControlRequest(xfer.Request{Control: cri.ExecContainer, NodeID: report.MakeContainerNodeID("ping")})
+	if want := (xfer.Response{Pipe: "pipeid", RawTTY: true, ResizeTTYControl: cri.ResizeExecTTY}); !reflect.DeepEqual(resp, want) {
+		t.Fatalf("want %v, have %v", want, resp)
+	}
+
+	resp = hr.HandleControlRequest(xfer.Request{
+		Control:     cri.ResizeExecTTY,
+		NodeID:      "pipeid",
+		ControlArgs: map[string]string{"pipeID": "pipeid", "height": "24", "width": "80"},
+	})
+	if resp.Error != "" {
+		t.Fatal(resp.Error)
+	}
+	if have, want := <-resized, `{"Width":80,"Height":24}`; have != want {
+		t.Errorf("want resize %s, have %s", want, have)
+	}
+
+	_, remote := pipe.Ends()
+	if _, err := remote.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(remote, buf); err != nil {
+		t.Fatal(err)
+	}
+	if have := string(buf); have != "hello" {
+		t.Errorf("want echo hello, have %q", have)
+	}
+
+	if _, err := remote.Write([]byte("exit")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Read(buf); err == nil || !pipe.Closed() {
+		t.Errorf("want the pipe closed when the command exits, have %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package cri
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// The CRI Exec call only prepares a session: it returns the URL of the
+// runtime's streaming server, which speaks the Kubernetes remote command
+// protocol. We use its websocket flavour, in which each message starts with
+// the number of the stream it carries.
+const (
+	execProtocol = "v4.channel.k8s.io"
+
+	stdinChannel  = 0
+	stdoutChannel = 1
+	stderrChannel = 2
+	errorChannel  = 3
+	resizeChannel = 4
+
+	execHandshakeTimeout = 10 * time.Second
+)
+
+type execSession struct {
+	sync.Mutex // serialises writes, and guards closed
+	conn       *websocket.Conn
+	closed     bool
+}
+
+func dialExec(rawurl string) (*execSession, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return nil, fmt.Errorf("unsupported exec URL %q", rawurl)
+	}
+	dialer := websocket.Dialer{
+		Subprotocols:     []string{execProtocol},
+		HandshakeTimeout: execHandshakeTimeout,
+	}
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &execSession{conn: conn}, nil
+}
+
+func (s *execSession) write(channel byte, data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.conn.WriteMessage(websocket.BinaryMessage, append([]byte{channel}, data...))
+}
+
+// resize tells the runtime the terminal is now height rows by width columns.
+func (s *execSession) resize(height, width uint) error {
+	size, err := json.Marshal(struct{ Width, Height uint16 }{uint16(width), uint16(height)})
+	if err != nil {
+		return err
+	}
+	return s.write(resizeChannel, size)
+}
+
+// copy sends what is read from local to the command's stdin, and the
+// command's output to local, until the command exits or the session is
+// closed. It returns an error if the command failed.
+func (s *execSession) copy(local io.ReadWriter) error {
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := local.Read(buf)
+			if n > 0 {
+				if err := s.write(stdinChannel, buf[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		_, msg, err := s.conn.ReadMessage()
+		if err != nil {
+			s.Lock()
+			closed := s.closed
+			s.Unlock()
+			if closed || websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return err
+		}
+		if len(msg) < 2 {
+			continue
+		}
+		switch msg[0] {
+		case stdoutChannel, stderrChannel:
+			if _, err := local.Write(msg[1:]); err != nil {
+				return err
+			}
+		case errorChannel:
+			var status struct{ Status, Message string }
+			if err := json.Unmarshal(msg[1:], &status); err != nil {
+				return err
+			}
+			if status.Status != "Success" {
+				return errors.New(status.Message)
+			}
+			return nil
+		}
+	}
+}
+
+func (s *execSession) close() {
+	s.Lock()
+	s.closed = true
+	s.Unlock()
+	s.conn.Close()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"os"
 	"time"
 
 	"google.golang.org/grpc"
@@ -16,6 +17,28 @@ const (
 	tcpProtocol  = "tcp"
 )
 
+// KnownEndpoints are the CRI sockets of common runtimes, in the order
+// FindEndpoint tries them.
+var KnownEndpoints = []string{
+	"unix:///run/containerd/containerd.sock",
+	"unix:///var/run/crio/crio.sock",
+	"unix:///var/run/cri-dockerd.sock",
+}
+
+// FindEndpoint returns the first of KnownEndpoints whose socket exists.
+func FindEndpoint() (string, bool) {
+	for _, endpoint := range KnownEndpoints {
+		addr, err := parseEndpointWithFallbackProtocol(endpoint, unixProtocol)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(addr); err == nil {
+			return endpoint, true
+		}
+	}
+	return "", false
+}
+
 func dial(addr string, timeout time.Duration) (net.Conn, error) {
 	return net.DialTimeout(unixProtocol, addr, timeout)
 }
@@ -3,57 +3,129 @@ package cri
 import (
 	"context"
 	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 
+	log "github.com/sirupsen/logrus"
 	client "github.com/weaveworks/scope/cri/runtime"
+	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/report"
 )
 
 // Reporter generate Reports containing Container and ContainerImage topologies
 type Reporter struct {
-	cri client.RuntimeServiceClient
+	sync.Mutex
+	cri             client.RuntimeServiceClient
+	pipes           controls.PipeClient
+	probeID         string
+	handlerRegistry *controls.HandlerRegistry
+	pipeIDToExec    map[string]*execSession
+	cpuUsage        map[string]*client.CpuUsage
 }
 
 // NewReporter makes a new Reporter
-func NewReporter(cri client.RuntimeServiceClient) *Reporter {
+func NewReporter(cri client.RuntimeServiceClient, pipes controls.PipeClient, probeID string, handlerRegistry *controls.HandlerRegistry) *Reporter {
 	reporter := &Reporter{
-		cri: cri,
+		cri:             cri,
+		pipes:           pipes,
+		probeID:         probeID,
+		handlerRegistry: handlerRegistry,
+		pipeIDToExec:    map[string]*execSession{},
+		cpuUsage:        map[string]*client.CpuUsage{},
 	}
+	reporter.registerControls()
 
 	return reporter
 }
 
+// Stop unregisters controls.
+func (r *Reporter) Stop() {
+	r.deregisterControls()
+}
+
 // Name of this reporter, for metrics gathering
-func (Reporter) Name() string { return "CRI" }
+func (*Reporter) Name() string { return "CRI" }
 
 // Report generates a Report containing Container topologies
 func (r *Reporter) Report() (report.Report, error) {
 	result := report.MakeReport()
-	containerTopol, err := r.containerTopology()
+	ctx := context.Background()
+	resp, err := r.cri.ListContainers(ctx, &client.ListContainersRequest{})
 	if err != nil {
 		return report.MakeReport(), err
 	}
 
-	result.Container = result.Container.Merge(containerTopol)
+	// Not all runtimes implement stats, so carry on without them
+	var stats []*client.ContainerStats
+	statsResp, err := r.cri.ListContainerStats(ctx, &client.ListContainerStatsRequest{})
+	if err != nil {
+		log.Debugf("CRI: failed to list container stats: %v", err)
+	} else {
+		stats = statsResp.Stats
+	}
+
+	// CPU usage is cumulative, so keep it to work out the next percentage
+	r.Lock()
+	previous := r.cpuUsage
+	r.cpuUsage = map[string]*client.CpuUsage{}
+	for _, s := range stats {
+		if s.Attributes != nil && s.Cpu != nil {
+			r.cpuUsage[s.Attributes.Id] = s.Cpu
+		}
+	}
+	r.Unlock()
+
+	result.Container = result.Container.Merge(containerTopology(resp.Containers, stats, previous, r.probeID))
+	result.ContainerImage = result.ContainerImage.Merge(containerImageTopology(resp.Containers))
 	return result, nil
 }
 
-func (r *Reporter) containerTopology() (report.Topology, error) {
+func containerTopology(containers []*client.Container, stats []*client.ContainerStats, previous map[string]*client.CpuUsage, probeID string) report.Topology {
 	result := report.MakeTopology().
-		WithMetadataTemplates(docker.ContainerImageMetadataTemplates).
-		WithTableTemplates(docker.ContainerImageTableTemplates)
+		WithMetadataTemplates(docker.ContainerMetadataTemplates).
+		WithMetricTemplates(docker.ContainerMetricTemplates).
+		WithTableTemplates(docker.ContainerTableTemplates)
+	result.Controls.AddControls(ContainerControls)
 
-	ctx := context.Background()
-	resp, err := r.cri.ListContainers(ctx, &client.ListContainersRequest{})
-	if err != nil {
-		return result, err
+	metrics := map[string]report.Metrics{}
+	for _, s := range stats {
+		if s.Attributes != nil {
+			metrics[s.Attributes.Id] = getMetrics(s, previous[s.Attributes.Id])
+		}
+	}
+	for _, c := range containers {
+		node := getNode(c).WithLatests(map[string]string{report.ControlProbeID: probeID})
+		if m, ok := metrics[c.Id]; ok {
+			node = node.WithMetrics(m)
+		}
+		result.AddNode(node)
 	}
 
-	for _, c := range resp.Containers {
-		result.AddNode(getNode(c))
+	return result
+}
+
+// containerImageTopology derives the images from the containers using them,
+// since runtimes such as containerd have no Docker image API to list them.
+func containerImageTopology(containers []*client.Container) report.Topology {
+	result := report.MakeTopology().
+		WithMetadataTemplates(docker.ContainerImageMetadataTemplates).
+		WithTableTemplates(docker.ContainerImageTableTemplates)
+
+	for _, c := range containers {
+		latests := map[string]string{
+			docker.ImageID: c.ImageRef,
+		}
+		if c.Image != nil && c.Image.Image != "" {
+			latests[docker.ImageName] = docker.ImageNameWithoutTag(c.Image.Image)
+			latests[docker.ImageTag] = docker.ImageNameTag(c.Image.Image)
+		}
+		result.AddNode(report.MakeNodeWith(report.MakeContainerImageNodeID(c.ImageRef), latests))
 	}
 
-	return result, nil
+	return result
 }
 
 func getNode(c *client.Container) report.Node {
@@ -61,6 +133,8 @@ func getNode(c *client.Container) report.Node {
 		docker.ContainerName:         c.Metadata.Name,
 		docker.ContainerID:           c.Id,
 		docker.ContainerState:        fmt.Sprintf("%v", c.State),
+		docker.ContainerStateHuman:   humanState(c.State),
+		docker.ContainerCreated:      time.Unix(0, c.CreatedAt).Format(time.RFC3339Nano),
 		docker.ContainerRestartCount: fmt.Sprintf("%v", c.Metadata.Attempt),
 		docker.ImageID:               c.ImageRef,
 		docker.ImageName:             c.Image.Image,
@@ -68,6 +142,34 @@ func getNode(c *client.Container) report.Node {
 		Add(report.ContainerImage, report.MakeStringSet(report.MakeContainerImageNodeID(c.ImageRef))),
 	)
 	result = result.AddPrefixPropertyList(docker.LabelPrefix, c.Labels)
+	result = result.WithLatestActiveControls(activeControls(c.State)...)
 
 	return result
 }
+
+// humanState turns e.g. CONTAINER_RUNNING into "running", to match the
+// states the Docker probe reports.
+func humanState(state client.ContainerState) string {
+	return strings.ToLower(strings.TrimPrefix(state.String(), "CONTAINER_"))
+}
+
+// getMetrics reports the working set memory of a container and, given the
+// previous CPU usage sample, its CPU usage since then. As with Docker, CPU
+// usage is a percentage of all the host's CPUs.
+func getMetrics(s *client.ContainerStats, previous *client.CpuUsage) report.Metrics {
+	metrics := report.Metrics{}
+	if s.Memory != nil && s.Memory.WorkingSetBytes != nil {
+		metrics[docker.MemoryUsage] = report.MakeSingletonMetric(time.Unix(0, s.Memory.Timestamp), float64(s.Memory.WorkingSetBytes.Value))
+	}
+	if cpu := s.Cpu; cpu != nil && cpu.UsageCoreNanoSeconds != nil &&
+		previous != nil && previous.UsageCoreNanoSeconds != nil &&
+		cpu.Timestamp > previous.Timestamp && cpu.UsageCoreNanoSeconds.Value >= previous.UsageCoreNanoSeconds.Value {
+		usage := float64(cpu.UsageCoreNanoSeconds.Value - previous.UsageCoreNanoSeconds.Value)
+		elapsed := float64(cpu.Timestamp-previous.Timestamp) * float64(runtime.NumCPU())
+		metrics[docker.CPUTotalUsage] = report.MakeMetric([]report.Sample{{
+			Timestamp: time.Unix(0, cpu.Timestamp),
+			Value:     usage / elapsed * 100.0,
+		}}).WithMax(100.0)
+	}
+	return metrics
+}
@@ -0,0 +1,190 @@
+package cri_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	client "github.com/weaveworks/scope/cri/runtime"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/cri"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+)
+
+type mockCRIClient struct {
+	client.RuntimeServiceClient
+	containers []*client.Container
+	stats      []*client.ContainerStats
+}
+
+func (m mockCRIClient) ListContainers(context.Context, *client.ListContainersRequest, ...grpc.CallOption) (*client.ListContainersResponse, error) {
+	return &client.ListContainersResponse{Containers: m.containers}, nil
+}
+
+func (m mockCRIClient) ListContainerStats(context.Context, *client.ListContainerStatsRequest, ...grpc.CallOption) (*client.ListContainerStatsResponse, error) {
+	if m.stats == nil {
+		return nil, errors.New("not implemented")
+	}
+	return &client.ListContainerStatsResponse{Stats: m.stats}, nil
+}
+
+func TestReporter(t *testing.T) {
+	const imageRef = "sha256:4e1b5c96d7b4"
+	mock := mockCRIClient{containers: []*client.Container{
+		{
+			Id:       "ping",
+			Metadata: &client.ContainerMetadata{Name: "pinger"},
+			Image:    &client.ImageSpec{Image: "docker.io/library/busybox:1.36"},
+			ImageRef: imageRef,
+			State:    client.ContainerState_CONTAINER_RUNNING,
+		},
+		{
+			Id:       "pong",
+			Metadata: &client.ContainerMetadata{Name: "ponger"},
+			Image:    &client.ImageSpec{Image: "docker.io/library/busybox:1.36"},
+			ImageRef: imageRef,
+			State:    client.ContainerState_CONTAINER_EXITED,
+		},
+	}}
+
+	rpt, err := cri.NewReporter(mock, nil, "probe-id", controls.NewDefaultHandlerRegistry()).Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have := len(rpt.Container.Nodes); have != 2 {
+		t.Errorf("want 2 containers, have %d", have)
+	}
+	container, ok := rpt.Container.Nodes[report.MakeContainerNodeID("ping")]
+	if !ok {
+		t.Fatalf("container not found")
+	}
+	if name, _ := container.Latest.Lookup(docker.ContainerName); name != "pinger" {
+		t.Errorf("want container name pinger, have %q", name)
+	}
+	if have, want := container.ActiveControls(), []string{cri.StopContainer, cri.ExecContainer}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want controls %v, have %v", want, have)
+	}
+	if probeID, _ := container.Latest.Lookup(report.ControlProbeID); probeID != "probe-id" {
+		t.Errorf("want control probe probe-id, have %q", probeID)
+	}
+
+	if have := len(rpt.ContainerImage.Nodes); have != 1 {
+		t.Errorf("want 1 image, have %d", have)
+	}
+	image, ok := rpt.ContainerImage.Nodes[report.MakeContainerImageNodeID(imageRef)]
+	if !ok {
+		t.Fatalf("image not found")
+	}
+	for key, want := range map[string]string{
+		docker.ImageID:   imageRef,
+		docker.ImageName: "library/busybox",
+		docker.ImageTag:  "1.36",
+	} {
+		if have, _ := image.Latest.Lookup(key); have != want {
+			t.Errorf("%s: want %q, have %q", key, want, have)
+		}
+	}
+}
+
+func TestReporterStats(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock := mockCRIClient{
+		containers: []*client.Container{{
+			Id:        "ping",
+			Metadata:  &client.ContainerMetadata{Name: "pinger"},
+			Image:     &client.ImageSpec{Image: "busybox"},
+			ImageRef:  "sha256:4e1b5c96d7b4",
+			State:     client.ContainerState_CONTAINER_RUNNING,
+			CreatedAt: created.UnixNano(),
+		}},
+		stats: []*client.ContainerStats{{
+			Attributes: &client.ContainerAttributes{Id: "ping"},
+			Memory: &client.MemoryUsage{
+				Timestamp:       created.UnixNano(),
+				WorkingSetBytes: &client.UInt64Value{Value: 1 << 20},
+			},
+		}},
+	}
+
+	rpt, err := cri.NewReporter(mock, nil, "probe-id", controls.NewDefaultHandlerRegistry()).Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	container, ok := rpt.Container.Nodes[report.MakeContainerNodeID("ping")]
+	if !ok {
+		t.Fatalf("container not found")
+	}
+	for key, want := range map[string]string{
+		docker.ContainerStateHuman: "running",
+		docker.ContainerCreated:    created.Format(time.RFC3339Nano),
+	} {
+		if have, _ := container.Latest.Lookup(key); have != want {
+			t.Errorf("%s: want %q, have %q", key, want, have)
+		}
+	}
+	if metric, ok := container.Metrics[docker.MemoryUsage]; !ok || metric.Max != 1<<20 {
+		t.Errorf("want memory usage of 1MiB, have %v (%v)", metric, ok)
+	}
+
+	// Runtimes without stats still report containers
+	mock.stats = nil
+	rpt, err = cri.NewReporter(mock, nil, "probe-id", controls.NewDefaultHandlerRegistry()).Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rpt.Container.Nodes[report.MakeContainerNodeID("ping")].Metrics[docker.MemoryUsage]; ok {
+		t.Errorf("unexpected memory usage without stats")
+	}
+}
+
+func TestReporterCPU(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cpu := &client.CpuUsage{Timestamp: start.UnixNano(), UsageCoreNanoSeconds: &client.UInt64Value{Value: 1e9}}
+	mock := mockCRIClient{
+		containers: []*client.Container{{
+			Id:       "ping",
+			Metadata: &client.ContainerMetadata{Name: "pinger"},
+			Image:    &client.ImageSpec{Image: "busybox"},
+			State:    client.ContainerState_CONTAINER_RUNNING,
+		}},
+		stats: []*client.ContainerStats{{
+			Attributes: &client.ContainerAttributes{Id: "ping"},
+			Cpu:        cpu,
+		}},
+	}
+	reporter := cri.NewReporter(mock, nil, "probe-id", controls.NewDefaultHandlerRegistry())
+	defer reporter.Stop()
+
+	// CPU usage needs two samples
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rpt.Container.Nodes[report.MakeContainerNodeID("ping")].Metrics[docker.CPUTotalUsage]; ok {
+		t.Errorf("unexpected CPU usage from one sample")
+	}
+
+	// Half of every CPU for a second
+	mock.stats[0].Cpu = &client.CpuUsage{
+		Timestamp:            start.Add(time.Second).UnixNano(),
+		UsageCoreNanoSeconds: &client.UInt64Value{Value: 1e9 + uint64(runtime.NumCPU())*5e8},
+	}
+	rpt, err = reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metric, ok := rpt.Container.Nodes[report.MakeContainerNodeID("ping")].Metrics[docker.CPUTotalUsage]
+	if !ok {
+		t.Fatalf("CPU usage not found")
+	}
+	if have, ok := metric.LastSample(); !ok || have.Value != 50 {
+		t.Errorf("want 50%% CPU usage, have %v", have.Value)
+	}
+}
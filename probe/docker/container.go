@@ -395,9 +395,9 @@ func (c *container) controls() []string {
 	case c.container.State.Paused:
 		return []string{UnpauseContainer}
 	case c.container.State.Running:
-		return []string{RestartContainer, StopContainer, PauseContainer, AttachContainer, ExecContainer}
+		return []string{RestartContainer, StopContainer, PauseContainer, AttachContainer, ExecContainer, LogsContainer}
 	default:
-		return []string{StartContainer, RemoveContainer}
+		return []string{StartContainer, RemoveContainer, LogsContainer}
 	}
 }
 
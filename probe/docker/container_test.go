@@ -66,6 +66,7 @@ func TestContainer(t *testing.T) {
 			docker.PauseContainer,
 			docker.AttachContainer,
 			docker.ExecContainer,
+			docker.LogsContainer,
 		}
 		want := report.MakeNodeWith("ping;<container>", map[string]string{
 			"docker_container_command":     "ping foo.bar.local",
@@ -1,6 +1,11 @@
 package docker
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strconv"
+
 	docker_client "github.com/fsouza/go-dockerclient"
 
 	log "github.com/sirupsen/logrus"
@@ -20,7 +25,9 @@ const (
 	RemoveContainer  = report.DockerRemoveContainer
 	AttachContainer  = report.DockerAttachContainer
 	ExecContainer    = report.DockerExecContainer
+	LogsContainer    = report.DockerLogsContainer
 	ResizeExecTTY    = "docker_resize_exec_tty"
+	ResizeAttachTTY  = "docker_resize_attach_tty"
 
 	waitTime = 10
 )
@@ -89,7 +96,16 @@ func (r *registry) attachContainer(containerID string, req xfer.Request) xfer.Re
 		pipe.Close()
 		return xfer.ResponseError(err)
 	}
+	if hasTTY {
+		r.Lock()
+		r.pipeIDToContainerID[id] = containerID
+		r.Unlock()
+	}
+
 	pipe.OnClose(func() {
+		r.Lock()
+		delete(r.pipeIDToContainerID, id)
+		r.Unlock()
 		if err := cw.Close(); err != nil {
 			log.Errorf("Error closing attachment to container %s: %v", containerID, err)
 			return
@@ -101,10 +117,14 @@ func (r *registry) attachContainer(containerID string, req xfer.Request) xfer.Re
 		}
 		pipe.Close()
 	}()
-	return xfer.Response{
+	resp := xfer.Response{
 		Pipe:   id,
 		RawTTY: hasTTY,
 	}
+	if hasTTY {
+		resp.ResizeTTYControl = ResizeAttachTTY
+	}
+	return resp
 }
 
 func (r *registry) execContainer(containerID string, req xfer.Request) xfer.Response {
@@ -143,13 +163,13 @@ func (r *registry) execContainer(containerID string, req xfer.Request) xfer.Resp
 	r.Unlock()
 
 	pipe.OnClose(func() {
+		r.Lock()
+		delete(r.pipeIDToexecID, id)
+		r.Unlock()
 		if err := cw.Close(); err != nil {
 			log.Errorf("Error closing exec in container %s: %v", containerID, err)
 			return
 		}
-		r.Lock()
-		delete(r.pipeIDToexecID, id)
-		r.Unlock()
 	})
 	go func() {
 		if err := cw.Wait(); err != nil {
@@ -164,6 +184,52 @@ func (r *registry) execContainer(containerID string, req xfer.Request) xfer.Resp
 	}
 }
 
+func (r *registry) logsContainer(containerID string, req xfer.Request) xfer.Response {
+	c, ok := r.GetContainer(containerID)
+	if !ok {
+		return xfer.ResponseErrorf("Not found: %s", containerID)
+	}
+
+	tail := "all"
+	if t, ok := req.ControlArgs["tail"]; ok {
+		if _, err := strconv.ParseUint(t, 10, 32); err != nil {
+			return xfer.ResponseErrorf("Bad parameter: tail (%q): %v", t, err)
+		}
+		tail = t
+	}
+
+	id, pipe, err := controls.NewPipe(r.pipes, req.AppID)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	local, _ := pipe.Ends()
+	ctx, cancel := context.WithCancel(context.Background())
+	pipe.OnClose(cancel)
+	// Logs are read-only, so throw away anything typed into the terminal
+	go io.Copy(ioutil.Discard, local)
+	go func() {
+		err := r.client.Logs(docker_client.LogsOptions{
+			Context:      ctx,
+			Container:    containerID,
+			OutputStream: local,
+			ErrorStream:  local,
+			Tail:         tail,
+			Follow:       true,
+			Stdout:       true,
+			Stderr:       true,
+			Timestamps:   true,
+			RawTerminal:  c.HasTTY(),
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("Error getting logs of container %s: %v", containerID, err)
+		}
+		pipe.Close()
+	}()
+	return xfer.Response{
+		Pipe: id,
+	}
+}
+
 func (r *registry) resizeExecTTY(pipeID string, height, width uint) xfer.Response {
 	r.Lock()
 	execID, ok := r.pipeIDToexecID[pipeID]
@@ -182,6 +248,24 @@ func (r *registry) resizeExecTTY(pipeID string, height, width uint) xfer.Respons
 	return xfer.Response{}
 }
 
+func (r *registry) resizeAttachTTY(pipeID string, height, width uint) xfer.Response {
+	r.Lock()
+	containerID, ok := r.pipeIDToContainerID[pipeID]
+	r.Unlock()
+
+	if !ok {
+		return xfer.ResponseErrorf("Unknown pipeID (%q)", pipeID)
+	}
+
+	if err := r.client.ResizeContainerTTY(containerID, int(height), int(width)); err != nil {
+		return xfer.ResponseErrorf(
+			"Error setting terminal size (%d, %d) of pipe %s: %v",
+			height, width, pipeID, err)
+	}
+
+	return xfer.Response{}
+}
+
 func captureContainerID(f func(string, xfer.Request) xfer.Response) func(xfer.Request) xfer.Response {
 	return func(req xfer.Request) xfer.Response {
 		containerID, ok := report.ParseContainerNodeID(req.NodeID)
@@ -202,7 +286,9 @@ func (r *registry) registerControls() {
 		RemoveContainer:  captureContainerID(r.removeContainer),
 		AttachContainer:  captureContainerID(r.attachContainer),
 		ExecContainer:    captureContainerID(r.execContainer),
+		LogsContainer:    captureContainerID(r.logsContainer),
 		ResizeExecTTY:    xfer.ResizeTTYControlWrapper(r.resizeExecTTY),
+		ResizeAttachTTY:  xfer.ResizeTTYControlWrapper(r.resizeAttachTTY),
 	}
 	r.handlerRegistry.Batch(nil, controls)
 }
@@ -217,7 +303,9 @@ func (r *registry) deregisterControls() {
 		RemoveContainer,
 		AttachContainer,
 		ExecContainer,
+		LogsContainer,
 		ResizeExecTTY,
+		ResizeAttachTTY,
 	}
 	r.handlerRegistry.Batch(controls, nil)
 }
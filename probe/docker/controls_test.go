@@ -2,7 +2,9 @@ package docker_test
 
 import (
 	"io"
+	"io/ioutil"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -46,7 +48,12 @@ func TestControls(t *testing.T) {
 
 type mockPipe struct{}
 
-func (mockPipe) Ends() (io.ReadWriter, io.ReadWriter)                        { return nil, nil }
+var mockEnd = struct {
+	io.Reader
+	io.Writer
+}{strings.NewReader(""), ioutil.Discard}
+
+func (mockPipe) Ends() (io.ReadWriter, io.ReadWriter)                        { return mockEnd, mockEnd }
 func (mockPipe) CopyToWebsocket(io.ReadWriter, xfer.Websocket) (bool, error) { return true, nil }
 func (mockPipe) Close() error                                                { return nil }
 func (mockPipe) Closed() bool                                                { return false }
@@ -80,8 +87,9 @@ func TestPipes(t *testing.T) {
 			{
 				control: docker.AttachContainer,
 				response: xfer.Response{
-					Pipe:   "pipeid",
-					RawTTY: true,
+					Pipe:             "pipeid",
+					RawTTY:           true,
+					ResizeTTYControl: docker.ResizeAttachTTY,
 				},
 			},
 
@@ -93,6 +101,13 @@ func TestPipes(t *testing.T) {
 					ResizeTTYControl: docker.ResizeExecTTY,
 				},
 			},
+
+			{
+				control: docker.LogsContainer,
+				response: xfer.Response{
+					Pipe: "pipeid",
+				},
+			},
 		} {
 			result := hr.HandleControlRequest(xfer.Request{
 				Control: want.control,
@@ -102,6 +117,15 @@ func TestPipes(t *testing.T) {
 				t.Errorf("diff %s: %s", want.control, commonTest.Diff(want, result))
 			}
 		}
+
+		// The attached pipe's terminal is resized on the container
+		result := hr.HandleControlRequest(xfer.Request{
+			Control:     docker.ResizeAttachTTY,
+			ControlArgs: map[string]string{"pipeID": "pipeid", "height": "24", "width": "80"},
+		})
+		if want := "Error setting terminal size (24, 80) of pipe pipeid: resizeContainerTTY"; result.Error != want {
+			t.Errorf("want %q, have %q", want, result.Error)
+		}
 	})
 }
 
@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -65,6 +67,8 @@ type registry struct {
 	images          map[string]docker_client.APIImages
 	networks        []docker_client.Network
 	pipeIDToexecID  map[string]string
+	// pipes attached to containers with a TTY, which can be resized
+	pipeIDToContainerID map[string]string
 }
 
 // Client interface for mocking.
@@ -87,6 +91,8 @@ type Client interface {
 	StartExecNonBlocking(string, docker_client.StartExecOptions) (docker_client.CloseWaiter, error)
 	Stats(docker_client.StatsOptions) error
 	ResizeExecTTY(id string, height, width int) error
+	ResizeContainerTTY(id string, height, width int) error
+	Logs(docker_client.LogsOptions) error
 }
 
 func newDockerClient(endpoint string) (Client, error) {
@@ -96,6 +102,26 @@ func newDockerClient(endpoint string) (Client, error) {
 	return docker_client.NewClient(endpoint)
 }
 
+// defaultEndpoint is where the Docker client looks when DOCKER_HOST is unset.
+const defaultEndpoint = "unix:///var/run/docker.sock"
+
+// SocketMissing says whether endpoint, or DOCKER_HOST if endpoint is empty,
+// names a unix socket which doesn't exist, so no Docker daemon can be
+// listening on it.
+func SocketMissing(endpoint string) bool {
+	if endpoint == "" {
+		endpoint = os.Getenv("DOCKER_HOST")
+	}
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	if !strings.HasPrefix(endpoint, "unix://") {
+		return false
+	}
+	_, err := os.Stat(strings.TrimPrefix(endpoint, "unix://"))
+	return os.IsNotExist(err)
+}
+
 // RegistryOptions are used to initialize the Registry
 type RegistryOptions struct {
 	Interval               time.Duration
@@ -116,10 +142,11 @@ func NewRegistry(options RegistryOptions) (Registry, error) {
 	}
 
 	r := &registry{
-		containers:      radix.New(),
-		containersByPID: map[int]Container{},
-		images:          map[string]docker_client.APIImages{},
-		pipeIDToexecID:  map[string]string{},
+		containers:          radix.New(),
+		containersByPID:     map[int]Container{},
+		images:              map[string]docker_client.APIImages{},
+		pipeIDToexecID:      map[string]string{},
+		pipeIDToContainerID: map[string]string{},
 
 		client:                 client,
 		pipes:                  options.Pipes,
@@ -2,7 +2,10 @@ package docker_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"sync"
@@ -174,6 +177,14 @@ func (m *mockDockerClient) ResizeExecTTY(id string, height, width int) error {
 	return fmt.Errorf("resizeExecTTY")
 }
 
+func (m *mockDockerClient) ResizeContainerTTY(id string, height, width int) error {
+	return fmt.Errorf("resizeContainerTTY")
+}
+
+func (m *mockDockerClient) Logs(_ client.LogsOptions) error {
+	return fmt.Errorf("logs")
+}
+
 type mockCloseWaiter struct{}
 
 func (mockCloseWaiter) Close() error { return nil }
@@ -516,3 +527,25 @@ func TestRegistryDelete(t *testing.T) {
 		}
 	})
 }
+
+func TestSocketMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	if err := ioutil.WriteFile(socket, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for endpoint, want := range map[string]bool{
+		"unix://" + socket:                        false,
+		"unix://" + filepath.Join(dir, "missing"): true,
+		"tcp://127.0.0.1:2375":                    false,
+	} {
+		if have := docker.SocketMissing(endpoint); have != want {
+			t.Errorf("%s: want %v, have %v", endpoint, want, have)
+		}
+	}
+}
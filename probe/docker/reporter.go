@@ -101,41 +101,47 @@ var (
 			Icon:  "fa fa-terminal",
 			Rank:  2,
 		},
+		{
+			ID:    LogsContainer,
+			Human: "Logs",
+			Icon:  "fa fa-align-left",
+			Rank:  3,
+		},
 		{
 			ID:    StartContainer,
 			Human: "Start",
 			Icon:  "fa fa-play",
-			Rank:  3,
+			Rank:  4,
 		},
 		{
 			ID:    RestartContainer,
 			Human: "Restart",
 			Icon:  "fa fa-redo",
-			Rank:  4,
+			Rank:  5,
 		},
 		{
 			ID:    PauseContainer,
 			Human: "Pause",
 			Icon:  "fa fa-pause",
-			Rank:  5,
+			Rank:  6,
 		},
 		{
 			ID:    UnpauseContainer,
 			Human: "Unpause",
 			Icon:  "fa fa-play",
-			Rank:  6,
+			Rank:  7,
 		},
 		{
 			ID:    StopContainer,
 			Human: "Stop",
 			Icon:  "fa fa-stop",
-			Rank:  7,
+			Rank:  8,
 		},
 		{
 			ID:    RemoveContainer,
 			Human: "Remove",
 			Icon:  "far fa-trash-alt",
-			Rank:  8,
+			Rank:  9,
 		},
 	}
 
@@ -38,7 +38,7 @@ type DNSSnooper struct {
 
 // NewDNSSnooper creates a new snooper of DNS queries
 func NewDNSSnooper() (*DNSSnooper, error) {
-	pcapHandle, err := newPcapHandle()
+	pcapHandle, err := newPcapHandle("inbound and port 53", pcap.DirectionIn)
 	if err != nil {
 		return nil, err
 	}
@@ -54,7 +54,7 @@ func NewDNSSnooper() (*DNSSnooper, error) {
 	return s, nil
 }
 
-func newPcapHandle() (*pcap.Handle, error) {
+func newPcapHandle(bpfFilter string, direction pcap.Direction) (*pcap.Handle, error) {
 	inactive, err := pcap.NewInactiveHandle("any")
 	if err != nil {
 		return nil, err
@@ -86,11 +86,11 @@ func newPcapHandle() (*pcap.Handle, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := pcapHandle.SetDirection(pcap.DirectionIn); err != nil {
+	if err := pcapHandle.SetDirection(direction); err != nil {
 		pcapHandle.Close()
 		return nil, err
 	}
-	if err := pcapHandle.SetBPFFilter("inbound and port 53"); err != nil {
+	if err := pcapHandle.SetBPFFilter(bpfFilter); err != nil {
 		pcapHandle.Close()
 		return nil, err
 	}
@@ -0,0 +1,120 @@
+//go:build (linux && amd64) || (linux && ppc64le)
+// +build linux,amd64 linux,ppc64le
+
+package endpoint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// HTTPSnooper samples plain HTTP/1.x traffic on some ports, to report the
+// request rate, status codes and latencies of the servers on them. Only
+// traffic over IPv4 is seen, as with the rest of the endpoint reporter's
+// connection tracking.
+type HTTPSnooper struct {
+	stop       chan struct{}
+	pcapHandle *pcap.Handle
+	tracker    *httpTracker
+}
+
+// NewHTTPSnooper creates a new snooper of HTTP traffic to and from ports.
+func NewHTTPSnooper(ports []uint16) (*HTTPSnooper, error) {
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports to snoop HTTP traffic on")
+	}
+	filters := make([]string, 0, len(ports))
+	for _, port := range ports {
+		filters = append(filters, fmt.Sprintf("port %d", port))
+	}
+	// Only look at segments with a payload
+	filter := fmt.Sprintf("tcp and (%s) and (((ip[2:2] - ((ip[0]&0xf)<<2)) - ((tcp[12]&0xf0)>>2)) != 0)", strings.Join(filters, " or "))
+	pcapHandle, err := newPcapHandle(filter, pcap.DirectionInOut)
+	if err != nil {
+		return nil, err
+	}
+	s := &HTTPSnooper{
+		stop:       make(chan struct{}),
+		pcapHandle: pcapHandle,
+		tracker:    newHTTPTracker(mtime.Now()),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Report adds the HTTP stats gathered since the last report to rpt.
+func (s *HTTPSnooper) Report(rpt *report.Report, hostID string) {
+	if s != nil {
+		s.tracker.report(rpt, hostID, mtime.Now())
+	}
+}
+
+// Stop makes the snooper stop inspecting HTTP traffic
+func (s *HTTPSnooper) Stop() {
+	if s != nil {
+		close(s.stop)
+	}
+}
+
+func (s *HTTPSnooper) run() {
+	var (
+		decodedLayers []gopacket.LayerType
+		payload       gopacket.Payload
+		tcp           layers.TCP
+		ip4           layers.IPv4
+		ip6           layers.IPv6
+		eth           layers.Ethernet
+		dot1q         layers.Dot1Q
+		sll           layers.LinuxSLL
+	)
+
+	// assumes that the "any" interface is being used (see https://wiki.wireshark.org/SLL)
+	packetParser := gopacket.NewDecodingLayerParser(layers.LayerTypeLinuxSLL, &sll, &dot1q, &eth, &ip4, &ip6, &tcp, &payload)
+	packetParser.IgnoreUnsupported = true
+
+	for {
+		select {
+		case <-s.stop:
+			s.pcapHandle.Close()
+			return
+		default:
+		}
+
+		packet, ci, err := s.pcapHandle.ZeroCopyReadPacketData()
+		if err != nil {
+			// TimeoutExpired is acceptable due to the Timeout black magic
+			// on the handle.
+			if err != pcap.NextErrorTimeoutExpired {
+				log.Errorf("HTTPSnooper: error reading packet data: %s", err)
+			}
+			continue
+		}
+
+		if err := packetParser.DecodeLayers(packet, &decodedLayers); err != nil {
+			continue
+		}
+		var isIPv4, isTCP bool
+		for _, layerType := range decodedLayers {
+			switch layerType {
+			case layers.LayerTypeIPv4:
+				isIPv4 = true
+			case layers.LayerTypeTCP:
+				isTCP = true
+			}
+		}
+		// fourTuple only holds IPv4 addresses
+		if !isIPv4 || !isTCP || len(tcp.Payload) == 0 {
+			continue
+		}
+		tuple := makeFourTuple(ip4.SrcIP, ip4.DstIP, uint16(tcp.SrcPort), uint16(tcp.DstPort))
+		s.tracker.observe(tuple, tcp.Payload, ci.Timestamp)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build darwin || arm || arm64 || s390x
+// +build darwin arm arm64 s390x
+
+// Cross-compiling the snooper requires having pcap binaries,
+// let's disable it for now, as for the DNS snooper.
+
+package endpoint
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// HTTPSnooper samples plain HTTP/1.x traffic on some ports
+type HTTPSnooper struct{}
+
+// NewHTTPSnooper always fails, so that asking for HTTP stats where they
+// can't be gathered doesn't go unnoticed.
+func NewHTTPSnooper(ports []uint16) (*HTTPSnooper, error) {
+	return nil, fmt.Errorf("HTTP snooping is not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// Report adds the HTTP stats gathered since the last report to rpt.
+func (s *HTTPSnooper) Report(rpt *report.Report, hostID string) {}
+
+// Stop makes the snooper stop inspecting HTTP traffic
+func (s *HTTPSnooper) Stop() {}
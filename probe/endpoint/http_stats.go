@@ -0,0 +1,265 @@
+package endpoint
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// Node metadata and metric keys for sampled HTTP traffic.
+const (
+	HTTPRequestRate      = report.HTTPRequestRate
+	HTTPLatencyP50       = report.HTTPLatencyP50
+	HTTPLatencyP99       = report.HTTPLatencyP99
+	HTTPStatusCodes      = report.HTTPStatusCodes
+	HTTPLatencyHistogram = report.HTTPLatencyHistogram
+)
+
+const (
+	// Bound the memory used for requests whose responses haven't been seen
+	maxPendingHTTPFlows    = 10000
+	maxPendingHTTPRequests = 16 // per flow, for pipelining
+	pendingHTTPTimeout     = time.Minute
+	maxHTTPRequestLine     = 2048
+)
+
+// httpLatencyBounds are the upper bounds, in milliseconds, of the buckets
+// of the latency histograms.
+var httpLatencyBounds = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, math.Inf(1)}
+
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("PUT "), []byte("DELETE "),
+	[]byte("HEAD "), []byte("PATCH "), []byte("OPTIONS "),
+}
+
+// Templates for the HTTP metrics and metadata of endpoints and processes.
+var (
+	HTTPMetricTemplates = report.MetricTemplates{
+		HTTPRequestRate: {ID: HTTPRequestRate, Label: "HTTP requests/s", Format: report.DefaultFormat, Group: "http", Priority: 20},
+		HTTPLatencyP50:  {ID: HTTPLatencyP50, Label: "HTTP latency p50 (ms)", Format: report.DefaultFormat, Group: "http", Priority: 21},
+		HTTPLatencyP99:  {ID: HTTPLatencyP99, Label: "HTTP latency p99 (ms)", Format: report.DefaultFormat, Group: "http", Priority: 22},
+	}
+
+	HTTPMetadataTemplates = report.MetadataTemplates{
+		HTTPStatusCodes:      {ID: HTTPStatusCodes, Label: "HTTP status codes", From: report.FromLatest, Priority: 20},
+		HTTPLatencyHistogram: {ID: HTTPLatencyHistogram, Label: "HTTP latencies", From: report.FromLatest, Priority: 21},
+	}
+)
+
+// httpServer is the endpoint a request was sent to.
+type httpServer struct {
+	addr [net.IPv4len]byte
+	port uint16
+}
+
+// httpStats are the HTTP exchanges seen with a server since the last report.
+type httpStats struct {
+	requests    int
+	statusCodes map[int]int
+	buckets     []int // counts by httpLatencyBounds, not cumulative
+}
+
+// httpTracker matches the HTTP/1.x requests and responses found at the start
+// of TCP payloads, and counts them by server. Requests and responses split
+// over several segments are still counted, since only their first line is
+// needed, but messages which don't start a segment are missed.
+type httpTracker struct {
+	mtx        sync.Mutex
+	pending    map[fourTuple][]time.Time // request times by client to server tuple, oldest first
+	stats      map[httpServer]*httpStats
+	lastReport time.Time
+}
+
+func newHTTPTracker(now time.Time) *httpTracker {
+	return &httpTracker{
+		pending:    map[fourTuple][]time.Time{},
+		stats:      map[httpServer]*httpStats{},
+		lastReport: now,
+	}
+}
+
+// observe looks at the payload of a TCP segment sent along tuple at time t.
+func (t *httpTracker) observe(tuple fourTuple, payload []byte, now time.Time) {
+	if isHTTPRequest(payload) {
+		t.mtx.Lock()
+		defer t.mtx.Unlock()
+		times, ok := t.pending[tuple]
+		if !ok && len(t.pending) >= maxPendingHTTPFlows {
+			return
+		}
+		if len(times) >= maxPendingHTTPRequests {
+			times = times[1:]
+		}
+		t.pending[tuple] = append(times, now)
+		return
+	}
+
+	status, ok := httpResponseStatus(payload)
+	if !ok || status < 200 {
+		// Informational responses (e.g. 100 Continue) come before the real one
+		return
+	}
+	request := reverse(tuple)
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	times := t.pending[request]
+	if len(times) == 0 {
+		return
+	}
+	start := times[0]
+	if len(times) == 1 {
+		delete(t.pending, request)
+	} else {
+		t.pending[request] = times[1:]
+	}
+
+	server := httpServer{addr: tuple.fromAddr, port: tuple.fromPort}
+	stats, ok := t.stats[server]
+	if !ok {
+		stats = &httpStats{statusCodes: map[int]int{}, buckets: make([]int, len(httpLatencyBounds))}
+		t.stats[server] = stats
+	}
+	stats.requests++
+	stats.statusCodes[status]++
+	latency := float64(now.Sub(start)) / float64(time.Millisecond)
+	stats.buckets[sort.SearchFloat64s(httpLatencyBounds, latency)]++
+}
+
+func isHTTPRequest(payload []byte) bool {
+	method := false
+	for _, m := range httpMethods {
+		if bytes.HasPrefix(payload, m) {
+			method = true
+			break
+		}
+	}
+	if !method {
+		return false
+	}
+	if len(payload) > maxHTTPRequestLine {
+		payload = payload[:maxHTTPRequestLine]
+	}
+	end := bytes.Index(payload, []byte("\r\n"))
+	return end != -1 && bytes.Contains(payload[:end], []byte(" HTTP/1."))
+}
+
+// httpResponseStatus parses the status code of a status line such as
+// "HTTP/1.1 200 OK".
+func httpResponseStatus(payload []byte) (int, bool) {
+	if len(payload) < 12 || !bytes.HasPrefix(payload, []byte("HTTP/1.")) || payload[8] != ' ' {
+		return 0, false
+	}
+	status, err := strconv.Atoi(string(payload[9:12]))
+	if err != nil || status < 100 || status > 599 {
+		return 0, false
+	}
+	return status, true
+}
+
+// report adds the stats gathered since the last report to the endpoint nodes
+// of the servers, and to the nodes of the processes listening on them, and
+// starts counting afresh.
+func (t *httpTracker) report(rpt *report.Report, hostID string, now time.Time) {
+	t.mtx.Lock()
+	stats, elapsed := t.stats, now.Sub(t.lastReport).Seconds()
+	t.stats, t.lastReport = map[httpServer]*httpStats{}, now
+	for tuple, times := range t.pending {
+		if now.Sub(times[len(times)-1]) > pendingHTTPTimeout {
+			delete(t.pending, tuple)
+		}
+	}
+	t.mtx.Unlock()
+	if elapsed <= 0 {
+		return
+	}
+
+	rpt.Endpoint = rpt.Endpoint.WithMetricTemplates(HTTPMetricTemplates).WithMetadataTemplates(HTTPMetadataTemplates)
+	for server, s := range stats {
+		var (
+			id      = report.MakeEndpointNodeIDB(hostID, 0, net.IP(server.addr[:]), server.port)
+			latests = map[string]string{
+				HTTPStatusCodes:      formatStatusCodes(s.statusCodes),
+				HTTPLatencyHistogram: formatLatencyHistogram(s.buckets),
+			}
+			metrics = report.Metrics{
+				HTTPRequestRate: report.MakeSingletonMetric(now, float64(s.requests)/elapsed),
+			}
+		)
+		for key, q := range map[string]float64{HTTPLatencyP50: 0.5, HTTPLatencyP99: 0.99} {
+			metrics[key] = report.MakeSingletonMetric(now, latencyQuantile(q, s.buckets))
+		}
+		node := report.MakeNodeWith(id, latests).WithMetrics(metrics)
+		rpt.Endpoint.AddNode(node)
+
+		// Put the stats on the listening process too, where they show in the UI
+		if existing, ok := rpt.Endpoint.Nodes[id]; ok {
+			if pid, ok := existing.Latest.Lookup(process.PID); ok {
+				rpt.Process = rpt.Process.WithMetricTemplates(HTTPMetricTemplates).WithMetadataTemplates(HTTPMetadataTemplates)
+				rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID(hostID, pid), latests).WithMetrics(metrics))
+			}
+		}
+	}
+}
+
+// formatStatusCodes lists the number of responses with each status code,
+// e.g. "200: 95, 404: 3, 500: 2".
+func formatStatusCodes(statusCodes map[int]int) string {
+	codes := make([]int, 0, len(statusCodes))
+	for code := range statusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	parts := make([]string, 0, len(codes))
+	for _, code := range codes {
+		parts = append(parts, fmt.Sprintf("%d: %d", code, statusCodes[code]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatLatencyHistogram lists the non-empty latency buckets, e.g.
+// "<=10ms: 80, <=100ms: 15, >10000ms: 1".
+func formatLatencyHistogram(buckets []int) string {
+	var parts []string
+	for i, count := range buckets {
+		if count == 0 {
+			continue
+		}
+		if bound := httpLatencyBounds[i]; math.IsInf(bound, 1) {
+			parts = append(parts, fmt.Sprintf(">%vms: %d", httpLatencyBounds[i-1], count))
+		} else {
+			parts = append(parts, fmt.Sprintf("<=%vms: %d", bound, count))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// latencyQuantile estimates the q-quantile of the latencies counted in
+// buckets, interpolating linearly within the bucket it falls in.
+func latencyQuantile(q float64, buckets []int) float64 {
+	total := 0
+	for _, count := range buckets {
+		total += count
+	}
+	rank := q * float64(total)
+	lowerBound, seen := 0.0, 0
+	for i, count := range buckets {
+		bound := httpLatencyBounds[i]
+		if count > 0 && float64(seen+count) >= rank {
+			if math.IsInf(bound, 1) {
+				return lowerBound
+			}
+			return lowerBound + (bound-lowerBound)*(rank-float64(seen))/float64(count)
+		}
+		lowerBound, seen = bound, seen+count
+	}
+	return lowerBound
+}
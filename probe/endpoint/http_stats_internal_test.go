@@ -0,0 +1,132 @@
+package endpoint
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestIsHTTPRequest(t *testing.T) {
+	for payload, want := range map[string]bool{
+		"GET /index.html HTTP/1.1\r\nHost: x\r\n\r\n": true,
+		"POST /api HTTP/1.0\r\n":                      true,
+		"GET /index.html HTTP/1.1":                    false, // no end of line
+		"GET /index.html\r\n":                         false, // HTTP/0.9
+		"PRI * HTTP/2.0\r\n":                          false,
+		"HTTP/1.1 200 OK\r\n":                         false,
+		"get / HTTP/1.1\r\n":                          false,
+	} {
+		if have := isHTTPRequest([]byte(payload)); have != want {
+			t.Errorf("%q: want %v, have %v", payload, want, have)
+		}
+	}
+}
+
+func TestHTTPResponseStatus(t *testing.T) {
+	for payload, want := range map[string]int{
+		"HTTP/1.1 200 OK\r\n":               200,
+		"HTTP/1.0 404 Not Found\r\n":        404,
+		"HTTP/1.1 100 Continue\r\n":         100,
+		"HTTP/1.1 999 Whatever\r\n":         0,
+		"HTTP/1.1 20":                       0,
+		"HTTP/2.0 200 OK\r\n":               0,
+		"GET / HTTP/1.1\r\nHTTP/1.1 200 \r": 0,
+	} {
+		have, ok := httpResponseStatus([]byte(payload))
+		if ok != (want != 0) || have != want {
+			t.Errorf("%q: want %d, have %d (%v)", payload, want, have, ok)
+		}
+	}
+}
+
+func TestHTTPTracker(t *testing.T) {
+	var (
+		start   = time.Now()
+		client1 = makeFourTuple(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.9"), 40000, 80)
+		client2 = makeFourTuple(net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.9"), 40000, 80)
+		tracker = newHTTPTracker(start)
+		ms      = func(n int) time.Time { return start.Add(time.Duration(n) * time.Millisecond) }
+	)
+
+	// Two pipelined requests, answered after 3ms and 40ms
+	tracker.observe(client1, []byte("GET /a HTTP/1.1\r\n\r\n"), ms(0))
+	tracker.observe(client1, []byte("GET /b HTTP/1.1\r\n\r\n"), ms(1))
+	tracker.observe(reverse(client1), []byte("HTTP/1.1 200 OK\r\n\r\n"), ms(3))
+	tracker.observe(reverse(client1), []byte("HTTP/1.1 404 Not Found\r\n\r\n"), ms(41))
+	// An informational response doesn't end the exchange
+	tracker.observe(client2, []byte("POST /c HTTP/1.1\r\nExpect: 100-continue\r\n\r\n"), ms(0))
+	tracker.observe(reverse(client2), []byte("HTTP/1.1 100 Continue\r\n\r\n"), ms(1))
+	tracker.observe(client2, []byte("body"), ms(2))
+	tracker.observe(reverse(client2), []byte("HTTP/1.1 500 Internal Server Error\r\n\r\n"), ms(4))
+	// Responses without a request, and request bodies, are ignored
+	tracker.observe(reverse(client2), []byte("HTTP/1.1 200 OK\r\n\r\n"), ms(5))
+
+	const hostID = "host"
+	serverID := report.MakeEndpointNodeID(hostID, "", "10.0.0.9", "80")
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNodeWith(serverID, map[string]string{process.PID: "42"}))
+	now := start.Add(2 * time.Second)
+	tracker.report(&rpt, hostID, now)
+
+	node, ok := rpt.Endpoint.Nodes[serverID]
+	if !ok {
+		t.Fatalf("Expected endpoint %s in %v", serverID, rpt.Endpoint.Nodes)
+	}
+	if pid, _ := node.Latest.Lookup(process.PID); pid != "42" {
+		t.Errorf("Expected existing endpoint metadata to be kept, have pid %q", pid)
+	}
+	rate, _ := node.Metrics.Lookup(HTTPRequestRate)
+	if sample, ok := rate.LastSample(); !ok || sample.Value != 1.5 {
+		t.Errorf("Expected 1.5 requests/s, have %v", rate)
+	}
+	if codes, _ := node.Latest.Lookup(HTTPStatusCodes); codes != "200: 1, 404: 1, 500: 1" {
+		t.Errorf("Unexpected status codes %q", codes)
+	}
+	if histogram, _ := node.Latest.Lookup(HTTPLatencyHistogram); histogram != "<=5ms: 2, <=50ms: 1" {
+		t.Errorf("Unexpected latency histogram %q", histogram)
+	}
+	if _, ok := rpt.Endpoint.MetricTemplates[HTTPRequestRate]; !ok {
+		t.Errorf("Expected endpoint metric templates")
+	}
+
+	processNode, ok := rpt.Process.Nodes[report.MakeProcessNodeID(hostID, "42")]
+	if !ok {
+		t.Fatalf("Expected the stats on the listening process, have %v", rpt.Process.Nodes)
+	}
+	if _, ok := processNode.Metrics.Lookup(HTTPLatencyP99); !ok {
+		t.Errorf("Expected a p99 latency on the process")
+	}
+
+	// The stats start afresh after each report
+	rpt = report.MakeReport()
+	tracker.report(&rpt, hostID, now.Add(time.Second))
+	if len(rpt.Endpoint.Nodes) != 0 {
+		t.Errorf("Expected no stats, have %v", rpt.Endpoint.Nodes)
+	}
+}
+
+func TestLatencyQuantile(t *testing.T) {
+	buckets := make([]int, len(httpLatencyBounds))
+	buckets[2] = 10 // (5, 10]ms
+	buckets[5] = 10 // (50, 100]ms
+	for _, c := range []struct {
+		q, want float64
+	}{
+		{0.25, 7.5},
+		{0.5, 10},
+		{0.75, 75},
+		{1, 100},
+	} {
+		if have := latencyQuantile(c.q, buckets); have != c.want {
+			t.Errorf("q%v: want %v, have %v", c.q, c.want, have)
+		}
+	}
+
+	buckets[len(buckets)-1] = 80 // slower than the largest bound
+	if have := latencyQuantile(0.99, buckets); have != 10000 {
+		t.Errorf("Expected the largest bound, have %v", have)
+	}
+}
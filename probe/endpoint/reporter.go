@@ -26,6 +26,7 @@ type ReporterConfig struct {
 	ProcessCache *process.CachingWalker
 	Scanner      procspy.ConnectionScanner
 	DNSSnooper   *DNSSnooper
+	HTTPSnooper  *HTTPSnooper
 }
 
 // Name of this reporter, for metrics gathering
@@ -41,6 +41,7 @@ func (r *Reporter) Report() (report.Report, error) {
 	rpt := report.MakeReport()
 
 	r.connectionTracker.ReportConnections(&rpt)
+	r.conf.HTTPSnooper.Report(&rpt, r.conf.HostID)
 	r.natMapper.applyNAT(rpt, r.conf.HostID)
 	return rpt, nil
 }
@@ -21,6 +21,8 @@ import (
 	apibatchv1 "k8s.io/api/batch/v1"
 	apibatchv1beta1 "k8s.io/api/batch/v1beta1"
 	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
@@ -54,12 +56,13 @@ type Client interface {
 	WalkVolumeSnapshots(f func(VolumeSnapshot) error) error
 	WalkVolumeSnapshotData(f func(VolumeSnapshotData) error) error
 	WalkJobs(f func(Job) error) error
+	WalkNetworkPolicies(f func(NetworkPolicy) error) error
 
 	WatchPods(f func(Event, Pod))
 
 	CloneVolumeSnapshot(namespaceID, volumeSnapshotID, persistentVolumeClaimID, capacity string) error
 	CreateVolumeSnapshot(namespaceID, persistentVolumeClaimID, capacity string) error
-	GetLogs(namespaceID, podID string, containerNames []string) (io.ReadCloser, error)
+	GetLogs(namespaceID, podID string, containerNames []string, tailLines *int64) (io.ReadCloser, error)
 	Describe(namespaceID, resourceID string, groupKind schema.GroupKind, restMapping apimeta.RESTMapping) (io.ReadCloser, error)
 	DeletePod(namespaceID, podID string) error
 	DeleteVolumeSnapshot(namespaceID, volumeSnapshotID string) error
@@ -67,6 +70,9 @@ type Client interface {
 	ScaleDown(namespaceID, id string) error
 	// Cordon or Uncordon a node based on whether `desired` is true or false respectively.
 	CordonNode(name string, desired bool) error
+	// Cordon a node and evict all its pods, except those of daemon sets
+	// and static pods, which would not move elsewhere.
+	DrainNode(name string, force bool) error
 	// Returns a list of kubernetes nodes.
 	GetNodes() ([]apiv1.Node, error)
 }
@@ -104,6 +110,7 @@ type client struct {
 	storageClassStore          cache.Store
 	volumeSnapshotStore        cache.Store
 	volumeSnapshotDataStore    cache.Store
+	networkPolicyStore         cache.Store
 
 	podWatchesMutex sync.Mutex
 	podWatches      []func(Event, Pod)
@@ -199,6 +206,7 @@ func NewClient(config ClientConfig) (Client, error) {
 	result.storageClassStore = result.setupStore("storageclasses")
 	result.volumeSnapshotStore = result.setupStore("volumesnapshots")
 	result.volumeSnapshotDataStore = result.setupStore("volumesnapshotdatas")
+	result.networkPolicyStore = result.setupStore("networkpolicies")
 
 	return result, nil
 }
@@ -257,6 +265,8 @@ func (c *client) clientAndType(resource string) (rest.Interface, interface{}, er
 		return c.snapshotClient.VolumesnapshotV1().RESTClient(), &snapshotv1.VolumeSnapshotData{}, nil
 	case "cronjobs":
 		return c.client.BatchV1beta1().RESTClient(), &apibatchv1beta1.CronJob{}, nil
+	case "networkpolicies":
+		return c.client.NetworkingV1().RESTClient(), &networkingv1.NetworkPolicy{}, nil
 	}
 	return nil, nil, fmt.Errorf("Invalid resource: %v", resource)
 }
@@ -461,6 +471,16 @@ func (c *client) WalkJobs(f func(Job) error) error {
 	return nil
 }
 
+func (c *client) WalkNetworkPolicies(f func(NetworkPolicy) error) error {
+	for _, m := range c.networkPolicyStore.List() {
+		networkPolicy := m.(*networkingv1.NetworkPolicy)
+		if err := f(NewNetworkPolicy(networkPolicy)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *client) CloneVolumeSnapshot(namespaceID, volumeSnapshotID, persistentVolumeClaimID, capacity string) error {
 	var scName string
 	var claimSize string
@@ -544,7 +564,7 @@ func (c *client) CreateVolumeSnapshot(namespaceID, persistentVolumeClaimID, capa
 	return nil
 }
 
-func (c *client) GetLogs(namespaceID, podID string, containerNames []string) (io.ReadCloser, error) {
+func (c *client) GetLogs(namespaceID, podID string, containerNames []string, tailLines *int64) (io.ReadCloser, error) {
 	readClosersWithLabel := map[io.ReadCloser]string{}
 	for _, container := range containerNames {
 		req := c.client.CoreV1().Pods(namespaceID).GetLogs(
@@ -553,6 +573,7 @@ func (c *client) GetLogs(namespaceID, podID string, containerNames []string) (io
 				Follow:     true,
 				Timestamps: true,
 				Container:  container,
+				TailLines:  tailLines,
 			},
 		)
 		readCloser, err := req.Stream()
@@ -650,6 +671,89 @@ func (c *client) CordonNode(name string, desired bool) error {
 	return nil
 }
 
+func (c *client) DrainNode(name string, force bool) error {
+	pods, err := c.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", name).String(),
+	})
+	if err != nil {
+		return err
+	}
+	// Check the pods before cordoning, so a refused drain leaves the node alone
+	evictions, err := podsToEvict(pods.Items, force)
+	if err != nil {
+		return fmt.Errorf("cannot drain node %s: %v", name, err)
+	}
+	if err := c.CordonNode(name, true); err != nil {
+		return err
+	}
+	var failed []string
+	for _, pod := range evictions {
+		// Evictions respect pod disruption budgets, unlike deletions
+		err := c.client.CoreV1().Pods(pod.Namespace).Evict(&policyv1beta1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			failed = append(failed, fmt.Sprintf("%s/%s: %v", pod.Namespace, pod.Name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("error evicting pods from node %s: %s", name, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// podsToEvict picks the pods which draining a node should evict. Unless
+// force is set, it refuses, listing them, if any pod would be lost for good
+// (it has no controller to recreate it) or would lose local data (it has
+// emptyDir volumes).
+func podsToEvict(pods []apiv1.Pod, force bool) ([]apiv1.Pod, error) {
+	var (
+		evictions []apiv1.Pod
+		blocked   []string
+	)
+	for _, pod := range pods {
+		evict, err := evictable(pod, force)
+		if err != nil {
+			blocked = append(blocked, fmt.Sprintf("%s/%s (%v)", pod.Namespace, pod.Name, err))
+			continue
+		}
+		if evict {
+			evictions = append(evictions, pod)
+		}
+	}
+	if len(blocked) > 0 {
+		return nil, fmt.Errorf("pods would be lost, use force to evict them anyway: %s", strings.Join(blocked, ", "))
+	}
+	return evictions, nil
+}
+
+// evictable says whether draining a node should evict the pod. It returns
+// an error for pods which can only be evicted with force.
+func evictable(pod apiv1.Pod, force bool) (bool, error) {
+	if pod.Status.Phase == apiv1.PodSucceeded || pod.Status.Phase == apiv1.PodFailed {
+		return false, nil
+	}
+	if _, ok := pod.Annotations[apiv1.MirrorPodAnnotationKey]; ok {
+		return false, nil
+	}
+	ref := metav1.GetControllerOf(&pod)
+	if ref != nil && ref.Kind == "DaemonSet" {
+		return false, nil
+	}
+	if force {
+		return true, nil
+	}
+	if ref == nil {
+		return false, errors.New("not managed by a controller")
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.EmptyDir != nil {
+			return false, fmt.Errorf("uses emptyDir volume %s", volume.Name)
+		}
+	}
+	return true, nil
+}
+
 func (c *client) GetNodes() ([]apiv1.Node, error) {
 	l, err := c.client.CoreV1().Nodes().List(metav1.ListOptions{})
 	if err != nil {
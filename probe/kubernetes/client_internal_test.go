@@ -0,0 +1,96 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func drainTestPod(name, controllerKind string, phase apiv1.PodPhase, volumes ...apiv1.Volume) apiv1.Pod {
+	pod := apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Spec:       apiv1.PodSpec{Volumes: volumes},
+		Status:     apiv1.PodStatus{Phase: phase},
+	}
+	if controllerKind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: controllerKind, Name: "owner", Controller: &controller}}
+	}
+	return pod
+}
+
+var (
+	emptyDir  = apiv1.Volume{Name: "scratch", VolumeSource: apiv1.VolumeSource{EmptyDir: &apiv1.EmptyDirVolumeSource{}}}
+	configMap = apiv1.Volume{Name: "config", VolumeSource: apiv1.VolumeSource{ConfigMap: &apiv1.ConfigMapVolumeSource{}}}
+)
+
+func TestEvictable(t *testing.T) {
+	mirror := drainTestPod("mirror", "", apiv1.PodRunning)
+	mirror.Annotations = map[string]string{apiv1.MirrorPodAnnotationKey: "x"}
+
+	for _, c := range []struct {
+		name      string
+		pod       apiv1.Pod
+		force     bool
+		want      bool
+		wantError string
+	}{
+		{"replicaset", drainTestPod("a", "ReplicaSet", apiv1.PodRunning, configMap), false, true, ""},
+		{"daemonset", drainTestPod("a", "DaemonSet", apiv1.PodRunning), false, false, ""},
+		{"daemonset forced", drainTestPod("a", "DaemonSet", apiv1.PodRunning), true, false, ""},
+		{"succeeded", drainTestPod("a", "", apiv1.PodSucceeded), false, false, ""},
+		{"failed", drainTestPod("a", "Job", apiv1.PodFailed), false, false, ""},
+		{"mirror", mirror, false, false, ""},
+		{"bare", drainTestPod("a", "", apiv1.PodRunning), false, false, "not managed by a controller"},
+		{"bare forced", drainTestPod("a", "", apiv1.PodRunning), true, true, ""},
+		{"emptydir", drainTestPod("a", "ReplicaSet", apiv1.PodRunning, configMap, emptyDir), false, false, "uses emptyDir volume scratch"},
+		{"emptydir forced", drainTestPod("a", "ReplicaSet", apiv1.PodRunning, emptyDir), true, true, ""},
+	} {
+		have, err := evictable(c.pod, c.force)
+		haveError := ""
+		if err != nil {
+			haveError = err.Error()
+		}
+		if have != c.want || haveError != c.wantError {
+			t.Errorf("%s: want {%v, %q}, have {%v, %q}", c.name, c.want, c.wantError, have, haveError)
+		}
+	}
+}
+
+func TestPodsToEvict(t *testing.T) {
+	pods := []apiv1.Pod{
+		drainTestPod("web", "ReplicaSet", apiv1.PodRunning),
+		drainTestPod("agent", "DaemonSet", apiv1.PodRunning),
+		drainTestPod("bare", "", apiv1.PodRunning),
+		drainTestPod("cache", "StatefulSet", apiv1.PodRunning, emptyDir),
+	}
+
+	_, err := podsToEvict(pods, false)
+	if err == nil {
+		t.Fatal("Expected drain to be refused")
+	}
+	for _, want := range []string{"ns/bare (not managed by a controller)", "ns/cache (uses emptyDir volume scratch)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %q", want, err)
+		}
+	}
+
+	evictions, err := podsToEvict(pods, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pod := range evictions {
+		names = append(names, pod.Name)
+	}
+	if have := strings.Join(names, ","); have != "web,bare,cache" {
+		t.Errorf("Expected web,bare,cache to be evicted, have %s", have)
+	}
+
+	evictions, err = podsToEvict(pods[:2], false)
+	if err != nil || len(evictions) != 1 || evictions[0].Name != "web" {
+		t.Errorf("Expected only web to be evicted, have %v (%v)", evictions, err)
+	}
+}
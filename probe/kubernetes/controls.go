@@ -3,6 +3,7 @@ package kubernetes
 import (
 	"io"
 	"io/ioutil"
+	"strconv"
 
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/controls"
@@ -23,6 +24,7 @@ const (
 	ScaleDown            = report.KubernetesScaleDown
 	CordonNode           = report.KubernetesCordonNode
 	UncordonNode         = report.KubernetesUncordonNode
+	DrainNode            = report.KubernetesDrainNode
 )
 
 // GroupName and version used by CRDs
@@ -31,9 +33,18 @@ const (
 	SnapshotVersion   = "v1"
 )
 
-// GetLogs is the control to get the logs for a kubernetes pod
+// GetLogs is the control to get the logs for a kubernetes pod. The optional
+// "tail" argument limits how many existing lines are shown before following.
 func (r *Reporter) GetLogs(req xfer.Request, namespaceID, podID string, containerNames []string) xfer.Response {
-	readCloser, err := r.client.GetLogs(namespaceID, podID, containerNames)
+	var tailLines *int64
+	if t, ok := req.ControlArgs["tail"]; ok {
+		lines, err := strconv.ParseInt(t, 10, 64)
+		if err != nil || lines < 0 {
+			return xfer.ResponseErrorf("Bad parameter: tail (%q)", t)
+		}
+		tailLines = &lines
+	}
+	readCloser, err := r.client.GetLogs(namespaceID, podID, containerNames, tailLines)
 	if err != nil {
 		return xfer.ResponseError(err)
 	}
@@ -507,6 +518,20 @@ func (r *Reporter) UncordonNode(req xfer.Request, name string) xfer.Response {
 	return xfer.ResponseError(r.client.CordonNode(name, false))
 }
 
+// DrainNode is the control to cordon a node and evict its pods. It refuses
+// to evict pods without a controller or with emptyDir volumes unless the
+// "force" argument is "true".
+func (r *Reporter) DrainNode(req xfer.Request, name string) xfer.Response {
+	force := false
+	if f, ok := req.ControlArgs["force"]; ok {
+		var err error
+		if force, err = strconv.ParseBool(f); err != nil {
+			return xfer.ResponseErrorf("Bad parameter: force (%q)", f)
+		}
+	}
+	return xfer.ResponseError(r.client.DrainNode(name, force))
+}
+
 func (r *Reporter) registerControls() {
 	controls := map[string]xfer.ControlHandlerFunc{
 		CloneVolumeSnapshot:  r.CaptureVolumeSnapshot(r.cloneVolumeSnapshot),
@@ -519,6 +544,7 @@ func (r *Reporter) registerControls() {
 		ScaleDown:            r.CaptureDeployment(r.ScaleDown),
 		CordonNode:           r.CaptureNode(r.CordonNode),
 		UncordonNode:         r.CaptureNode(r.UncordonNode),
+		DrainNode:            r.CaptureNode(r.DrainNode),
 	}
 	r.handlerRegistry.Batch(nil, controls)
 }
@@ -535,6 +561,7 @@ func (r *Reporter) deregisterControls() {
 		ScaleDown,
 		CordonNode,
 		UncordonNode,
+		DrainNode,
 	}
 	r.handlerRegistry.Batch(controls, nil)
 }
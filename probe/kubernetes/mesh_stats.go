@@ -0,0 +1,308 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// These constants are keys used in node metrics
+const (
+	MeshRequestRate = report.KubernetesMeshRequestRate
+	MeshErrorRate   = report.KubernetesMeshErrorRate
+	MeshLatencyP99  = report.KubernetesMeshLatencyP99
+)
+
+// Istio's Envoy sidecar serves both its own and Istio's Prometheus stats on
+// this port of the pod IP.
+const (
+	istioStatsPort = "15090"
+	istioStatsPath = "/stats/prometheus"
+
+	istioRequests = "istio_requests_total"
+	istioDuration = "istio_request_duration_milliseconds"
+)
+
+// MeshMetricTemplates are the templates of the metrics read from sidecars.
+var (
+	MeshMetricTemplates = report.MetricTemplates{
+		MeshRequestRate: {ID: MeshRequestRate, Label: "Requests/s", Format: report.DefaultFormat, Group: "mesh", Priority: 3},
+		MeshErrorRate:   {ID: MeshErrorRate, Label: "Errors", Format: report.PercentFormat, Group: "mesh", Priority: 4},
+		MeshLatencyP99:  {ID: MeshLatencyP99, Label: "Latency p99 (ms)", Format: report.DefaultFormat, Group: "mesh", Priority: 5},
+	}
+)
+
+// meshCounters are the totals, since the sidecar started, of the requests a
+// pod has served through the mesh.
+type meshCounters struct {
+	time     time.Time
+	requests float64
+	errors   float64 // requests answered with a 5xx status
+	// buckets maps the upper bounds of a latency histogram, in
+	// milliseconds, to the number of requests served that fast.
+	buckets map[float64]float64
+}
+
+// parseIstioStats reads the inbound request counters from the Prometheus
+// stats of an Istio sidecar. Only the metrics reported by the sidecar as the
+// destination are counted, so each request is counted once, by the pod
+// which served it.
+func parseIstioStats(r io.Reader, t time.Time) (meshCounters, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return meshCounters{}, err
+	}
+	counters := meshCounters{time: t, buckets: map[float64]float64{}}
+	if family, ok := families[istioRequests]; ok {
+		for _, m := range family.GetMetric() {
+			if labelValue(m, "reporter") != "destination" {
+				continue
+			}
+			counters.requests += m.GetCounter().GetValue()
+			if strings.HasPrefix(labelValue(m, "response_code"), "5") {
+				counters.errors += m.GetCounter().GetValue()
+			}
+		}
+	}
+	if family, ok := families[istioDuration]; ok {
+		for _, m := range family.GetMetric() {
+			if labelValue(m, "reporter") != "destination" {
+				continue
+			}
+			h := m.GetHistogram()
+			for _, b := range h.GetBucket() {
+				if !math.IsInf(b.GetUpperBound(), 1) {
+					counters.buckets[b.GetUpperBound()] += float64(b.GetCumulativeCount())
+				}
+			}
+			// The +Inf bucket holds every request, and is optional in the format
+			counters.buckets[math.Inf(1)] += float64(h.GetSampleCount())
+		}
+	}
+	return counters, nil
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// meshMetrics works out the request rate, the percentage of requests which
+// failed and the 99th percentile latency between two readings of a pod's
+// counters. It returns false if there is no interval between them, or the
+// counters were reset in between, as happens when the sidecar restarts.
+func meshMetrics(prev, cur meshCounters) (report.Metrics, bool) {
+	elapsed := cur.time.Sub(prev.time).Seconds()
+	requests := cur.requests - prev.requests
+	if elapsed <= 0 || requests < 0 || cur.errors < prev.errors {
+		return nil, false
+	}
+	metrics := report.Metrics{
+		MeshRequestRate: report.MakeSingletonMetric(cur.time, requests/elapsed),
+	}
+	if requests == 0 {
+		return metrics, true
+	}
+	metrics[MeshErrorRate] = report.MakeSingletonMetric(cur.time, (cur.errors-prev.errors)/requests*100).WithMax(100)
+	buckets := map[float64]float64{}
+	for bound, count := range cur.buckets {
+		buckets[bound] = count - prev.buckets[bound]
+	}
+	if p99, ok := histogramQuantile(0.99, buckets); ok {
+		metrics[MeshLatencyP99] = report.MakeSingletonMetric(cur.time, p99)
+	}
+	return metrics, true
+}
+
+// histogramQuantile estimates the q-quantile of a histogram of cumulative
+// counts by upper bound, interpolating linearly within the bucket it falls
+// in, as Prometheus' histogram_quantile does.
+func histogramQuantile(q float64, buckets map[float64]float64) (float64, bool) {
+	bounds := make([]float64, 0, len(buckets))
+	for bound := range buckets {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+	if len(bounds) < 2 || !math.IsInf(bounds[len(bounds)-1], 1) {
+		return 0, false
+	}
+	total := buckets[bounds[len(bounds)-1]]
+	if total <= 0 {
+		return 0, false
+	}
+	rank := q * total
+	lowerBound, lowerCount := 0.0, 0.0
+	for _, bound := range bounds {
+		count := buckets[bound]
+		if count >= rank {
+			if math.IsInf(bound, 1) {
+				// Can't interpolate into an unbounded bucket
+				return lowerBound, true
+			}
+			if count == lowerCount {
+				return bound, true
+			}
+			return lowerBound + (bound-lowerBound)*(rank-lowerCount)/(count-lowerCount), true
+		}
+		lowerBound, lowerCount = bound, count
+	}
+	return lowerBound, true
+}
+
+// meshStatsScraper reads the stats of the sidecars of mesh pods in the
+// background, keeping the previous reading of each to turn the counters into
+// rates, so that reporting never waits on a sidecar.
+//
+// The metrics are per pod, as served: Istio labels requests with the source
+// workload rather than the source pod, so they can't be attributed to
+// pod-to-pod edges.
+type meshStatsScraper struct {
+	client *http.Client
+	port   string
+	quit   chan struct{}
+
+	mtx     sync.Mutex
+	targets map[string]string         // pod node IDs to pod IPs, as last reported
+	last    map[string]meshCounters   // by pod node ID
+	metrics map[string]report.Metrics // by pod node ID, from the last two readings
+}
+
+const (
+	meshStatsInterval = 5 * time.Second
+	// meshStatsWorkers bounds how many sidecars are scraped at once
+	meshStatsWorkers = 16
+)
+
+func newMeshStatsScraper(timeout time.Duration) *meshStatsScraper {
+	return &meshStatsScraper{
+		client:  &http.Client{Timeout: timeout},
+		port:    istioStatsPort,
+		quit:    make(chan struct{}),
+		targets: map[string]string{},
+		last:    map[string]meshCounters{},
+		metrics: map[string]report.Metrics{},
+	}
+}
+
+func (s *meshStatsScraper) loop() {
+	ticker := time.NewTicker(meshStatsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.scrapeAll()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *meshStatsScraper) stop() {
+	close(s.quit)
+}
+
+func (s *meshStatsScraper) scrape(ip string) (meshCounters, error) {
+	resp, err := s.client.Get(fmt.Sprintf("http://%s%s", net.JoinHostPort(ip, s.port), istioStatsPath))
+	if err != nil {
+		return meshCounters{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return meshCounters{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return parseIstioStats(resp.Body, mtime.Now())
+}
+
+// scrapeAll reads the sidecars of the last reported pods, at most
+// meshStatsWorkers at a time, and works out the metrics of those read
+// before.
+func (s *meshStatsScraper) scrapeAll() {
+	s.mtx.Lock()
+	targets := make(map[string]string, len(s.targets))
+	for id, ip := range s.targets {
+		targets[id] = ip
+	}
+	s.mtx.Unlock()
+
+	var (
+		wg       sync.WaitGroup
+		mtx      sync.Mutex
+		counters = make(map[string]meshCounters, len(targets))
+		ids      = make(chan string)
+	)
+	for i := 0; i < meshStatsWorkers && i < len(targets); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				c, err := s.scrape(targets[id])
+				if err != nil {
+					log.Debugf("Kubernetes reporter: error reading mesh stats of %s: %v", id, err)
+					continue
+				}
+				mtx.Lock()
+				counters[id] = c
+				mtx.Unlock()
+			}
+		}()
+	}
+	for id := range targets {
+		ids <- id
+	}
+	close(ids)
+	wg.Wait()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for id, cur := range counters {
+		if _, ok := s.targets[id]; !ok {
+			continue // gone while we were scraping
+		}
+		if prev, ok := s.last[id]; ok {
+			if metrics, ok := meshMetrics(prev, cur); ok {
+				s.metrics[id] = metrics
+			} else {
+				delete(s.metrics, id)
+			}
+		}
+		s.last[id] = cur
+	}
+}
+
+// addMetrics adds the metrics last worked out to their nodes in pods, and
+// makes targets, a map of pod node IDs to pod IPs, the pods to scrape from
+// now on. Pods which are gone are forgotten.
+func (s *meshStatsScraper) addMetrics(pods report.Topology, targets map[string]string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.targets = targets
+	for id := range s.last {
+		if _, ok := targets[id]; !ok {
+			delete(s.last, id)
+			delete(s.metrics, id)
+		}
+	}
+	for id, metrics := range s.metrics {
+		if node, ok := pods.Nodes[id]; ok {
+			pods.Nodes[id] = node.WithMetrics(metrics)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package kubernetes
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+func istioStats(requests, errors, fast int) string {
+	return fmt.Sprintf(`# TYPE istio_requests_total counter
+istio_requests_total{reporter="destination",response_code="200"} %d
+istio_requests_total{reporter="destination",response_code="503"} %d
+istio_requests_total{reporter="source",response_code="200"} 1000
+# TYPE istio_request_duration_milliseconds histogram
+istio_request_duration_milliseconds_bucket{reporter="destination",le="10"} %d
+istio_request_duration_milliseconds_bucket{reporter="destination",le="100"} %d
+istio_request_duration_milliseconds_bucket{reporter="destination",le="+Inf"} %d
+istio_request_duration_milliseconds_sum{reporter="destination"} 0
+istio_request_duration_milliseconds_count{reporter="destination"} %d
+istio_request_duration_milliseconds_bucket{reporter="source",le="10"} 1000
+istio_request_duration_milliseconds_bucket{reporter="source",le="+Inf"} 1000
+istio_request_duration_milliseconds_sum{reporter="source"} 0
+istio_request_duration_milliseconds_count{reporter="source"} 1000
+`, requests-errors, errors, fast, requests, requests, requests)
+}
+
+func TestParseIstioStats(t *testing.T) {
+	now := time.Now()
+	have, err := parseIstioStats(strings.NewReader(istioStats(100, 5, 90)), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have.requests != 100 || have.errors != 5 {
+		t.Errorf("Expected 100 requests and 5 errors, have %v and %v", have.requests, have.errors)
+	}
+	for bound, want := range map[float64]float64{10: 90, 100: 100, math.Inf(1): 100} {
+		if have.buckets[bound] != want {
+			t.Errorf("Bucket %v: want %v, have %v", bound, want, have.buckets[bound])
+		}
+	}
+	if _, err := parseIstioStats(strings.NewReader("not { prometheus"), now); err == nil {
+		t.Error("Expected error on bad stats")
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	for _, c := range []struct {
+		buckets map[float64]float64
+		want    float64
+		ok      bool
+	}{
+		{map[float64]float64{10: 0, 20: 100, math.Inf(1): 100}, 19.9, true},
+		{map[float64]float64{10: 100, 20: 100, math.Inf(1): 100}, 9.9, true},
+		{map[float64]float64{10: 50, math.Inf(1): 100}, 10, true},
+		{map[float64]float64{10: 0, math.Inf(1): 0}, 0, false},
+		{map[float64]float64{10: 5}, 0, false},
+	} {
+		have, ok := histogramQuantile(0.99, c.buckets)
+		if ok != c.ok || math.Abs(have-c.want) > 1e-9 {
+			t.Errorf("%v: want {%v, %v}, have {%v, %v}", c.buckets, c.want, c.ok, have, ok)
+		}
+	}
+}
+
+func TestMeshMetrics(t *testing.T) {
+	now := time.Now()
+	prev, _ := parseIstioStats(strings.NewReader(istioStats(100, 5, 90)), now)
+	cur, _ := parseIstioStats(strings.NewReader(istioStats(300, 15, 190)), now.Add(10*time.Second))
+
+	metrics, ok := meshMetrics(prev, cur)
+	if !ok {
+		t.Fatal("Expected metrics")
+	}
+	// 200 requests in 10s, 10 of them failed, 100 under 10ms and the rest under 100ms
+	for id, want := range map[string]float64{MeshRequestRate: 20, MeshErrorRate: 5, MeshLatencyP99: 98.2} {
+		sample, ok := metrics[id].LastSample()
+		if !ok || math.Abs(sample.Value-want) > 1e-9 {
+			t.Errorf("%s: want %v, have %v", id, want, sample.Value)
+		}
+	}
+
+	if _, ok := meshMetrics(cur, prev); ok {
+		t.Error("Expected no metrics after a counter reset")
+	}
+	if metrics, ok := meshMetrics(prev, meshCounters{time: now.Add(time.Second), requests: prev.requests, errors: prev.errors}); !ok || len(metrics) != 1 {
+		t.Errorf("Expected only a request rate without requests, have %v", metrics)
+	}
+}
+
+func TestMeshStatsScraper(t *testing.T) {
+	requests := 100
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != istioStatsPath {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, istioStats(requests, 0, requests))
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+	s := newMeshStatsScraper(time.Second)
+	s.port = port
+	id := report.MakePodNodeID("uid")
+	targets := map[string]string{id: host}
+	topology := func() report.Topology {
+		t := report.MakeTopology()
+		t.AddNode(report.MakeNode(id))
+		return t
+	}
+
+	// Reporting only tells the scraper what to scrape
+	pods := topology()
+	s.addMetrics(pods, targets)
+	if len(pods.Nodes[id].Metrics) != 0 {
+		t.Errorf("Expected no metrics before scraping, have %v", pods.Nodes[id].Metrics)
+	}
+	s.scrapeAll()
+
+	// The first reading only gives counters
+	pods = topology()
+	s.addMetrics(pods, targets)
+	if len(pods.Nodes[id].Metrics) != 0 {
+		t.Errorf("Expected no metrics yet, have %v", pods.Nodes[id].Metrics)
+	}
+
+	requests = 150
+	mtime.NowForce(now.Add(5 * time.Second))
+	s.scrapeAll()
+	pods = topology()
+	s.addMetrics(pods, targets)
+	if sample, ok := pods.Nodes[id].Metrics[MeshRequestRate].LastSample(); !ok || sample.Value != 10 {
+		t.Errorf("Expected 10 requests/s, have %v", pods.Nodes[id].Metrics)
+	}
+
+	// Pods which are gone are forgotten
+	s.addMetrics(topology(), map[string]string{})
+	if len(s.last) != 0 || len(s.metrics) != 0 {
+		t.Errorf("Expected readings of deleted pods to be dropped, have %v, %v", s.last, s.metrics)
+	}
+}
@@ -13,7 +13,7 @@ const (
 	Name            = report.KubernetesName
 	Namespace       = report.KubernetesNamespace
 	Created         = report.KubernetesCreated
-	LabelPrefix     = "kubernetes_labels_"
+	LabelPrefix     = report.KubernetesLabelPrefix
 	VolumeClaimName = report.KubernetesVolumeClaim
 )
 
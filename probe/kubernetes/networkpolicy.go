@@ -0,0 +1,97 @@
+package kubernetes
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// NetworkPolicy represents a Kubernetes network policy
+type NetworkPolicy interface {
+	Meta
+	Selector() (labels.Selector, error)
+	Rules() report.NetworkPolicy
+}
+
+type networkPolicy struct {
+	*networkingv1.NetworkPolicy
+	Meta
+}
+
+// NewNetworkPolicy creates a new network policy
+func NewNetworkPolicy(np *networkingv1.NetworkPolicy) NetworkPolicy {
+	return &networkPolicy{
+		NetworkPolicy: np,
+		Meta:          meta{np.ObjectMeta},
+	}
+}
+
+// Selector returns the selector for the pods the policy applies to. An
+// empty pod selector selects every pod in the policy's namespace.
+func (np *networkPolicy) Selector() (labels.Selector, error) {
+	return metav1.LabelSelectorAsSelector(&np.Spec.PodSelector)
+}
+
+// Rules returns what the app needs to know of the policy to work out which
+// connections between pods it allows.
+func (np *networkPolicy) Rules() report.NetworkPolicy {
+	result := report.NetworkPolicy{
+		Namespace:   np.Namespace(),
+		Name:        np.Name(),
+		PodSelector: labelSelector(np.Spec.PodSelector),
+		Ingress:     policyRules(len(np.Spec.Ingress), func(i int) []networkingv1.NetworkPolicyPeer { return np.Spec.Ingress[i].From }),
+		Egress:      policyRules(len(np.Spec.Egress), func(i int) []networkingv1.NetworkPolicyPeer { return np.Spec.Egress[i].To }),
+	}
+	if len(np.Spec.PolicyTypes) == 0 {
+		// As defaulted by the API server
+		result.PolicyIngress = true
+		result.PolicyEgress = len(np.Spec.Egress) > 0
+	}
+	for _, t := range np.Spec.PolicyTypes {
+		switch t {
+		case networkingv1.PolicyTypeIngress:
+			result.PolicyIngress = true
+		case networkingv1.PolicyTypeEgress:
+			result.PolicyEgress = true
+		}
+	}
+	return result
+}
+
+func policyRules(n int, peers func(int) []networkingv1.NetworkPolicyPeer) []report.NetworkPolicyRule {
+	rules := make([]report.NetworkPolicyRule, 0, n)
+	for i := 0; i < n; i++ {
+		var rule report.NetworkPolicyRule
+		for _, p := range peers(i) {
+			var peer report.NetworkPolicyPeer
+			if p.PodSelector != nil {
+				s := labelSelector(*p.PodSelector)
+				peer.PodSelector = &s
+			}
+			if p.NamespaceSelector != nil {
+				s := labelSelector(*p.NamespaceSelector)
+				peer.NamespaceSelector = &s
+			}
+			if p.IPBlock != nil {
+				peer.IPBlock = &report.IPBlock{CIDR: p.IPBlock.CIDR, Except: p.IPBlock.Except}
+			}
+			rule.Peers = append(rule.Peers, peer)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func labelSelector(s metav1.LabelSelector) report.LabelSelector {
+	result := report.LabelSelector{MatchLabels: s.MatchLabels}
+	for _, r := range s.MatchExpressions {
+		result.MatchExpressions = append(result.MatchExpressions, report.LabelSelectorRequirement{
+			Key:      r.Key,
+			Operator: string(r.Operator),
+			Values:   r.Values,
+		})
+	}
+	return result
+}
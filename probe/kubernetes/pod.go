@@ -14,8 +14,16 @@ const (
 	State           = report.KubernetesState
 	IsInHostNetwork = report.KubernetesIsInHostNetwork
 	RestartCount    = report.KubernetesRestartCount
+	ServiceMesh     = report.KubernetesServiceMesh
 )
 
+// meshSidecars maps the names of the proxy containers injected by service
+// meshes to the mesh they belong to.
+var meshSidecars = map[string]string{
+	"istio-proxy":   "istio",
+	"linkerd-proxy": "linkerd",
+}
+
 // Pod represents a Kubernetes pod
 type Pod interface {
 	Meta
@@ -25,6 +33,7 @@ type Pod interface {
 	RestartCount() uint
 	ContainerNames() []string
 	VolumeClaimNames() []string
+	ServiceMesh() (string, bool)
 }
 
 type pod struct {
@@ -75,6 +84,17 @@ func (p *pod) RestartCount() uint {
 	return count
 }
 
+// ServiceMesh returns the service mesh whose sidecar proxy runs in the pod,
+// if any. Traffic to and from such pods goes through the proxy.
+func (p *pod) ServiceMesh() (string, bool) {
+	for _, c := range p.Pod.Spec.Containers {
+		if mesh, ok := meshSidecars[c.Name]; ok {
+			return mesh, true
+		}
+	}
+	return "", false
+}
+
 func (p *pod) VolumeClaimNames() []string {
 	var claimNames []string
 	for _, volume := range p.Spec.Volumes {
@@ -103,6 +123,10 @@ func (p *pod) GetNode(probeID string) report.Node {
 		latests[IsInHostNetwork] = "true"
 	}
 
+	if mesh, ok := p.ServiceMesh(); ok {
+		latests[ServiceMesh] = mesh
+	}
+
 	return p.MetaNode(report.MakePodNodeID(p.UID())).WithLatests(latests).
 		WithParents(p.parents).
 		WithLatestActiveControls(GetLogs, DeletePod, Describe)
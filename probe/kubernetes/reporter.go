@@ -1,6 +1,11 @@
 package kubernetes
 
 import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
 	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/weaveworks/common/mtime"
@@ -31,6 +36,10 @@ const (
 	VolumeSnapshotName = report.KubernetesVolumeSnapshotName
 	SnapshotData       = report.KubernetesSnapshotData
 	VolumeCapacity     = report.KubernetesVolumeCapacity
+	NetworkPolicies    = report.KubernetesNetworkPolicies
+	NetworkPolicyRules = report.KubernetesNetworkPolicyRules
+	PolicyDenied       = report.KubernetesPolicyDenied
+	PolicyUnprotected  = report.KubernetesPolicyUnprotected
 )
 
 // Exposed for testing
@@ -42,9 +51,14 @@ var (
 		Namespace:        {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 5},
 		Created:          {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 6},
 		RestartCount:     {ID: RestartCount, Label: "Restart #", From: report.FromLatest, Priority: 7},
+		NetworkPolicies:  {ID: NetworkPolicies, Label: "Network policies", From: report.FromLatest, Priority: 8},
+		ServiceMesh:      {ID: ServiceMesh, Label: "Service mesh", From: report.FromLatest, Priority: 9},
+		// Worked out by the app, see render.PodRenderer
+		PolicyDenied:      {ID: PolicyDenied, Label: "Connections denied by policy", From: report.FromSets, Priority: 10},
+		PolicyUnprotected: {ID: PolicyUnprotected, Label: "Connections no policy covers", From: report.FromSets, Priority: 11},
 	}
 
-	PodMetricTemplates = docker.ContainerMetricTemplates
+	PodMetricTemplates = docker.ContainerMetricTemplates.Merge(MeshMetricTemplates)
 
 	ServiceMetadataTemplates = report.MetadataTemplates{
 		Namespace:  {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
@@ -195,6 +209,12 @@ var (
 			Icon:  "fa fa-toggle-on",
 			Rank:  0,
 		},
+		{
+			ID:    DrainNode,
+			Human: "Drain",
+			Icon:  "fa fa-sign-out-alt",
+			Rank:  2,
+		},
 	}
 )
 
@@ -207,6 +227,7 @@ type Reporter struct {
 	hostID          string
 	handlerRegistry *controls.HandlerRegistry
 	nodeName        string
+	meshStats       *meshStatsScraper
 }
 
 // NewReporter makes a new Reporter
@@ -225,9 +246,20 @@ func NewReporter(client Client, pipes controls.PipeClient, probeID string, hostI
 	return reporter
 }
 
-// Stop unregisters controls.
+// EnableMeshStats makes the reporter read the request rate, error rate and
+// latency of pods with an Istio sidecar from the sidecar's stats, waiting at
+// most timeout for each. The sidecars are read in the background.
+func (r *Reporter) EnableMeshStats(timeout time.Duration) {
+	r.meshStats = newMeshStatsScraper(timeout)
+	go r.meshStats.loop()
+}
+
+// Stop unregisters controls, and stops reading mesh stats.
 func (r *Reporter) Stop() {
 	r.deregisterControls()
+	if r.meshStats != nil {
+		r.meshStats.stop()
+	}
 }
 
 // Name of this reporter, for metrics gathering
@@ -332,11 +364,15 @@ func (r *Reporter) Report() (report.Report, error) {
 	if err != nil {
 		return result, err
 	}
-	podTopology, err := r.podTopology(services, deployments, daemonSets, statefulSets, cronJobs, jobs)
+	networkPolicies, err := r.networkPolicies()
 	if err != nil {
 		return result, err
 	}
-	namespaceTopology, err := r.namespaceTopology()
+	podTopology, err := r.podTopology(services, deployments, daemonSets, statefulSets, cronJobs, jobs, networkPolicies)
+	if err != nil {
+		return result, err
+	}
+	namespaceTopology, err := r.namespaceTopology(networkPolicies)
 	if err != nil {
 		return result, err
 	}
@@ -582,7 +618,16 @@ func match(namespace string, selector labels.Selector, topology, id string) func
 	}
 }
 
-func (r *Reporter) podTopology(services []Service, deployments []Deployment, daemonSets []DaemonSet, statefulSets []StatefulSet, cronJobs []CronJob, jobs []Job) (report.Topology, error) {
+func (r *Reporter) networkPolicies() ([]NetworkPolicy, error) {
+	networkPolicies := []NetworkPolicy{}
+	err := r.client.WalkNetworkPolicies(func(np NetworkPolicy) error {
+		networkPolicies = append(networkPolicies, np)
+		return nil
+	})
+	return networkPolicies, err
+}
+
+func (r *Reporter) podTopology(services []Service, deployments []Deployment, daemonSets []DaemonSet, statefulSets []StatefulSet, cronJobs []CronJob, jobs []Job, networkPolicies []NetworkPolicy) (report.Topology, error) {
 	var (
 		pods = report.MakeTopology().
 			WithMetadataTemplates(PodMetadataTemplates).
@@ -661,20 +706,30 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 				report.MakeCronJobNodeID(cronJob.UID()),
 			))
 		}
-		for _, job := range jobs {
-			selector, err := job.Selector()
-			if err != nil {
-				return pods, err
-			}
-			selectors = append(selectors, match(
-				job.Namespace(),
-				selector,
-				report.Job,
-				report.MakeJobNodeID(job.UID()),
-			))
+	}
+	for _, job := range jobs {
+		selector, err := job.Selector()
+		if err != nil {
+			return pods, err
+		}
+		selectors = append(selectors, match(
+			job.Namespace(),
+			selector,
+			report.Job,
+			report.MakeJobNodeID(job.UID()),
+		))
+	}
+
+	policySelectors := make([]labels.Selector, 0, len(networkPolicies))
+	for _, np := range networkPolicies {
+		selector, err := np.Selector()
+		if err != nil {
+			return pods, err
 		}
+		policySelectors = append(policySelectors, selector)
 	}
 
+	meshTargets := map[string]string{}
 	err := r.client.WalkPods(func(p Pod) error {
 		// filter out non-local pods: we only want to report local ones for performance reasons.
 		if r.nodeName != "" {
@@ -685,16 +740,51 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 		for _, selector := range selectors {
 			selector(p)
 		}
-		pods.AddNode(p.GetNode(r.probeID))
+		node := p.GetNode(r.probeID)
+		// Record which policies apply, so pods no policy protects stand out
+		var policyNames []string
+		for i, np := range networkPolicies {
+			if np.Namespace() == p.Namespace() && policySelectors[i].Matches(labels.Set(p.Labels())) {
+				policyNames = append(policyNames, np.Name())
+			}
+		}
+		if len(policyNames) > 0 {
+			sort.Strings(policyNames)
+			node = node.WithLatest(NetworkPolicies, mtime.Now(), strings.Join(policyNames, ", "))
+		}
+		pods.AddNode(node)
+		if mesh, ok := p.ServiceMesh(); ok && mesh == "istio" && r.meshStats != nil {
+			if ip, ok := node.Latest.Lookup(IP); ok && ip != "" {
+				meshTargets[node.ID] = ip
+			}
+		}
 		return nil
 	})
+	if err == nil && r.meshStats != nil {
+		r.meshStats.addMetrics(pods, meshTargets)
+	}
 	return pods, err
 }
 
-func (r *Reporter) namespaceTopology() (report.Topology, error) {
+func (r *Reporter) namespaceTopology(networkPolicies []NetworkPolicy) (report.Topology, error) {
 	result := report.MakeTopology()
+	// The rules of the policies of each namespace go on its node, for the
+	// app to check connections between pods against
+	rules := map[string][]report.NetworkPolicy{}
+	for _, np := range networkPolicies {
+		rules[np.Namespace()] = append(rules[np.Namespace()], np.Rules())
+	}
 	err := r.client.WalkNamespaces(func(ns NamespaceResource) error {
-		result.AddNode(ns.GetNode())
+		node := ns.GetNode()
+		if policies, ok := rules[ns.Name()]; ok {
+			sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+			buf, err := json.Marshal(policies)
+			if err != nil {
+				return err
+			}
+			node = node.WithLatest(NetworkPolicyRules, mtime.Now(), string(buf))
+		}
+		result.AddNode(node)
 		return nil
 	})
 	return result, err
@@ -723,7 +813,7 @@ func (r *Reporter) hostTopology() (report.Topology, error) {
 		result.AddNode(
 			report.MakeNode(report.MakeHostNodeID(n.Name)).
 				WithTopology(report.Host).
-				WithLatestActiveControls(activeControl),
+				WithLatestActiveControls(activeControl, DrainNode),
 		)
 	}
 	return result, nil
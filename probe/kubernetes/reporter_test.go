@@ -8,7 +8,9 @@ import (
 	"testing"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	k8smeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -117,7 +119,10 @@ type mockClient struct {
 	pods        []kubernetes.Pod
 	services    []kubernetes.Service
 	deployments []kubernetes.Deployment
+	jobs        []kubernetes.Job
+	policies    []kubernetes.NetworkPolicy
 	logs        map[string]io.ReadCloser
+	drained     []string
 }
 
 func (c *mockClient) Stop() {}
@@ -173,10 +178,23 @@ func (c *mockClient) WalkVolumeSnapshotData(f func(kubernetes.VolumeSnapshotData
 	return nil
 }
 func (c *mockClient) WalkJobs(f func(kubernetes.Job) error) error {
+	for _, job := range c.jobs {
+		if err := f(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (c *mockClient) WalkNetworkPolicies(f func(kubernetes.NetworkPolicy) error) error {
+	for _, policy := range c.policies {
+		if err := f(policy); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 func (*mockClient) WatchPods(func(kubernetes.Event, kubernetes.Pod)) {}
-func (c *mockClient) GetLogs(namespaceID, podName string, _ []string) (io.ReadCloser, error) {
+func (c *mockClient) GetLogs(namespaceID, podName string, _ []string, _ *int64) (io.ReadCloser, error) {
 	r, ok := c.logs[namespaceID+";"+podName]
 	if !ok {
 		return nil, fmt.Errorf("Not found")
@@ -209,6 +227,11 @@ func (c *mockClient) CordonNode(name string, desired bool) error {
 	return nil
 }
 
+func (c *mockClient) DrainNode(name string, force bool) error {
+	c.drained = append(c.drained, fmt.Sprintf("%s force=%v", name, force))
+	return nil
+}
+
 func (c *mockClient) GetNodes() ([]apiv1.Node, error) {
 	return nil, nil
 }
@@ -310,6 +333,114 @@ func TestReporter(t *testing.T) {
 
 }
 
+func TestReporterJobParents(t *testing.T) {
+	mockK8s := newMockClient()
+	mockK8s.jobs = []kubernetes.Job{kubernetes.NewJob(&batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pong-job",
+			UID:       types.UID("job1234"),
+			Namespace: "ping",
+		},
+		Spec: batchv1.JobSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"ponger": "true"}},
+		},
+	})}
+	hr := controls.NewDefaultHandlerRegistry()
+	rpt, err := kubernetes.NewReporter(mockK8s, nil, "probe-id", "foo", nil, hr, nodeName).Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pods are matched to jobs even when there are no cronjobs
+	jobID := report.MakeJobNodeID("job1234")
+	for _, podID := range []string{report.MakePodNodeID(pod1UID), report.MakePodNodeID(pod2UID)} {
+		parents, _ := rpt.Pod.Nodes[podID].Parents.Lookup(report.Job)
+		if !reflect.DeepEqual(report.MakeStringSet(jobID), parents) {
+			t.Errorf("Expected pod %s to have parent job %q, got %q", podID, jobID, parents)
+		}
+	}
+}
+
+func TestReporterNetworkPolicies(t *testing.T) {
+	mockK8s := newMockClient()
+	mockK8s.pods = append(mockK8s.pods, kubernetes.NewPod(&apiv1.Pod{
+		TypeMeta: podTypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pong-c",
+			UID:       types.UID("k1l2m3n4o5"),
+			Namespace: "ping",
+			Labels:    map[string]string{"ponger": "false"},
+		},
+		Spec: apiv1.PodSpec{NodeName: nodeName},
+	}))
+	for _, np := range []networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "ping"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-pong", Namespace: "ping"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"ponger": "true"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-namespace", Namespace: "pang"},
+		},
+	} {
+		np := np
+		mockK8s.policies = append(mockK8s.policies, kubernetes.NewNetworkPolicy(&np))
+	}
+	hr := controls.NewDefaultHandlerRegistry()
+	rpt, err := kubernetes.NewReporter(mockK8s, nil, "probe-id", "foo", nil, hr, nodeName).Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for podUID, want := range map[string]string{
+		pod1UID:      "allow-pong, deny-all",
+		pod2UID:      "allow-pong, deny-all",
+		"k1l2m3n4o5": "deny-all",
+	} {
+		podID := report.MakePodNodeID(podUID)
+		if have, _ := rpt.Pod.Nodes[podID].Latest.Lookup(kubernetes.NetworkPolicies); have != want {
+			t.Errorf("Expected pod %s to have network policies %q, got %q", podID, want, have)
+		}
+	}
+
+	// Policies without policy types restrict ingress, and egress only if
+	// they have egress rules
+	rules := mockK8s.policies[1].Rules()
+	if want := (report.NetworkPolicy{
+		Namespace:     "ping",
+		Name:          "allow-pong",
+		PodSelector:   report.LabelSelector{MatchLabels: map[string]string{"ponger": "true"}},
+		PolicyIngress: true,
+		Ingress:       []report.NetworkPolicyRule{},
+		Egress:        []report.NetworkPolicyRule{},
+	}); !reflect.DeepEqual(want, rules) {
+		t.Errorf("want %+v, have %+v", want, rules)
+	}
+}
+
+func TestReporterServiceMesh(t *testing.T) {
+	meshPod := apiPod2
+	meshPod.Spec.Containers = []apiv1.Container{{Name: "pong"}, {Name: "istio-proxy"}}
+	mockK8s := newMockClient()
+	mockK8s.pods = []kubernetes.Pod{pod1, kubernetes.NewPod(&meshPod)}
+	hr := controls.NewDefaultHandlerRegistry()
+	rpt, err := kubernetes.NewReporter(mockK8s, nil, "probe-id", "foo", nil, hr, nodeName).Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, ok := rpt.Pod.Nodes[report.MakePodNodeID(pod1UID)].Latest.Lookup(kubernetes.ServiceMesh); ok {
+		t.Errorf("Expected pod without a sidecar to have no service mesh, got %q", have)
+	}
+	if have, _ := rpt.Pod.Nodes[report.MakePodNodeID(pod2UID)].Latest.Lookup(kubernetes.ServiceMesh); have != "istio" {
+		t.Errorf("Expected pod with istio-proxy to be in the istio mesh, got %q", have)
+	}
+}
+
 func BenchmarkReporter(b *testing.B) {
 	hr := controls.NewDefaultHandlerRegistry()
 	mockK8s := newMockClient()
@@ -426,6 +557,19 @@ func TestReporterGetLogs(t *testing.T) {
 		}
 	}
 
+	// Should reject a bad number of lines to tail
+	{
+		resp := reporter.CapturePod(reporter.GetLogs)(xfer.Request{
+			AppID:       "appID",
+			NodeID:      report.MakePodNodeID(pod1UID),
+			Control:     kubernetes.GetLogs,
+			ControlArgs: map[string]string{"tail": "-1"},
+		})
+		if want := `Bad parameter: tail ("-1")`; resp.Error != want {
+			t.Errorf("Expected error on bad tail: %q, got %q", want, resp.Error)
+		}
+	}
+
 	podNamespaceAndID := "ping;pong-a"
 	pod1Request := xfer.Request{
 		AppID:   "appID",
@@ -469,3 +613,26 @@ func TestReporterGetLogs(t *testing.T) {
 		t.Errorf("Expected pipe to close the underlying log stream")
 	}
 }
+
+func TestReporterDrainNode(t *testing.T) {
+	client := newMockClient()
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := kubernetes.NewReporter(client, nil, "", "", nil, hr, nodeName)
+	drain := reporter.CaptureNode(reporter.DrainNode)
+
+	if resp := drain(xfer.Request{NodeID: "invalidID", Control: kubernetes.DrainNode}); resp.Error != "Invalid ID: invalidID" {
+		t.Errorf("Expected error on invalid ID, got %q", resp.Error)
+	}
+	nodeID := report.MakeHostNodeID(nodeName)
+	if resp := drain(xfer.Request{NodeID: nodeID, Control: kubernetes.DrainNode, ControlArgs: map[string]string{"force": "maybe"}}); resp.Error != `Bad parameter: force ("maybe")` {
+		t.Errorf("Expected error on bad force, got %q", resp.Error)
+	}
+	for _, args := range []map[string]string{nil, {"force": "true"}} {
+		if resp := drain(xfer.Request{NodeID: nodeID, Control: kubernetes.DrainNode, ControlArgs: args}); resp.Error != "" {
+			t.Errorf("Unexpected error: %q", resp.Error)
+		}
+	}
+	if want := []string{nodeName + " force=false", nodeName + " force=true"}; !reflect.DeepEqual(want, client.drained) {
+		t.Errorf("want %v, have %v", want, client.drained)
+	}
+}
@@ -29,6 +29,7 @@ import (
 // Exposed for testing
 var (
 	transport                 = makeUnixRoundTripper
+	decodeResult              = getResult
 	maxResponseBytes    int64 = 50 * 1024 * 1024
 	errResponseTooLarge       = fmt.Errorf("response must be shorter than 50MB")
 	validPluginName           = regexp.MustCompile("^[A-Za-z0-9]+([-][A-Za-z0-9]+)*$")
@@ -294,17 +295,25 @@ func (r *Registry) pluginControlHandler(req xfer.Request) xfer.Response {
 	pluginID, controlID := realPluginAndControlID(req.Control)
 	req.Control = controlID
 	r.lock.RLock()
-	defer r.lock.RUnlock()
-	if plugin, found := r.pluginsByID[pluginID]; found {
-		response := plugin.Control(req)
-		if response.ShortcutReport != nil {
+	plugin, found := r.pluginsByID[pluginID]
+	r.lock.RUnlock()
+	if !found {
+		return xfer.ResponseErrorf("plugin %s not found", pluginID)
+	}
+	response := plugin.Control(req)
+	if response.ShortcutReport != nil {
+		// Updating the plugin's controls needs the write lock, as other
+		// controls may be handled concurrently. Don't bring back the
+		// controls of a plugin which went away in the meantime.
+		r.lock.Lock()
+		if r.pluginsByID[pluginID] == plugin {
 			r.updateAndRegisterControlsInReport(response.ShortcutReport)
-			response.ShortcutReport.Shortcut = true
-			r.publisher.Publish(*response.ShortcutReport)
 		}
-		return response.Response
+		r.lock.Unlock()
+		response.ShortcutReport.Shortcut = true
+		r.publisher.Publish(*response.ShortcutReport)
 	}
-	return xfer.ResponseErrorf("plugin %s not found", pluginID)
+	return response.Response
 }
 
 func realPluginAndControlID(fakeID string) (string, string) {
@@ -358,6 +367,10 @@ type Plugin struct {
 	client             *http.Client
 	cancel             context.CancelFunc
 	backoff            backoff.Interface
+
+	// specLock guards PluginSpec, as the registry sends controls to the
+	// plugin while it reports
+	specLock sync.Mutex
 }
 
 // NewPlugin loads and initializes a new plugin. If client is nil,
@@ -391,10 +404,11 @@ func (p *Plugin) Report() (result report.Report, err error) {
 	result = report.MakeReport()
 	defer func() {
 		p.setStatus(err)
-		result.Plugins = result.Plugins.Add(p.PluginSpec)
+		spec := p.spec()
+		result.Plugins = result.Plugins.Add(spec)
 		if err != nil {
 			result = report.MakeReport()
-			result.Plugins = xfer.MakePluginSpecs(p.PluginSpec)
+			result.Plugins = xfer.MakePluginSpecs(spec)
 		}
 	}()
 
@@ -407,10 +421,12 @@ func (p *Plugin) Report() (result report.Report, err error) {
 
 	key := result.Plugins.Keys()[0]
 	spec, _ := result.Plugins.Lookup(key)
-	if spec.ID != p.PluginSpec.ID {
-		return result, fmt.Errorf("plugin must not change its id (is %q, should be %q)", spec.ID, p.PluginSpec.ID)
+	if id := p.spec().ID; spec.ID != id {
+		return result, fmt.Errorf("plugin must not change its id (is %q, should be %q)", spec.ID, id)
 	}
+	p.specLock.Lock()
 	p.PluginSpec = spec
+	p.specLock.Unlock()
 
 	switch {
 	case spec.APIVersion != p.expectedAPIVersion:
@@ -437,14 +453,14 @@ func (p *Plugin) Control(request xfer.Request) (res PluginResponse) {
 	if p.Implements("controller") {
 		err = p.post("/control", p.handshakeMetadata, request, &res)
 	} else {
-		err = fmt.Errorf("the %s plugin does not implement the controller interface", p.PluginSpec.Label)
+		err = fmt.Errorf("the %s plugin does not implement the controller interface", p.spec().Label)
 	}
 	return res
 }
 
 // Implements checks if the plugin implements the given interface
 func (p *Plugin) Implements(iface string) bool {
-	for _, i := range p.PluginSpec.Interfaces {
+	for _, i := range p.spec().Interfaces {
 		if i == iface {
 			return true
 		}
@@ -452,7 +468,15 @@ func (p *Plugin) Implements(iface string) bool {
 	return false
 }
 
+func (p *Plugin) spec() xfer.PluginSpec {
+	p.specLock.Lock()
+	defer p.specLock.Unlock()
+	return p.PluginSpec
+}
+
 func (p *Plugin) setStatus(err error) {
+	p.specLock.Lock()
+	defer p.specLock.Unlock()
 	if err == nil {
 		p.Status = "ok"
 	} else {
@@ -472,7 +496,7 @@ func (p *Plugin) get(path string, params url.Values, result interface{}) error {
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("plugin returned non-200 status code: %s", resp.Status)
 	}
-	return getResult(resp.Body, result)
+	return decodeResult(resp.Body, result)
 }
 
 func (p *Plugin) post(path string, params url.Values, data interface{}, result interface{}) error {
@@ -491,7 +515,7 @@ func (p *Plugin) post(path string, params url.Values, data interface{}, result i
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("plugin returned non-200 status code: %s", resp.Status)
 	}
-	return getResult(resp.Body, result)
+	return decodeResult(resp.Body, result)
 }
 
 func getResult(body io.ReadCloser, result interface{}) error {
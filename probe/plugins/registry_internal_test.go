@@ -841,3 +841,97 @@ func TestRegistryHandlersCallPlugins(t *testing.T) {
 		t.Fatalf("Got unexpected response: %#v", res)
 	}
 }
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+type countingPublisher struct {
+	sync.Mutex
+	published int
+}
+
+func (p *countingPublisher) Publish(report.Report) {
+	p.Lock()
+	defer p.Unlock()
+	p.published++
+}
+
+// Controls are handled concurrently, each answered with a shortcut report
+// which rewrites the plugin's controls, while the probe reports; run with
+// -race. The plugin's answers are made directly, rather than going through
+// the codec, so that only the registry is under test.
+func TestRegistryHandlesConcurrentShortcutReports(t *testing.T) {
+	setup(t)
+	defer restore(t)
+	spec := pluginSpec("testPlugin", "reporter", "controller")
+	decodeResult = func(_ io.ReadCloser, result interface{}) error {
+		rpt := testReport(topologyWithControls("pod", "node1", []int{1, 2}, []int{1}), spec)
+		switch result := result.(type) {
+		case *report.Report:
+			*result = rpt
+		case *PluginResponse:
+			*result = PluginResponse{ShortcutReport: &rpt}
+		}
+		return nil
+	}
+	defer func() { decodeResult = getResult }()
+
+	publisher := &countingPublisher{}
+	handlerRegistry := controls.NewDefaultHandlerRegistry()
+	r, err := NewRegistry("/plugins", "1", nil, handlerRegistry, publisher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})}
+	plugin, err := NewPlugin(r.context, "/plugins/testPlugin.sock", client, "1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin.PluginSpec = spec
+	r.lock.Lock()
+	r.pluginsBySocket[plugin.socket] = plugin
+	r.pluginsByID[spec.ID] = plugin
+	r.lock.Unlock()
+	if _, err := r.Report(); err != nil {
+		t.Fatal(err)
+	}
+
+	const controls = 20
+	var wg sync.WaitGroup
+	for i := 0; i < controls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := handlerRegistry.HandleControlRequest(xfer.Request{
+				Control: fakeControlID(spec.ID, controlID(1)),
+				NodeID:  "node1",
+			})
+			if res.Error != "" {
+				t.Error(res.Error)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := r.Report(); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+
+	if publisher.published != controls {
+		t.Errorf("want %d shortcut reports, have %d", controls, publisher.published)
+	}
+	r.lock.RLock()
+	have := r.controlsByPlugin[spec.ID]
+	r.lock.RUnlock()
+	if want := report.MakeStringSet(controlID(1), controlID(2)); !reflect.DeepEqual(want, have) {
+		t.Errorf("want plugin controls %v, have %v", want, have)
+	}
+}
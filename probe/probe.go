@@ -139,9 +139,11 @@ func (p *Probe) spyLoop() {
 	for {
 		select {
 		case <-spyTick:
+			t := time.Now()
 			p.tick()
 			rpt := p.report()
 			rpt = p.tag(rpt)
+			metrics.MeasureSince([]string{"spy", "duration", "seconds"}, t)
 			p.spiedReports <- rpt
 		case <-p.quit:
 			return
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -38,6 +39,7 @@ import (
 const (
 	memcacheUpdateInterval = 1 * time.Minute
 	httpTimeout            = 90 * time.Second
+	defaultDiskRetention   = 24 * time.Hour
 )
 
 var (
@@ -51,6 +53,7 @@ var (
 
 func registerAppMetrics() {
 	prometheus.MustRegister(requestDuration)
+	app.MustRegisterMetrics()
 	billing.MustRegisterMetrics()
 }
 
@@ -120,6 +123,20 @@ func collectorFactory(userIDer multitenant.UserIDer, collectorURL, s3URL string,
 	switch parsed.Scheme {
 	case "file":
 		return app.NewFileCollector(parsed.Path, window)
+	case "disk":
+		retention := defaultDiskRetention
+		if r := parsed.Query().Get("retention"); r != "" {
+			if retention, err = time.ParseDuration(r); err != nil {
+				return nil, fmt.Errorf("Invalid retention for disk collector: %v", err)
+			}
+		}
+		return app.NewDiskCollector(parsed.Path, window, retention)
+	case "cassandra":
+		cfg, err := app.CassandraConfigFromURL(parsed)
+		if err != nil {
+			return nil, err
+		}
+		return app.NewCassandraCollector(cfg, window, createTables)
 	case "dynamodb":
 		s3, err := url.Parse(s3URL)
 		if err != nil {
@@ -155,7 +172,7 @@ func collectorFactory(userIDer multitenant.UserIDer, collectorURL, s3URL string,
 		return awsCollector, nil
 	}
 
-	return nil, fmt.Errorf("Invalid collector '%s'", collectorURL)
+	return nil, fmt.Errorf("Invalid collector '%s' (want local, multitenant-live, or a file://, disk://, cassandra:// or dynamodb:// URL)", collectorURL)
 }
 
 func emitterFactory(collector app.Collector, clientCfg billing.Config, userIDer multitenant.UserIDer, emitterCfg multitenant.BillingEmitterConfig) (*multitenant.BillingEmitter, error) {
@@ -281,6 +298,14 @@ func appMain(flags appFlags) {
 		log.Fatalf("Error creating control router: %v", err)
 		return
 	}
+	if flags.controlPolicyFile != "" {
+		policy, err := app.LoadControlPolicy(flags.controlPolicyFile)
+		if err != nil {
+			log.Fatalf("Error loading control policy: %v", err)
+			return
+		}
+		controlRouter = app.NewAuthorizingControlRouter(controlRouter, policy)
+	}
 
 	pipeRouter, err := pipeRouterFactory(userIDer, flags.pipeRouterURL, flags.consulInf)
 	if err != nil {
@@ -334,6 +359,12 @@ func appMain(flags appFlags) {
 		log.Infof("Basic authentication disabled")
 	}
 
+	if flags.tlsClientCAFile != "" {
+		// Only probes need a certificate; browsers without one can use the UI
+		log.Infof("TLS client certificates required from probes")
+		handler = app.RequireClientCert(handler)
+	}
+
 	server := &graceful.Server{
 		// we want to manage the stop condition ourselves below
 		NoSignalHandling: true,
@@ -345,9 +376,20 @@ func appMain(flags appFlags) {
 			MaxHeaderBytes: 1 << 20,
 		},
 	}
+	tlsConfig, err := appTLSConfig(flags)
+	if err != nil {
+		log.Fatalf("Error configuring TLS: %v", err)
+		return
+	}
 	go func() {
 		log.Infof("listening on %s", flags.listen)
-		if err := server.ListenAndServe(); err != nil {
+		var err error
+		if tlsConfig != nil {
+			err = server.ListenAndServeTLSConfig(tlsConfig)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
 			log.Error(err)
 		}
 	}()
@@ -376,6 +418,39 @@ func (c stopper) Stop() error {
 	return nil
 }
 
+// appTLSConfig returns the TLS config to serve with, or nil to serve plain
+// HTTP. Certificates and client CAs are reloaded as they are rotated on disk.
+func appTLSConfig(flags appFlags) (*tls.Config, error) {
+	if flags.tlsCertFile == "" {
+		if flags.tlsClientCAFile != "" {
+			return nil, fmt.Errorf("app.tls.client-ca-file needs app.tls.cert-file")
+		}
+		return nil, nil
+	}
+	cert, err := xfer.NewCertReloader(flags.tlsCertFile, flags.tlsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		GetCertificate: cert.GetCertificate,
+	}
+	if flags.tlsClientCAFile != "" {
+		clientCAs, err := xfer.NewCertPoolReloader(flags.tlsClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		// Certificates are verified when given, and required by
+		// app.RequireClientCert on the probe routes
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c := config.Clone()
+			c.ClientCAs = clientCAs.Pool()
+			return c, nil
+		}
+	}
+	return config, nil
+}
+
 func newWeavePublisher(dockerEndpoint, weaveAddr, weaveHostname, containerName string) (*app.WeavePublisher, error) {
 	dockerClient, err := docker.NewDockerClientStub(dockerEndpoint)
 	if err != nil {
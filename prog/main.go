@@ -106,6 +106,9 @@ type probeFlags struct {
 	spyInterval            time.Duration
 	pluginsRoot            string
 	insecure               bool
+	tlsCertFile            string
+	tlsKeyFile             string
+	tlsCAFile              string
 	logPrefix              string
 	logLevel               string
 	resolver               string
@@ -121,6 +124,7 @@ type probeFlags struct {
 	procEnabled bool // Produce process topology & process nodes in endpoint
 	useEbpfConn bool // Enable connection tracking with eBPF
 	procRoot    string
+	httpPorts   string // Ports to sample HTTP traffic on
 
 	dockerEnabled  bool
 	dockerInterval time.Duration
@@ -133,6 +137,7 @@ type probeFlags struct {
 	kubernetesRole         string
 	kubernetesNodeName     string
 	kubernetesClientConfig kubernetes.ClientConfig
+	kubernetesMeshStats    time.Duration
 
 	ecsEnabled       bool
 	ecsCacheSize     int
@@ -159,12 +164,20 @@ type appFlags struct {
 	password         string
 	passwordFilename string
 
+	tlsCertFile     string
+	tlsKeyFile      string
+	tlsClientCAFile string
+
+	controlPolicyFile string
+
 	weaveEnabled   bool
 	weaveAddr      string
 	weaveHostname  string
 	containerName  string
 	dockerEndpoint string
 
+	knownServiceDomains string
+
 	collectorURL              string // how collector talks to backing store (or "local" if none)
 	collectorAddr             string // how to find collectors if deployed as microservices
 	s3URL                     string
@@ -311,6 +324,9 @@ func setupFlags(flags *flags) {
 	flag.BoolVar(&flags.probe.noEnvironmentVariables, "probe.omit.env-vars", true, "Disable collection of environment variables")
 
 	flag.BoolVar(&flags.probe.insecure, "probe.insecure", false, "(SSL) explicitly allow \"insecure\" SSL connections and transfers")
+	flag.StringVar(&flags.probe.tlsCertFile, "probe.tls.cert-file", "", "(SSL) client certificate to present to the app, reloaded when it changes on disk")
+	flag.StringVar(&flags.probe.tlsKeyFile, "probe.tls.key-file", "", "(SSL) private key for probe.tls.cert-file")
+	flag.StringVar(&flags.probe.tlsCAFile, "probe.tls.ca-file", "", "(SSL) CA certificates to verify the app with, instead of the standard ones. Reloaded when it changes")
 	flag.StringVar(&flags.probe.resolver, "probe.resolver", "", "IP address & port of resolver to use.  Default is to use system resolver.")
 	flag.StringVar(&flags.probe.logPrefix, "probe.log.prefix", "<probe>", "prefix for each log line")
 	flag.StringVar(&flags.probe.logLevel, "probe.log.level", "info", "logging threshold level: debug|info|warn|error|fatal|panic")
@@ -322,6 +338,7 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.probe.procRoot, "probe.proc.root", "/proc", "location of the proc filesystem")
 	flag.BoolVar(&flags.probe.procEnabled, "probe.processes", true, "produce process topology & include procspied connections")
 	flag.BoolVar(&flags.probe.useEbpfConn, "probe.ebpf.connections", true, "enable connection tracking with eBPF")
+	flag.StringVar(&flags.probe.httpPorts, "probe.http.ports", "", "comma-separated TCP ports on which to sample plain HTTP/1.x traffic over IPv4, reporting request rates, status codes and latencies of the servers on them (needs root and linux on amd64 or ppc64le, blank to disable)")
 
 	// Docker
 	flag.BoolVar(&flags.probe.dockerEnabled, "probe.docker", false, "collect Docker-related attributes for processes")
@@ -329,7 +346,7 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.probe.dockerBridge, "probe.docker.bridge", "docker0", "the docker bridge name")
 
 	// CRI
-	flag.BoolVar(&flags.probe.criEnabled, "probe.cri", false, "collect CRI-related attributes for processes")
+	flag.BoolVar(&flags.probe.criEnabled, "probe.cri", false, "collect CRI-related attributes for processes (used instead of --probe.docker when the Docker socket is missing and a containerd, CRI-O or cri-dockerd socket exists)")
 	flag.StringVar(&flags.probe.criEndpoint, "probe.cri.endpoint", "unix///var/run/dockershim.sock", "The endpoint to connect to the CRI")
 
 	// K8s
@@ -349,6 +366,7 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.probe.kubernetesClientConfig.User, "probe.kubernetes.user", "", "The name of the kubeconfig user to use")
 	flag.StringVar(&flags.probe.kubernetesClientConfig.Username, "probe.kubernetes.username", "", "Username for basic authentication to the API server")
 	flag.StringVar(&flags.probe.kubernetesNodeName, "probe.kubernetes.node-name", "", "Name of this node, for filtering pods")
+	flag.DurationVar(&flags.probe.kubernetesMeshStats, "probe.kubernetes.mesh-stats-timeout", 0, "Read request rates, error rates and latencies from the Envoy sidecars of Istio pods, waiting at most this long for each (0 to disable)")
 
 	// AWS ECS
 	flag.BoolVar(&flags.probe.ecsEnabled, "probe.ecs", false, "Collect ecs-related attributes for containers on this node")
@@ -375,14 +393,21 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.app.password, "app.basicAuth.password", "admin", "Password for basic authentication")
 	flag.StringVar(&flags.app.passwordFilename, "app.basicAuth.password.filename", "", "Password filename for basic authentication. It overwrites app.basicAuth.password")
 
+	flag.StringVar(&flags.app.tlsCertFile, "app.tls.cert-file", "", "Serve HTTPS using this certificate, reloaded when it changes on disk")
+	flag.StringVar(&flags.app.tlsKeyFile, "app.tls.key-file", "", "Private key for app.tls.cert-file")
+	flag.StringVar(&flags.app.tlsClientCAFile, "app.tls.client-ca-file", "", "Require probes to present a certificate signed by these CAs to publish reports and connect controls and pipes; browsers need none. Reloaded when it changes. Needs app.tls.cert-file")
+
+	flag.StringVar(&flags.app.controlPolicyFile, "app.controls.policy", "", "JSON file saying which users may invoke which controls (all controls are allowed if blank)")
+
 	flag.StringVar(&flags.app.weaveAddr, "app.weave.addr", app.DefaultWeaveURL, "Address on which to contact WeaveDNS")
 	flag.StringVar(&flags.app.weaveHostname, "app.weave.hostname", "", "Hostname to advertise in WeaveDNS")
 	flag.StringVar(&flags.app.containerName, "app.container.name", app.DefaultContainerName, "Name of this container (to lookup container ID)")
 	flag.StringVar(&flags.app.dockerEndpoint, "app.docker", "", "Overwrite location of docker endpoint (to lookup container ID) (default \"$DOCKER_HOST\")")
 	flag.Var(&flags.containerLabelFilterFlags, "app.container-label-filter", "Add container label-based view filter, specified as title:label. Multiple flags are accepted. Example: --app.container-label-filter='Database Containers:role=db'")
 	flag.Var(&flags.containerLabelFilterFlagsExclude, "app.container-label-filter-exclude", "Add container label-based view filter that excludes containers with the given label, specified as title:label. Multiple flags are accepted. Example: --app.container-label-filter-exclude='Database Containers:role=db'")
+	flag.StringVar(&flags.app.knownServiceDomains, "app.known-service-domains", "", "Comma-separated DNS domains whose hosts are shown as their own nodes rather than as the Internet. Example: --app.known-service-domains=example.com,corp.example.net")
 
-	flag.StringVar(&flags.app.collectorURL, "app.collector", "local", "Collector to use (local, multitenant-live, dynamodb, or file/directory)")
+	flag.StringVar(&flags.app.collectorURL, "app.collector", "local", "Collector to use (local, multitenant-live, dynamodb, file/directory, disk:///path?retention=24h or cassandra://host1,host2/keyspace?retention=24h to persist reports)")
 	flag.StringVar(&flags.app.collectorAddr, "app.collector-addr", "", "Address to look up collectors when deployed as microservices")
 	flag.StringVar(&flags.app.s3URL, "app.collector.s3", "local", "S3 URL to use (when collector is dynamodb)")
 	flag.DurationVar(&flags.app.storeInterval, "app.collector.store-interval", 0, "How often to store merged incoming reports.")
@@ -415,6 +440,9 @@ func main() {
 	flag.Parse()
 
 	app.AddContainerFilters(append(flags.containerLabelFilterFlags.apiTopologyOptions, flags.containerLabelFilterFlagsExclude.apiTopologyOptions...)...)
+	if flags.app.knownServiceDomains != "" {
+		render.AddKnownServiceDomains(strings.Split(flags.app.knownServiceDomains, ",")...)
+	}
 
 	// Deal with common args
 	if flags.debug {
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -148,6 +149,16 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		log.Warnf("unrecognized --probe.kubernetes.role: %s", flags.kubernetesRole)
 	}
 
+	// Without a Docker daemon, look for a CRI runtime such as containerd
+	if flags.dockerEnabled && !flags.criEnabled && docker.SocketMissing("") {
+		if endpoint, ok := cri.FindEndpoint(); ok {
+			log.Infof("Docker: no daemon socket, using the CRI runtime at %s instead", endpoint)
+			flags.dockerEnabled = false
+			flags.criEnabled = true
+			flags.criEndpoint = endpoint
+		}
+	}
+
 	if flags.spyProcs && os.Getegid() != 0 {
 		log.Warn("--probe.proc.spy=true, but that requires root to find everything")
 	}
@@ -158,9 +169,29 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		hostName = hostname.Get()
 		hostID   = hostName // TODO(pb): we should sanitize the hostname
 	)
+	if err := report.ValidHostID(hostID); err != nil {
+		log.Fatalf("Invalid host ID: %v", err)
+	}
 	log.Infof("probe starting, version %s, ID %s", version, probeID)
 	checkNewScopeVersion(flags)
 
+	var (
+		clientCert *xfer.CertReloader
+		rootCAs    *xfer.CertPoolReloader
+	)
+	if flags.tlsCertFile != "" {
+		var err error
+		if clientCert, err = xfer.NewCertReloader(flags.tlsCertFile, flags.tlsKeyFile); err != nil {
+			log.Fatalf("Failed to load TLS client certificate: %v", err)
+		}
+	}
+	if flags.tlsCAFile != "" {
+		var err error
+		if rootCAs, err = xfer.NewCertPoolReloader(flags.tlsCAFile); err != nil {
+			log.Fatalf("Failed to load TLS CA certificates: %v", err)
+		}
+	}
+
 	handlerRegistry := controls.NewDefaultHandlerRegistry()
 	clientFactory := func(hostname string, url url.URL) (appclient.AppClient, error) {
 		token := flags.token
@@ -179,6 +210,8 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 			ProbeVersion: version,
 			ProbeID:      probeID,
 			Insecure:     flags.insecure,
+			ClientCert:   clientCert,
+			RootCAs:      rootCAs,
 		}
 		return appclient.NewAppClient(
 			probeConfig, hostname, url,
@@ -266,6 +299,19 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 			defer dnsSnooper.Stop()
 		}
 
+		var httpSnooper *endpoint.HTTPSnooper
+		if flags.httpPorts != "" {
+			ports, err := parsePorts(flags.httpPorts)
+			if err != nil {
+				log.Fatalf("Invalid --probe.http.ports: %v", err)
+			}
+			if httpSnooper, err = endpoint.NewHTTPSnooper(ports); err != nil {
+				log.Errorf("Failed to start HTTP snooper: %s", err)
+			} else {
+				defer httpSnooper.Stop()
+			}
+		}
+
 		endpointReporter := endpoint.NewReporter(endpoint.ReporterConfig{
 			HostID:       hostID,
 			HostName:     hostName,
@@ -277,6 +323,7 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 			BufferSize:   flags.conntrackBufferSize,
 			ProcessCache: processCache,
 			DNSSnooper:   dnsSnooper,
+			HTTPSnooper:  httpSnooper,
 		})
 		defer endpointReporter.Stop()
 		p.AddReporter(endpointReporter)
@@ -315,7 +362,9 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		if err != nil {
 			log.Errorf("CRI: failed to start registry: %v", err)
 		} else {
-			p.AddReporter(cri.NewReporter(client))
+			reporter := cri.NewReporter(client, clients, probeID, handlerRegistry)
+			defer reporter.Stop()
+			p.AddReporter(reporter)
 		}
 	}
 
@@ -324,6 +373,9 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 			defer client.Stop()
 			reporter := kubernetes.NewReporter(client, clients, probeID, hostID, p, handlerRegistry, flags.kubernetesNodeName)
 			defer reporter.Stop()
+			if flags.kubernetesMeshStats > 0 {
+				reporter.EnableMeshStats(flags.kubernetesMeshStats)
+			}
 			p.AddReporter(reporter)
 			if flags.kubernetesRole != kubernetesRoleCluster && flags.kubernetesNodeName == "" {
 				log.Warnf("No value for --probe.kubernetes.node-name, reporting all pods from every probe (which may impact performance).")
@@ -384,3 +436,16 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		p,
 	)
 }
+
+// parsePorts parses a comma-separated list of port numbers.
+func parsePorts(s string) ([]uint16, error) {
+	var ports []uint16
+	for _, field := range strings.Split(s, ",") {
+		port, err := strconv.ParseUint(strings.TrimSpace(field), 10, 16)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("invalid port %q", field)
+		}
+		ports = append(ports, uint16(port))
+	}
+	return ports, nil
+}
@@ -20,6 +20,7 @@ var parentTopologies = []string{
 	report.DaemonSet,
 	report.StatefulSet,
 	report.CronJob,
+	report.Job,
 	report.Service,
 	report.ECSTask,
 	report.ECSService,
@@ -48,6 +48,15 @@ func TestParents(t *testing.T) {
 				{ID: fixture.ClientHostNodeID, Label: "client", TopologyID: "hosts"},
 			},
 		},
+		{
+			name: "Pod owned by a job",
+			node: report.MakeNode(fixture.ClientPodNodeID).WithTopology(report.Pod).WithParents(
+				report.MakeSets().Add(report.Job, report.MakeStringSet(report.MakeJobNodeID("backup"))),
+			),
+			want: []detailed.Parent{
+				{ID: report.MakeJobNodeID("backup"), Label: "backup", TopologyID: "kube-controllers"},
+			},
+		},
 		{
 			node: render.ProcessRenderer.Render(ctx, fixture.Report).Nodes[fixture.ClientProcess1NodeID],
 			want: []detailed.Parent{
@@ -11,6 +11,11 @@ type Diff struct {
 	Update []NodeSummary `json:"update"`
 	Remove []string      `json:"remove"`
 	Reset  bool          `json:"reset,omitempty"`
+	// Stream identifies the websocket stream the diff was sent on, and
+	// Seq numbers the diffs sent on it, starting from 1. A client that
+	// reconnects can pass both back to resume where it left off.
+	Stream string `json:"stream,omitempty"`
+	Seq    uint64 `json:"seq,omitempty"`
 }
 
 // TopoDiff gives you the diff to get from A to B.
@@ -1,6 +1,8 @@
 package render
 
 import (
+	"errors"
+	"net"
 	"strings"
 
 	"github.com/weaveworks/scope/report"
@@ -10,6 +12,10 @@ import (
 const (
 	IncomingInternetID = "in-theinternet"
 	OutgoingInternetID = "out-theinternet"
+
+	// TheInternetID is the canonical ID of an internet node of unknown
+	// direction.
+	TheInternetID = "theinternet"
 )
 
 // IsInternetNode determines whether the node represents the Internet.
@@ -17,11 +23,37 @@ func IsInternetNode(n report.Node) bool {
 	return n.ID == IncomingInternetID || n.ID == OutgoingInternetID
 }
 
+// CanonicalInternetID maps the recognised forms of internet node IDs,
+// whether bare or wrapped as pseudonode IDs (including the legacy
+// "pseudo;" form), to IncomingInternetID, OutgoingInternetID or
+// TheInternetID, preserving their direction. It returns false for other IDs.
+func CanonicalInternetID(id string) (string, bool) {
+	if pseudoID, ok := ParsePseudoNodeID(id); ok {
+		id = pseudoID
+	} else if strings.HasPrefix(id, Pseudo+report.ScopeDelim) {
+		id = id[len(Pseudo+report.ScopeDelim):]
+	}
+	switch id {
+	case IncomingInternetID, OutgoingInternetID, TheInternetID:
+		return id, true
+	}
+	return "", false
+}
+
 // MakePseudoNodeID joins the parts of an id into the id of a pseudonode
 func MakePseudoNodeID(parts ...string) string {
 	return strings.Join(append([]string{"pseudo"}, parts...), ":")
 }
 
+// MakePseudoNodeIDChecked is like MakePseudoNodeID, but returns an error if
+// no parts are given.
+func MakePseudoNodeIDChecked(parts ...string) (string, error) {
+	if len(parts) == 0 {
+		return "", errors.New("pseudonode ID needs at least one part")
+	}
+	return MakePseudoNodeID(parts...), nil
+}
+
 // ParsePseudoNodeID returns the joined id parts of a pseudonode
 // ID. If the ID is not recognisable as a pseudonode ID, it is
 // returned as is, with the returned bool set to false. That is
@@ -36,6 +68,71 @@ func ParsePseudoNodeID(nodeID string) (string, bool) {
 	return nodeID[pos+1:], true
 }
 
+// IsPseudo determines whether a node ID is a pseudonode ID. A bare
+// "pseudo", as produced by MakePseudoNodeID with no parts, or one with
+// nothing after the separator, is not.
+func IsPseudo(nodeID string) bool {
+	id, ok := ParsePseudoNodeID(nodeID)
+	return ok && id != ""
+}
+
+// IsTheInternet determines whether a node ID, in any of the forms
+// recognised by CanonicalInternetID, represents the Internet.
+func IsTheInternet(nodeID string) bool {
+	_, ok := CanonicalInternetID(nodeID)
+	return ok
+}
+
+// AggregatePseudoConnections counts, per pseudo or internet node ID, the
+// edges with that node at either end. Malformed edge IDs are skipped.
+func AggregatePseudoConnections(edgeIDs []string) map[string]int {
+	counts := map[string]int{}
+	for _, edgeID := range edgeIDs {
+		src, dst, ok := report.ParseEdgeID(edgeID)
+		if !ok {
+			continue
+		}
+		for _, id := range []string{src, dst} {
+			if IsPseudo(id) || IsTheInternet(id) {
+				counts[id]++
+			}
+		}
+	}
+	return counts
+}
+
+// SubnetID is the first part of subnet pseudonode IDs.
+const SubnetID = "subnet"
+
+// MakeSubnetPseudoNodeID produces the ID of a pseudonode grouping all the
+// addresses in a subnet, given in CIDR notation.
+func MakeSubnetPseudoNodeID(cidr string) string {
+	return MakePseudoNodeID(SubnetID, cidr)
+}
+
+// SubnetForAddressID produces the subnet pseudonode ID for the address in an
+// address (or endpoint) node ID, with the subnet having maskBits bits. It
+// returns false if the ID has no address or maskBits is out of range.
+func SubnetForAddressID(id string, maskBits int) (string, bool) {
+	ip := report.AddressIDAddresser(id)
+	if ip == nil {
+		ip = report.EndpointIDAddresser(id)
+	}
+	if ip == nil {
+		return "", false
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	if maskBits < 0 || maskBits > bits {
+		return "", false
+	}
+	mask := net.CIDRMask(maskBits, bits)
+	subnet := net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	return MakeSubnetPseudoNodeID(subnet.String()), true
+}
+
 // MakeGroupNodeTopology joins the parts of a group topology into the topology of a group node
 func MakeGroupNodeTopology(originalTopology, key string) string {
 	return strings.Join([]string{"group", originalTopology, key}, ":")
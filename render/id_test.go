@@ -0,0 +1,97 @@
+package render_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestCanonicalInternetID(t *testing.T) {
+	for id, want := range map[string]string{
+		"theinternet":            render.TheInternetID,
+		"pseudo;theinternet":     render.TheInternetID,
+		"pseudo:theinternet":     render.TheInternetID,
+		"in-theinternet":         render.IncomingInternetID,
+		"pseudo:in-theinternet":  render.IncomingInternetID,
+		"pseudo;in-theinternet":  render.IncomingInternetID,
+		"out-theinternet":        render.OutgoingInternetID,
+		"pseudo:out-theinternet": render.OutgoingInternetID,
+	} {
+		if have, ok := render.CanonicalInternetID(id); !ok || have != want {
+			t.Errorf("CanonicalInternetID(%q) = (%q, %v), want %q", id, have, ok, want)
+		}
+	}
+	for _, id := range []string{"pseudo:10.0.0.1", "theinternet2", render.MakePseudoNodeID(render.UncontainedID, "host")} {
+		if have, ok := render.CanonicalInternetID(id); ok {
+			t.Errorf("CanonicalInternetID(%q) = %q, expected failure", id, have)
+		}
+	}
+}
+
+func TestAggregatePseudoConnections(t *testing.T) {
+	unknown := render.MakePseudoNodeID("10.0.0.1")
+	have := render.AggregatePseudoConnections([]string{
+		report.MakeEdgeID("a", render.OutgoingInternetID),
+		report.MakeEdgeID("b", render.OutgoingInternetID),
+		report.MakeEdgeID(render.IncomingInternetID, "a"),
+		report.MakeEdgeID("a", unknown),
+		report.MakeEdgeID("a", "b"),
+		"malformed",
+	})
+	want := map[string]int{
+		render.OutgoingInternetID: 2,
+		render.IncomingInternetID: 1,
+		unknown:                   1,
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestSubnetForAddressID(t *testing.T) {
+	a, okA := render.SubnetForAddressID(report.MakeAddressNodeID("", "10.0.0.5"), 24)
+	b, okB := render.SubnetForAddressID(report.MakeAddressNodeID("", "10.0.0.200"), 24)
+	if want := render.MakeSubnetPseudoNodeID("10.0.0.0/24"); !okA || !okB || a != want || b != want {
+		t.Errorf("want %q, have %q (%v) and %q (%v)", want, a, okA, b, okB)
+	}
+	if !render.IsPseudo(a) {
+		t.Errorf("%q: not a pseudonode ID", a)
+	}
+
+	if have, _ := render.SubnetForAddressID(report.MakeAddressNodeID("", "10.0.1.5"), 24); have == a {
+		t.Errorf("addresses in different subnets grouped together")
+	}
+	if have, ok := render.SubnetForAddressID(report.MakeEndpointNodeID("", "", "fd00::1234", "80"), 64); !ok || have != render.MakeSubnetPseudoNodeID("fd00::/64") {
+		t.Errorf("have %q (%v)", have, ok)
+	}
+	for _, maskBits := range []int{-1, 33} {
+		if have, ok := render.SubnetForAddressID(report.MakeAddressNodeID("", "10.0.0.5"), maskBits); ok {
+			t.Errorf("/%d: unexpected subnet %q", maskBits, have)
+		}
+	}
+	if have, ok := render.SubnetForAddressID(report.MakeHostNodeID("host"), 24); ok {
+		t.Errorf("unexpected subnet %q for a host node ID", have)
+	}
+}
+
+func TestMakePseudoNodeIDChecked(t *testing.T) {
+	if id, err := render.MakePseudoNodeIDChecked(); err == nil {
+		t.Errorf("expected error, got %q", id)
+	}
+	for _, parts := range [][]string{{"10.0.0.1"}, {render.UncontainedID, "host", "extra"}} {
+		id, err := render.MakePseudoNodeIDChecked(parts...)
+		if err != nil || id != render.MakePseudoNodeID(parts...) {
+			t.Errorf("%v: have {%q, %v}", parts, id, err)
+		}
+		if !render.IsPseudo(id) {
+			t.Errorf("%q: not a pseudonode ID", id)
+		}
+	}
+	for _, id := range []string{render.MakePseudoNodeID(), "pseudo:"} {
+		if render.IsPseudo(id) {
+			t.Errorf("%q: unexpected pseudonode ID", id)
+		}
+	}
+}
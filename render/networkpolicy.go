@@ -0,0 +1,115 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// networkPolicyVerdicts checks the connections between the pods rendered by
+// the renderer it wraps against the network policies the Kubernetes probe
+// reports on namespace nodes. A pod gets the names of the pods it connects
+// to which no policy allows it to in a KubernetesPolicyDenied set, and the
+// names of those which no policy covers in either direction in a
+// KubernetesPolicyUnprotected set.
+type networkPolicyVerdicts struct {
+	Renderer
+}
+
+func (r networkPolicyVerdicts) Render(ctx context.Context, rpt report.Report) Nodes {
+	output := r.Renderer.Render(ctx, rpt)
+	policies, namespaceLabels := networkPolicies(rpt)
+	if len(policies) == 0 {
+		return output
+	}
+
+	pods := map[string]report.NetworkPolicyPod{}
+	for id, n := range output.Nodes {
+		if n.Topology == report.Pod {
+			pods[id] = networkPolicyPod(n, namespaceLabels)
+		}
+	}
+	nodes := make(report.Nodes, len(output.Nodes))
+	for id, n := range output.Nodes {
+		nodes[id] = n
+		src, ok := pods[id]
+		if !ok {
+			continue
+		}
+		var denied, unprotected []string
+		for _, adjacent := range n.Adjacency {
+			dst, ok := pods[adjacent]
+			if !ok || adjacent == id {
+				continue
+			}
+			allowed, covered := report.NetworkPolicyVerdict(policies, src, dst)
+			if !allowed {
+				denied = append(denied, podName(output.Nodes[adjacent]))
+			} else if !covered {
+				unprotected = append(unprotected, podName(output.Nodes[adjacent]))
+			}
+		}
+		if len(denied) > 0 {
+			nodes[id] = nodes[id].WithSet(report.KubernetesPolicyDenied, report.MakeStringSet(denied...))
+		}
+		if len(unprotected) > 0 {
+			nodes[id] = nodes[id].WithSet(report.KubernetesPolicyUnprotected, report.MakeStringSet(unprotected...))
+		}
+	}
+	return Nodes{Nodes: nodes, Filtered: output.Filtered}
+}
+
+// networkPolicies reads the network policies, and the labels of each
+// namespace by name, from the namespace topology.
+func networkPolicies(rpt report.Report) ([]report.NetworkPolicy, map[string]map[string]string) {
+	var policies []report.NetworkPolicy
+	namespaceLabels := map[string]map[string]string{}
+	for _, n := range rpt.Namespace.Nodes {
+		name, _ := n.Latest.Lookup(report.KubernetesName)
+		namespaceLabels[name] = kubernetesLabels(n)
+		rules, ok := n.Latest.Lookup(report.KubernetesNetworkPolicyRules)
+		if !ok {
+			continue
+		}
+		var ps []report.NetworkPolicy
+		if err := json.Unmarshal([]byte(rules), &ps); err != nil {
+			log.Warnf("Ignoring network policies of namespace %s: %v", name, err)
+			continue
+		}
+		policies = append(policies, ps...)
+	}
+	return policies, namespaceLabels
+}
+
+func networkPolicyPod(n report.Node, namespaceLabels map[string]map[string]string) report.NetworkPolicyPod {
+	namespace, _ := n.Latest.Lookup(report.KubernetesNamespace)
+	ip, _ := n.Latest.Lookup(report.KubernetesIP)
+	return report.NetworkPolicyPod{
+		Namespace:       namespace,
+		NamespaceLabels: namespaceLabels[namespace],
+		Labels:          kubernetesLabels(n),
+		IP:              net.ParseIP(ip),
+	}
+}
+
+func kubernetesLabels(n report.Node) map[string]string {
+	labels := map[string]string{}
+	n.Latest.ForEach(func(key string, _ time.Time, value string) {
+		if strings.HasPrefix(key, report.KubernetesLabelPrefix) {
+			labels[strings.TrimPrefix(key, report.KubernetesLabelPrefix)] = value
+		}
+	})
+	return labels
+}
+
+func podName(n report.Node) string {
+	namespace, _ := n.Latest.Lookup(report.KubernetesNamespace)
+	name, _ := n.Latest.Lookup(report.KubernetesName)
+	return namespace + "/" + name
+}
@@ -0,0 +1,89 @@
+package render_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestPodRendererNetworkPolicies(t *testing.T) {
+	server := report.LabelSelector{MatchLabels: map[string]string{"app": "server"}}
+	for i, c := range []struct {
+		policies            []report.NetworkPolicy
+		denied, unprotected []string
+	}{
+		{
+			// Only frontends may connect to the server
+			policies: []report.NetworkPolicy{{
+				Namespace: fixture.KubernetesNamespace, Name: "frontend", PodSelector: server, PolicyIngress: true,
+				Ingress: []report.NetworkPolicyRule{{Peers: []report.NetworkPolicyPeer{{
+					PodSelector: &report.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+				}}}},
+			}},
+			denied: []string{"ping/pong-b"},
+		},
+		{
+			// Clients may connect to the server
+			policies: []report.NetworkPolicy{{
+				Namespace: fixture.KubernetesNamespace, Name: "client", PodSelector: server, PolicyIngress: true,
+				Ingress: []report.NetworkPolicyRule{{Peers: []report.NetworkPolicyPeer{{
+					PodSelector: &report.LabelSelector{MatchExpressions: []report.LabelSelectorRequirement{{Key: "app", Operator: "In", Values: []string{"client"}}}},
+				}}}},
+			}},
+		},
+		{
+			// The client may make no connections
+			policies: []report.NetworkPolicy{{
+				Namespace: fixture.KubernetesNamespace, Name: "no-egress", PodSelector: report.LabelSelector{}, PolicyEgress: true,
+			}},
+			denied: []string{"ping/pong-b"},
+		},
+		{
+			// Policies elsewhere leave the connection unprotected
+			policies:    []report.NetworkPolicy{{Namespace: "other", Name: "deny-all", PolicyIngress: true}},
+			unprotected: []string{"ping/pong-b"},
+		},
+	} {
+		buf, err := json.Marshal(c.policies)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := fixture.Report.Copy()
+		input.ID = "network-policies-" + string(rune('0'+i))
+		input.Pod.Nodes[fixture.ClientPodNodeID] = input.Pod.Nodes[fixture.ClientPodNodeID].WithLatests(map[string]string{
+			report.KubernetesLabelPrefix + "app": "client",
+		})
+		input.Pod.Nodes[fixture.ServerPodNodeID] = input.Pod.Nodes[fixture.ServerPodNodeID].WithLatests(map[string]string{
+			report.KubernetesLabelPrefix + "app": "server",
+		})
+		input.Namespace = report.MakeTopology()
+		input.Namespace.AddNode(report.MakeNodeWith(report.MakeNamespaceNodeID("ping-uid"), map[string]string{
+			report.KubernetesName:               fixture.KubernetesNamespace,
+			report.KubernetesNetworkPolicyRules: string(buf),
+		}))
+
+		client := render.PodRenderer.Render(context.Background(), input).Nodes[fixture.ClientPodNodeID]
+		if have, _ := client.Sets.Lookup(report.KubernetesPolicyDenied); !equalStrings(c.denied, have) {
+			t.Errorf("%d: want denied %v, have %v", i, c.denied, have)
+		}
+		if have, _ := client.Sets.Lookup(report.KubernetesPolicyUnprotected); !equalStrings(c.unprotected, have) {
+			t.Errorf("%d: want unprotected %v, have %v", i, c.unprotected, have)
+		}
+	}
+}
+
+func equalStrings(want []string, have report.StringSet) bool {
+	if len(want) != len(have) {
+		return false
+	}
+	for i := range want {
+		if want[i] != have[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -44,8 +44,9 @@ func isPauseContainer(n report.Node) bool {
 }
 
 // PodRenderer is a Renderer which produces a renderable kubernetes
-// graph by merging the container graph and the pods topology.
-var PodRenderer = Memoise(ConditionalRenderer(renderKubernetesTopologies,
+// graph by merging the container graph and the pods topology. Connections
+// between pods are checked against network policies.
+var PodRenderer = Memoise(networkPolicyVerdicts{ConditionalRenderer(renderKubernetesTopologies,
 	MakeFilter(
 		func(n report.Node) bool {
 			state, ok := n.Latest.Lookup(report.KubernetesState)
@@ -68,7 +69,7 @@ var PodRenderer = Memoise(ConditionalRenderer(renderKubernetesTopologies,
 			KubernetesVolumesRenderer,
 		),
 	),
-))
+)})
 
 // Pods are not tagged with a Host parent, but their container children are.
 // If n doesn't already have a host, copy it from one of the children
@@ -1,6 +1,7 @@
 package render
 
 import (
+	"context"
 	"net"
 	"regexp"
 	"strings"
@@ -14,7 +15,9 @@ var (
 	// ServiceNodeIDPrefix is how the ID of all service pseudo nodes begin
 	ServiceNodeIDPrefix = "service-"
 
-	knownServiceMatcher = regexp.MustCompile(`^.+\.(` + strings.Join([]string{
+	// Domains (as regular expressions) whose hosts are shown as their own
+	// service nodes, rather than as the internet
+	knownServiceDomains = []string{
 		// See http://docs.aws.amazon.com/general/latest/gr/rande.html
 		// for finer grained details
 		`amazonaws\.com`,
@@ -27,7 +30,8 @@ var (
 		`cloudapp\.azure\.com`,     // Azure IaaS
 		`database\.windows\.net`,   // Azure SQL DB
 		`documents\.azure\.com`,    // Azure DocumentDB/CosmosDB
-	}, `|`) + `)$`)
+	}
+	knownServiceMatcher = makeKnownServiceMatcher(knownServiceDomains)
 
 	knownServiceExcluder = regexp.MustCompile(`^(` + strings.Join([]string{
 		// We exclude ec2 machines because they are too generic
@@ -54,7 +58,23 @@ func purgeKnownServiceCache() {
 	knownServiceCache = lru.New(10000)
 }
 
-// TODO: Make it user-customizable https://github.com/weaveworks/scope/issues/1876
+func makeKnownServiceMatcher(domains []string) *regexp.Regexp {
+	return regexp.MustCompile(`^.+\.(` + strings.Join(domains, `|`) + `)$`)
+}
+
+// AddKnownServiceDomains adds DNS domains (e.g. "example.com") whose hosts
+// are rendered as their own nodes, labelled with their hostname, rather than
+// as the internet. It must be called before rendering starts.
+func AddKnownServiceDomains(domains ...string) {
+	for _, domain := range domains {
+		if domain = strings.Trim(strings.TrimSpace(domain), "."); domain != "" {
+			knownServiceDomains = append(knownServiceDomains, regexp.QuoteMeta(domain))
+		}
+	}
+	knownServiceMatcher = makeKnownServiceMatcher(knownServiceDomains)
+	purgeKnownServiceCache()
+}
+
 // NB: this is a hotspot in rendering performance.
 func isKnownService(hostname string) bool {
 	if v, ok := knownServiceCache.Get(hostname); ok {
@@ -67,6 +87,41 @@ func isKnownService(hostname string) bool {
 	return known
 }
 
+// knownServiceDomain returns the known service domain of a service node,
+// e.g. "amazonaws.com" for the node of "s3.eu-west-1.amazonaws.com".
+func knownServiceDomain(id string) (string, bool) {
+	if !strings.HasPrefix(id, ServiceNodeIDPrefix) {
+		return "", false
+	}
+	match := knownServiceMatcher.FindStringSubmatch(id[len(ServiceNodeIDPrefix):])
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// GroupServicesByDomain is a transformer which merges the service nodes of
+// the hosts in each known service domain into one node for the domain, and
+// leaves the other nodes alone.
+var GroupServicesByDomain Transformer = groupServicesByDomain{}
+
+type groupServicesByDomain struct{}
+
+// Transform implements Transformer
+func (groupServicesByDomain) Transform(input Nodes) Nodes {
+	output := newJoinResults(nil)
+	for _, n := range input.Nodes {
+		if domain, ok := knownServiceDomain(n.ID); ok {
+			output.add(n.ID, report.MakeNode(ServiceNodeIDPrefix+domain).WithTopology(Pseudo).WithChildren(n.Children))
+		} else {
+			output.passThrough(n)
+		}
+	}
+	result := output.result(context.Background(), input)
+	result.Filtered = input.Filtered
+	return result
+}
+
 // LocalNetworks returns a superset of the networks (think: CIDRs) that are
 // "local" from the perspective of each host represented in the report. It's
 // used to determine which nodes in the report are "remote", i.e. outside of
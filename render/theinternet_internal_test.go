@@ -0,0 +1,68 @@
+package render
+
+import (
+	"context"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestAddKnownServiceDomains(t *testing.T) {
+	domains, matcher := knownServiceDomains, knownServiceMatcher
+	defer func() {
+		knownServiceDomains, knownServiceMatcher = domains, matcher
+		purgeKnownServiceCache()
+	}()
+
+	var (
+		localID  = report.MakeEndpointNodeID("", "", "10.0.0.1", "35000")
+		remoteID = report.MakeEndpointNodeID("", "", "203.0.113.5", "443")
+	)
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNode(report.MakeHostNodeID("local")).WithSets(report.MakeSets().
+		Add(report.HostLocalNetworks, report.MakeStringSet("10.0.0.1/8"))))
+	rpt.Endpoint.AddNode(report.MakeNode(remoteID).WithTopology(report.Endpoint))
+	rpt.Endpoint.AddNode(report.MakeNode(localID).WithTopology(report.Endpoint).WithAdjacent(remoteID))
+	rpt.DNS = report.DNSRecords{"203.0.113.5": {Forward: report.MakeStringSet("api.corp.example.net")}}
+	renderer := MapEndpoints(func(report.Node) string { return "" }, report.Process)
+
+	if _, ok := renderer.Render(context.Background(), rpt).Nodes[OutgoingInternetID]; !ok {
+		t.Errorf("Expected unknown domain to be rendered as the internet")
+	}
+	AddKnownServiceDomains("corp.example.net.")
+	if _, ok := renderer.Render(context.Background(), rpt).Nodes[ServiceNodeIDPrefix+"api.corp.example.net"]; !ok {
+		t.Errorf("Expected host in added domain to be rendered as a service node")
+	}
+}
+
+func TestGroupServicesByDomain(t *testing.T) {
+	var (
+		client = report.MakeNode("client").WithTopology(report.Process).WithAdjacent(
+			ServiceNodeIDPrefix+"s3.eu-west-1.amazonaws.com",
+			ServiceNodeIDPrefix+"sqs.eu-west-1.amazonaws.com",
+			ServiceNodeIDPrefix+"storage.googleapis.com",
+			OutgoingInternetID,
+		)
+		s3       = report.MakeNode(ServiceNodeIDPrefix + "s3.eu-west-1.amazonaws.com").WithTopology(Pseudo).WithChild(report.MakeNode("s3-endpoint").WithTopology(report.Endpoint))
+		sqs      = report.MakeNode(ServiceNodeIDPrefix + "sqs.eu-west-1.amazonaws.com").WithTopology(Pseudo).WithChild(report.MakeNode("sqs-endpoint").WithTopology(report.Endpoint))
+		gcs      = report.MakeNode(ServiceNodeIDPrefix + "storage.googleapis.com").WithTopology(Pseudo)
+		internet = report.MakeNode(OutgoingInternetID).WithTopology(Pseudo)
+	)
+	input := Nodes{Nodes: report.Nodes{client.ID: client, s3.ID: s3, sqs.ID: sqs, gcs.ID: gcs, internet.ID: internet}, Filtered: 2}
+	have := GroupServicesByDomain.Transform(input)
+
+	aws, ok := have.Nodes[ServiceNodeIDPrefix+"amazonaws.com"]
+	if !ok {
+		t.Fatalf("Expected one node for amazonaws.com, have %v", have.Nodes)
+	}
+	if aws.Children.Size() != 2 {
+		t.Errorf("Expected the children of both amazonaws.com hosts, have %v", aws.Children)
+	}
+	want := report.MakeIDList(ServiceNodeIDPrefix+"amazonaws.com", ServiceNodeIDPrefix+"googleapis.com", OutgoingInternetID)
+	if adjacency := have.Nodes["client"].Adjacency; !adjacency.Equal(want) {
+		t.Errorf("want adjacency %v, have %v", want, adjacency)
+	}
+	if len(have.Nodes) != 4 || have.Filtered != 2 {
+		t.Errorf("Expected client, two domains and the internet, %d filtered, have %v (%d filtered)", 2, have.Nodes, have.Filtered)
+	}
+}
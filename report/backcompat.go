@@ -116,8 +116,8 @@ func (r Report) upgradeNamespaces() Report {
 	for ns := range namespaces {
 		// Namespace ID:
 		// Probes did not use to report namespace ids, but since creating a report node requires an id,
-		// the namespace name, which is unique, is passed to `MakeNamespaceNodeID`
-		namespaceID := MakeNamespaceNodeID(ns)
+		// the namespace name, which is unique, is used as the ID
+		namespaceID := NodeID{Kind: NamespaceNodeIDType, Name: ns}.String()
 		nodes[namespaceID] = MakeNodeWith(namespaceID, map[string]string{KubernetesName: ns})
 	}
 	r.Namespace.Nodes = nodes
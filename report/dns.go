@@ -43,6 +43,21 @@ func (r DNSRecords) Merge(other DNSRecords) DNSRecords {
 	return cp
 }
 
+// UnsafeUnMerge removes records from r that would be added by merging other,
+// modifying the original. Records are merged as sets, so a record is removed
+// if other already has all of its names.
+func (r DNSRecords) UnsafeUnMerge(other DNSRecords) {
+	for k, v := range r {
+		if v2, ok := other[k]; ok && isSubset(v.Forward, v2.Forward) && isSubset(v.Reverse, v2.Reverse) {
+			delete(r, k)
+		}
+	}
+}
+
+func isSubset(s, of StringSet) bool {
+	return len(s.Intersection(of)) == len(s)
+}
+
 // FirstMatch returns the first DNS name where match() returns true
 func (r DNSRecords) FirstMatch(id string, match func(name string) bool) (string, bool) {
 	_, addr, _, ok := ParseEndpointNodeID(id)
@@ -0,0 +1,380 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OneHopNeighbors returns the sorted, unique node IDs directly connected to
+// node, in either direction, by the given edges. Malformed edge IDs are
+// skipped.
+func OneHopNeighbors(node string, edgeIDs []string) []string {
+	var neighbors []string
+	for _, edgeID := range edgeIDs {
+		src, dst, ok := ParseEdgeID(edgeID)
+		if !ok {
+			continue
+		}
+		switch node {
+		case src:
+			neighbors = append(neighbors, dst)
+		case dst:
+			neighbors = append(neighbors, src)
+		}
+	}
+	return []string(MakeStringSet(neighbors...))
+}
+
+// GroupEdgesBySource buckets the destinations of edges under their source
+// node ID, returning the number of malformed edge IDs skipped. Each bucket
+// is sorted.
+func GroupEdgesBySource(edgeIDs []string) (map[string][]string, int) {
+	return groupEdges(edgeIDs, func(src, dst string) (string, string) { return src, dst })
+}
+
+// GroupEdgesByDestination buckets the sources of edges under their
+// destination node ID, returning the number of malformed edge IDs skipped.
+// Each bucket is sorted.
+func GroupEdgesByDestination(edgeIDs []string) (map[string][]string, int) {
+	return groupEdges(edgeIDs, func(src, dst string) (string, string) { return dst, src })
+}
+
+func groupEdges(edgeIDs []string, keyValue func(src, dst string) (string, string)) (map[string][]string, int) {
+	groups, skipped := map[string][]string{}, 0
+	for _, edgeID := range edgeIDs {
+		src, dst, ok := ParseEdgeID(edgeID)
+		if !ok {
+			skipped++
+			continue
+		}
+		key, value := keyValue(src, dst)
+		groups[key] = append(groups[key], value)
+	}
+	for _, values := range groups {
+		sort.Strings(values)
+	}
+	return groups, skipped
+}
+
+// ValidateAdjacencyConsistency checks that every source and target node ID
+// in an adjacency map (source node ID to target node IDs) is recognised by
+// IsRecognizedNodeID, and, unless allowSelfLoops is set, that no node is
+// adjacent to itself. Self loops are legitimate in some topologies, e.g. a
+// process talking to itself over loopback. It returns one error per problem
+// found, in a deterministic order.
+func ValidateAdjacencyConsistency(adjacency map[string][]string, allowSelfLoops bool) []error {
+	var errs []error
+	srcs := make([]string, 0, len(adjacency))
+	for src := range adjacency {
+		srcs = append(srcs, src)
+	}
+	sort.Strings(srcs)
+	for _, src := range srcs {
+		if !IsRecognizedNodeID(src) {
+			errs = append(errs, fmt.Errorf("malformed source node ID %q", src))
+		}
+		for _, dst := range adjacency[src] {
+			switch {
+			case !IsRecognizedNodeID(dst):
+				errs = append(errs, fmt.Errorf("source %q: malformed target node ID %q", src, dst))
+			case dst == src && !allowSelfLoops:
+				errs = append(errs, fmt.Errorf("source %q: adjacent to itself", src))
+			}
+		}
+	}
+	return errs
+}
+
+// MergeAdjacency unions two adjacency maps (source node ID to target node
+// IDs), as when combining reports from several app instances. The targets of
+// each source are deduplicated and sorted. Sources and targets not
+// recognised by IsRecognizedNodeID are left out of the result, and listed
+// in the returned error; the rest of the merge still stands.
+func MergeAdjacency(a, b map[string][]string) (map[string][]string, error) {
+	merged := make(map[string][]string, len(a)+len(b))
+	var malformed []string
+	for _, adjacency := range []map[string][]string{a, b} {
+		for src, dsts := range adjacency {
+			if !IsRecognizedNodeID(src) {
+				malformed = append(malformed, src)
+				continue
+			}
+			targets := merged[src]
+			for _, dst := range dsts {
+				if !IsRecognizedNodeID(dst) {
+					malformed = append(malformed, dst)
+					continue
+				}
+				targets = append(targets, dst)
+			}
+			merged[src] = targets
+		}
+	}
+	for src, targets := range merged {
+		merged[src] = []string(MakeStringSet(targets...))
+	}
+	if len(malformed) > 0 {
+		quoted := make([]string, 0, len(malformed))
+		for _, id := range MakeStringSet(malformed...) {
+			quoted = append(quoted, fmt.Sprintf("%q", id))
+		}
+		return merged, fmt.Errorf("malformed node IDs in adjacency: %s", strings.Join(quoted, ", "))
+	}
+	return merged, nil
+}
+
+// ReverseAdjacency turns an adjacency map (source node ID to target node
+// IDs) around, mapping each target to the sources adjacent to it, for
+// who-connects-to-me views. Sources and targets not recognised by
+// IsRecognizedNodeID are skipped. The sources of each target are
+// deduplicated and sorted.
+func ReverseAdjacency(adjacency map[string][]string) map[string][]string {
+	reversed := map[string][]string{}
+	for src, dsts := range adjacency {
+		if !IsRecognizedNodeID(src) {
+			continue
+		}
+		for _, dst := range dsts {
+			if IsRecognizedNodeID(dst) {
+				reversed[dst] = append(reversed[dst], src)
+			}
+		}
+	}
+	for dst, srcs := range reversed {
+		reversed[dst] = []string(MakeStringSet(srcs...))
+	}
+	return reversed
+}
+
+// CanonicalizeAdjacency rewrites every source and target node ID in an
+// adjacency map (source node ID to target node IDs) with a chain of
+// normalizers, such as NormalizeEndpointNodeID. Each normalizer is applied
+// in turn to the output of the last, and an ID is left as it is by a
+// normalizer returning false. Sources which normalize to the same ID have
+// their targets merged; the targets of each source are deduplicated and
+// sorted.
+func CanonicalizeAdjacency(adjacency map[string][]string, normalizers ...func(string) (string, bool)) map[string][]string {
+	normalize := func(id string) string {
+		for _, normalizer := range normalizers {
+			if normalized, ok := normalizer(id); ok {
+				id = normalized
+			}
+		}
+		return id
+	}
+	canonical := make(map[string][]string, len(adjacency))
+	for src, dsts := range adjacency {
+		src = normalize(src)
+		targets := canonical[src]
+		for _, dst := range dsts {
+			targets = append(targets, normalize(dst))
+		}
+		canonical[src] = targets
+	}
+	for src, targets := range canonical {
+		canonical[src] = []string(MakeStringSet(targets...))
+	}
+	return canonical
+}
+
+// IsListeningEndpoint determines whether an endpoint node ID is for a
+// listening socket rather than one end of a connection: it binds an address,
+// either the wildcard address or a specific local one (i.e. it is scoped by
+// a host), but has no outgoing adjacency (source node ID to target node IDs)
+// to a remote peer. Unscoped endpoints can't be told apart from the remote
+// end of a connection, so they never count as listening.
+func IsListeningEndpoint(id string, adjacency map[string][]string) bool {
+	if EndpointIDAddresser(id) == nil || len(adjacency[id]) != 0 {
+		return false
+	}
+	if IsWildcardEndpoint(id) {
+		return true
+	}
+	scope, _, _, ok := ParseEndpointNodeID(id)
+	return ok && scope != ""
+}
+
+// ValidTopologyEdge checks that the nodes at the ends of an edge belong in
+// topologies (as determined by TopologyForNodeID) which may be connected:
+// allowed maps the topology of the source to the topologies it may have
+// edges to.
+func ValidTopologyEdge(edgeID string, allowed map[string]map[string]bool) error {
+	src, dst, ok := ParseEdgeID(edgeID)
+	if !ok {
+		return fmt.Errorf("malformed edge ID %q", edgeID)
+	}
+	srcTopology, ok := TopologyForNodeID(src)
+	if !ok {
+		return fmt.Errorf("edge %q: source %q belongs in no topology", edgeID, src)
+	}
+	dstTopology, ok := TopologyForNodeID(dst)
+	if !ok {
+		return fmt.Errorf("edge %q: destination %q belongs in no topology", edgeID, dst)
+	}
+	if !allowed[srcTopology][dstTopology] {
+		return fmt.Errorf("edge %q: %s nodes may not connect to %s nodes", edgeID, srcTopology, dstTopology)
+	}
+	return nil
+}
+
+// NodeDegrees counts the total (in plus out) degree of every node in a list
+// of edge IDs. Malformed edge IDs are skipped.
+func NodeDegrees(edgeIDs []string) map[string]int {
+	return countDegrees(edgeIDs, true, true)
+}
+
+// InDegrees counts the in-degree of every node with incoming edges in a list
+// of edge IDs. Malformed edge IDs are skipped.
+func InDegrees(edgeIDs []string) map[string]int {
+	return countDegrees(edgeIDs, false, true)
+}
+
+// OutDegrees counts the out-degree of every node with outgoing edges in a
+// list of edge IDs. Malformed edge IDs are skipped.
+func OutDegrees(edgeIDs []string) map[string]int {
+	return countDegrees(edgeIDs, true, false)
+}
+
+func countDegrees(edgeIDs []string, out, in bool) map[string]int {
+	degrees := map[string]int{}
+	for _, edgeID := range edgeIDs {
+		src, dst, ok := ParseEdgeID(edgeID)
+		if !ok {
+			continue
+		}
+		if out {
+			degrees[src]++
+		}
+		if in {
+			degrees[dst]++
+		}
+	}
+	return degrees
+}
+
+// ConnectionKey produces a key identifying the connection between two
+// endpoint node IDs regardless of direction: ephemeral ports (in the default
+// range) are coalesced as by CoalesceEphemeralPort and the endpoints put in
+// a canonical order, so A→B and B→A get the same key. It returns false if
+// either ID is not an endpoint node ID.
+func ConnectionKey(srcEndpointID, dstEndpointID string) (string, bool) {
+	if _, _, _, ok := ParseEndpointNodeID(srcEndpointID); !ok {
+		return "", false
+	}
+	if _, _, _, ok := ParseEndpointNodeID(dstEndpointID); !ok {
+		return "", false
+	}
+	a, _ := CoalesceEphemeralPort(srcEndpointID, EphemeralPortLow, EphemeralPortHigh)
+	b, _ := CoalesceEphemeralPort(dstEndpointID, EphemeralPortLow, EphemeralPortHigh)
+	if b < a {
+		a, b = b, a
+	}
+	return MakeEdgeID(a, b), true
+}
+
+// DropEphemeralClientEndpoints removes the edges whose source is an endpoint
+// with a port in [low, high], i.e. a client connecting out, for a
+// server-centric view. All other edge IDs, including malformed ones, are
+// kept in order.
+func DropEphemeralClientEndpoints(edgeIDs []string, low, high int) []string {
+	kept := make([]string, 0, len(edgeIDs))
+	for _, edgeID := range edgeIDs {
+		if src, _, ok := ParseEdgeID(edgeID); ok {
+			if _, ephemeral := CoalesceEphemeralPort(src, low, high); ephemeral {
+				continue
+			}
+		}
+		kept = append(kept, edgeID)
+	}
+	return kept
+}
+
+// IsCrossHostEdge determines whether an edge between two endpoint node IDs
+// crosses host boundaries. Unscoped (public) endpoints are taken to be on
+// another host. ok is false if edgeID is malformed or either end is not an
+// endpoint node ID.
+func IsCrossHostEdge(edgeID string) (cross bool, ok bool) {
+	src, dst, ok := ParseEdgeID(edgeID)
+	if !ok {
+		return false, false
+	}
+	srcHost, _, _, okSrc := ParseEndpointNodeID(src)
+	dstHost, _, _, okDst := ParseEndpointNodeID(dst)
+	if !okSrc || !okDst {
+		return false, false
+	}
+	return srcHost == "" || dstHost == "" || srcHost != dstHost, true
+}
+
+// ConnectedComponents groups nodes into sets which are mutually reachable,
+// ignoring edge direction. Nodes at the ends of edges are included even if
+// not listed in nodeIDs, and isolated nodes form components of their own.
+// Malformed edge IDs are skipped. Each component is sorted, and components
+// are ordered by their first node ID.
+func ConnectedComponents(nodeIDs, edgeIDs []string) [][]string {
+	parent := map[string]string{}
+	var find func(string) string
+	find = func(id string) string {
+		p, ok := parent[id]
+		if !ok {
+			parent[id] = id
+			return id
+		}
+		if p == id {
+			return id
+		}
+		root := find(p)
+		parent[id] = root
+		return root
+	}
+
+	for _, id := range nodeIDs {
+		find(id)
+	}
+	for _, edgeID := range edgeIDs {
+		src, dst, ok := ParseEdgeID(edgeID)
+		if !ok {
+			continue
+		}
+		if a, b := find(src), find(dst); a != b {
+			parent[a] = b
+		}
+	}
+
+	byRoot := map[string][]string{}
+	for id := range parent {
+		root := find(id)
+		byRoot[root] = append(byRoot[root], id)
+	}
+	components := make([][]string, 0, len(byRoot))
+	for _, component := range byRoot {
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	return components
+}
+
+// CanonicalizeEndpointEdgeDirection orders an edge between two endpoint node
+// IDs from client to server, taking the end with a port in the default
+// ephemeral range to be the client. It returns false if the edge is
+// malformed, or if neither or both ends have ephemeral ports.
+func CanonicalizeEndpointEdgeDirection(edgeID string) (string, bool) {
+	src, dst, ok := ParseEdgeID(edgeID)
+	if !ok {
+		return "", false
+	}
+	_, _, srcPort, okSrc := ParseEndpointNodeID(src)
+	_, _, dstPort, okDst := ParseEndpointNodeID(dst)
+	if !okSrc || !okDst {
+		return "", false
+	}
+	switch srcEphemeral, dstEphemeral := isEphemeralPort(srcPort), isEphemeralPort(dstPort); {
+	case srcEphemeral && !dstEphemeral:
+		return edgeID, true
+	case dstEphemeral && !srcEphemeral:
+		return MakeEdgeID(dst, src), true
+	}
+	return "", false
+}
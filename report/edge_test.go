@@ -0,0 +1,330 @@
+package report_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestOneHopNeighbors(t *testing.T) {
+	edgeIDs := []string{
+		report.MakeEdgeID("a", "b"),
+		report.MakeEdgeID("c", "a"),
+		report.MakeEdgeID("a", "b"),
+		report.MakeEdgeID("b", "d"),
+		"malformed",
+	}
+	if have, want := report.OneHopNeighbors("a", edgeIDs), []string{"b", "c"}; !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if have, want := report.OneHopNeighbors("d", edgeIDs), []string{"b"}; !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestGroupEdges(t *testing.T) {
+	edgeIDs := []string{
+		report.MakeEdgeID("c", "x"),
+		report.MakeEdgeID("a", "x"),
+		report.MakeEdgeID("b", "y"),
+		report.MakeEdgeID("a", "y"),
+		"malformed",
+	}
+
+	byDst, skipped := report.GroupEdgesByDestination(edgeIDs)
+	if want := map[string][]string{"x": {"a", "c"}, "y": {"a", "b"}}; !reflect.DeepEqual(want, byDst) {
+		t.Errorf("by destination: want %v, have %v", want, byDst)
+	}
+	if skipped != 1 {
+		t.Errorf("by destination: want 1 skipped, have %d", skipped)
+	}
+
+	bySrc, skipped := report.GroupEdgesBySource(edgeIDs)
+	if want := map[string][]string{"a": {"x", "y"}, "b": {"y"}, "c": {"x"}}; !reflect.DeepEqual(want, bySrc) {
+		t.Errorf("by source: want %v, have %v", want, bySrc)
+	}
+	if skipped != 1 {
+		t.Errorf("by source: want 1 skipped, have %d", skipped)
+	}
+}
+
+func TestValidateAdjacencyConsistency(t *testing.T) {
+	clean := map[string][]string{
+		client54001EndpointNodeID: {server80EndpointNodeID},
+		client54002EndpointNodeID: {server80EndpointNodeID},
+		server80EndpointNodeID:    {},
+	}
+	if errs := report.ValidateAdjacencyConsistency(clean, false); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	bad := map[string][]string{
+		client54001EndpointNodeID: {server80EndpointNodeID, "garbage"},
+		server80EndpointNodeID:    {server80EndpointNodeID},
+		"alsogarbage":             {server80EndpointNodeID},
+	}
+	errs := report.ValidateAdjacencyConsistency(bad, false)
+	if len(errs) != 3 {
+		t.Fatalf("want 3 errors, have %v", errs)
+	}
+	for i, want := range []string{"adjacent to itself", "target node ID \"garbage\"", "alsogarbage"} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("error %d: want %q, have %v", i, want, errs[i])
+		}
+	}
+
+	// Allowing self loops only leaves the malformed IDs
+	errs = report.ValidateAdjacencyConsistency(bad, true)
+	if len(errs) != 2 {
+		t.Fatalf("want 2 errors, have %v", errs)
+	}
+	for i, want := range []string{"target node ID \"garbage\"", "alsogarbage"} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("error %d: want %q, have %v", i, want, errs[i])
+		}
+	}
+	loop := map[string][]string{server80EndpointNodeID: {server80EndpointNodeID}}
+	if errs := report.ValidateAdjacencyConsistency(loop, true); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestMergeAdjacency(t *testing.T) {
+	a := map[string][]string{
+		client54001EndpointNodeID: {server80EndpointNodeID},
+	}
+	b := map[string][]string{
+		client54002EndpointNodeID: {server80EndpointNodeID},
+	}
+	have, err := report.MergeAdjacency(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		client54001EndpointNodeID: {server80EndpointNodeID},
+		client54002EndpointNodeID: {server80EndpointNodeID},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	b = map[string][]string{
+		client54001EndpointNodeID: {server80EndpointNodeID, clientHostNodeID, "garbage"},
+		"alsogarbage":             {server80EndpointNodeID},
+	}
+	have, err = report.MergeAdjacency(a, b)
+	if err == nil {
+		t.Fatal("expected error for malformed node IDs")
+	}
+	for _, id := range []string{"garbage", "alsogarbage"} {
+		if !strings.Contains(err.Error(), `"`+id+`"`) {
+			t.Errorf("error does not list %q: %v", id, err)
+		}
+	}
+	want = map[string][]string{
+		client54001EndpointNodeID: {server80EndpointNodeID, clientHostNodeID},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestReverseAdjacency(t *testing.T) {
+	adjacency := map[string][]string{
+		client54001EndpointNodeID: {server80EndpointNodeID, server80EndpointNodeID},
+		client54002EndpointNodeID: {server80EndpointNodeID, "garbage"},
+		server80EndpointNodeID:    {client54001EndpointNodeID},
+		"alsogarbage":             {server80EndpointNodeID},
+	}
+	want := map[string][]string{
+		server80EndpointNodeID:    {client54001EndpointNodeID, client54002EndpointNodeID},
+		client54001EndpointNodeID: {server80EndpointNodeID},
+	}
+	if have := report.ReverseAdjacency(adjacency); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestCanonicalizeAdjacency(t *testing.T) {
+	mapped := report.MakeScopedEndpointNodeID("", "::ffff:"+clientAddress, "54001")
+	server443 := report.MakeEndpointNodeID(serverHostID, "", serverAddress, "443")
+	adjacency := map[string][]string{
+		client54001EndpointNodeID: {server80EndpointNodeID},
+		mapped:                    {server443, report.MakeScopedEndpointNodeID("", "::ffff:"+serverAddress, "80")},
+		clientHostNodeID:          {serverHostNodeID},
+	}
+	upper := func(id string) (string, bool) {
+		if id != clientHostNodeID {
+			return id, false
+		}
+		return strings.ToUpper(id), true
+	}
+	want := map[string][]string{
+		client54001EndpointNodeID:         {server443, server80EndpointNodeID},
+		strings.ToUpper(clientHostNodeID): {serverHostNodeID},
+	}
+	if have := report.CanonicalizeAdjacency(adjacency, report.NormalizeEndpointNodeID, upper); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestIsListeningEndpoint(t *testing.T) {
+	listener := report.MakeWildcardEndpointNodeID(serverHostID, "443")
+	local := report.MakeScopedEndpointNodeID(serverHostID, serverAddress, "8080")
+	remote := report.MakeScopedEndpointNodeID("", "8.8.8.8", "80")
+	adjacency := map[string][]string{
+		client54001EndpointNodeID: {server80EndpointNodeID},
+		listener:                  {},
+	}
+	for id, want := range map[string]bool{
+		listener:                  true,
+		local:                     true,
+		server80EndpointNodeID:    false,
+		remote:                    false,
+		client54001EndpointNodeID: false,
+		serverHostNodeID:          false,
+	} {
+		if have := report.IsListeningEndpoint(id, adjacency); have != want {
+			t.Errorf("%q: want %v, have %v", id, want, have)
+		}
+	}
+}
+
+func TestDropEphemeralClientEndpoints(t *testing.T) {
+	server8080 := report.MakeEndpointNodeID(serverHostID, "", serverAddress, "8080")
+	edgeIDs := []string{
+		report.MakeEdgeID(client54001EndpointNodeID, server80EndpointNodeID),
+		report.MakeEdgeID(server80EndpointNodeID, server8080),
+		report.MakeEdgeID(client54002EndpointNodeID, server80EndpointNodeID),
+		report.MakeEdgeID(server80EndpointNodeID, client54001EndpointNodeID),
+	}
+	want := []string{
+		report.MakeEdgeID(server80EndpointNodeID, server8080),
+		report.MakeEdgeID(server80EndpointNodeID, client54001EndpointNodeID),
+	}
+	if have := report.DropEphemeralClientEndpoints(edgeIDs, 32768, 60999); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestValidTopologyEdge(t *testing.T) {
+	allowed := map[string]map[string]bool{
+		report.Endpoint:  {report.Endpoint: true},
+		report.Container: {report.Container: true},
+	}
+	if err := report.ValidTopologyEdge(report.MakeEdgeID(client54001EndpointNodeID, server80EndpointNodeID), allowed); err != nil {
+		t.Errorf("endpoint to endpoint: %v", err)
+	}
+
+	for _, edgeID := range []string{
+		report.MakeEdgeID(client54001EndpointNodeID, report.MakeContainerNodeID("abc")),
+		report.MakeEdgeID(report.MakeContainerNodeID("abc"), client54001EndpointNodeID),
+		report.MakeEdgeID(clientHostNodeID, serverHostNodeID),
+		report.MakeEdgeID(client54001EndpointNodeID, "garbage"),
+		"malformed",
+	} {
+		if err := report.ValidTopologyEdge(edgeID, allowed); err == nil {
+			t.Errorf("%q: expected error", edgeID)
+		}
+	}
+	err := report.ValidTopologyEdge(report.MakeEdgeID(client54001EndpointNodeID, report.MakeContainerNodeID("abc")), allowed)
+	if err == nil || !strings.Contains(err.Error(), "endpoint nodes may not connect to container nodes") {
+		t.Errorf("undescriptive error: %v", err)
+	}
+}
+
+func TestNodeDegrees(t *testing.T) {
+	edgeIDs := []string{
+		report.MakeEdgeID("a", "b"),
+		report.MakeEdgeID("a", "c"),
+		report.MakeEdgeID("b", "c"),
+		report.MakeEdgeID("c", "a"),
+		"malformed",
+	}
+	if want, have := map[string]int{"a": 3, "b": 2, "c": 3}, report.NodeDegrees(edgeIDs); !reflect.DeepEqual(want, have) {
+		t.Errorf("total: want %v, have %v", want, have)
+	}
+	if want, have := map[string]int{"a": 1, "b": 1, "c": 2}, report.InDegrees(edgeIDs); !reflect.DeepEqual(want, have) {
+		t.Errorf("in: want %v, have %v", want, have)
+	}
+	if want, have := map[string]int{"a": 2, "b": 1, "c": 1}, report.OutDegrees(edgeIDs); !reflect.DeepEqual(want, have) {
+		t.Errorf("out: want %v, have %v", want, have)
+	}
+}
+
+func TestConnectionKey(t *testing.T) {
+	forward, okForward := report.ConnectionKey(client54001EndpointNodeID, server80EndpointNodeID)
+	backward, okBackward := report.ConnectionKey(server80EndpointNodeID, client54001EndpointNodeID)
+	if !okForward || !okBackward || forward != backward {
+		t.Errorf("have %q (%v) and %q (%v)", forward, okForward, backward, okBackward)
+	}
+	if other, _ := report.ConnectionKey(client54002EndpointNodeID, server80EndpointNodeID); other != forward {
+		t.Errorf("ephemeral ports not dropped: %q vs %q", other, forward)
+	}
+	if other, _ := report.ConnectionKey(report.MakeEndpointNodeID("", "", clientAddress, "22"), server80EndpointNodeID); other == forward {
+		t.Errorf("non-ephemeral port dropped: %q", other)
+	}
+	if _, ok := report.ConnectionKey(clientAddressNodeID, server80EndpointNodeID); ok {
+		t.Errorf("expected failure for an address node ID")
+	}
+}
+
+func TestIsCrossHostEdge(t *testing.T) {
+	local80 := report.MakeEndpointNodeID(serverHostID, "", "127.0.0.1", "80")
+	local5432 := report.MakeEndpointNodeID(serverHostID, "", "127.0.0.1", "5432")
+	remote := report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "5432")
+	for edgeID, want := range map[string]bool{
+		report.MakeEdgeID(local80, local5432):                                false,
+		report.MakeEdgeID(local80, remote):                                   true,
+		report.MakeEdgeID(local80, server80EndpointNodeID):                   true,
+		report.MakeEdgeID(client54001EndpointNodeID, server80EndpointNodeID): true,
+	} {
+		if cross, ok := report.IsCrossHostEdge(edgeID); !ok || cross != want {
+			t.Errorf("IsCrossHostEdge(%q) = (%v, %v), want %v", edgeID, cross, ok, want)
+		}
+	}
+	for _, bad := range []string{"malformed", report.MakeEdgeID(local80, clientHostNodeID)} {
+		if _, ok := report.IsCrossHostEdge(bad); ok {
+			t.Errorf("%q: expected failure", bad)
+		}
+	}
+}
+
+func TestConnectedComponents(t *testing.T) {
+	have := report.ConnectedComponents(
+		[]string{"a", "b", "c", "d", "e", "isolated"},
+		[]string{
+			report.MakeEdgeID("a", "b"),
+			report.MakeEdgeID("c", "b"),
+			report.MakeEdgeID("d", "e"),
+			report.MakeEdgeID("e", "d"),
+			"malformed",
+		},
+	)
+	want := [][]string{{"a", "b", "c"}, {"d", "e"}, {"isolated"}}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestCanonicalizeEndpointEdgeDirection(t *testing.T) {
+	want := report.MakeEdgeID(client54001EndpointNodeID, server80EndpointNodeID)
+	for _, edgeID := range []string{want, report.MakeEdgeID(server80EndpointNodeID, client54001EndpointNodeID)} {
+		if have, ok := report.CanonicalizeEndpointEdgeDirection(edgeID); !ok || have != want {
+			t.Errorf("%q: want %q, have {%q, %v}", edgeID, want, have, ok)
+		}
+	}
+
+	for _, ambiguous := range []string{
+		report.MakeEdgeID(client54001EndpointNodeID, client54002EndpointNodeID),
+		report.MakeEdgeID(server80EndpointNodeID, report.MakeEndpointNodeID("", "", clientAddress, "443")),
+		report.MakeEdgeID(client54001EndpointNodeID, serverHostNodeID),
+		"malformed",
+	} {
+		if have, ok := report.CanonicalizeEndpointEdgeDirection(ambiguous); ok {
+			t.Errorf("%q: expected failure, have %q", ambiguous, have)
+		}
+	}
+}
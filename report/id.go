@@ -1,7 +1,13 @@
 package report
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
 	"net"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -18,6 +24,32 @@ const (
 	// Concretely, it separates node IDs in keys that represent edges.
 	EdgeDelim = "|"
 
+	// WeightDelim separates an edge ID from its weight in weighted edge IDs.
+	WeightDelim = "^"
+
+	// TimestampDelim separates a node ID from the snapshot time in
+	// timestamped node IDs.
+	TimestampDelim = "@"
+
+	// AdjacencyPrefix marks adjacency IDs, which refer to the node ID they
+	// are made from.
+	AdjacencyPrefix = ">"
+
+	// NameDelim separates the address from the DNS name in named address
+	// node IDs.
+	NameDelim = "#"
+
+	// TopologyDelim separates the topology from the node ID in qualified
+	// IDs.
+	TopologyDelim = "!"
+
+	// LineageDelim separates the node IDs in lineage IDs.
+	LineageDelim = "~"
+
+	// L7Delim separates the port from the application protocol in L7
+	// endpoint node IDs.
+	L7Delim = "/"
+
 	// Key added to nodes to prevent them being joined with conntracked connections
 	DoesNotMakeConnections = "does_not_make_connections"
 
@@ -26,14 +58,157 @@ const (
 
 	// DockerOverlayPeerPrefix is the prefix for docker peers in the overlay network
 	DockerOverlayPeerPrefix = "docker_peer_"
+
+	// EphemeralPort replaces the port of endpoint node IDs which have been
+	// coalesced by CoalesceEphemeralPort
+	EphemeralPort = "ephemeral"
+
+	// RedactedPort replaces the port of endpoint node IDs which have been
+	// redacted by RedactPort
+	RedactedPort = "redacted"
+)
+
+// The default range of ephemeral ports, as used by Linux
+// (net.ipv4.ip_local_port_range).
+const (
+	EphemeralPortLow  = 32768
+	EphemeralPortHigh = 60999
 )
 
+// MakeEdgeID produces an edge ID from the node IDs at either end.
+func MakeEdgeID(srcNodeID, dstNodeID string) string {
+	return srcNodeID + EdgeDelim + dstNodeID
+}
+
+// ParseEdgeID splits an edge ID into its source and destination node IDs.
+func ParseEdgeID(edgeID string) (srcNodeID, dstNodeID string, ok bool) {
+	return split2(edgeID, EdgeDelim)
+}
+
+// MakeAdjacencyID produces an adjacency ID from a node ID.
+func MakeAdjacencyID(nodeID string) string {
+	return AdjacencyPrefix + nodeID
+}
+
+// ParseAdjacencyID produces the node ID from an adjacency ID.
+func ParseAdjacencyID(adjacencyID string) (string, bool) {
+	if !strings.HasPrefix(adjacencyID, AdjacencyPrefix) {
+		return "", false
+	}
+	return adjacencyID[len(AdjacencyPrefix):], true
+}
+
+// EdgeToAdjacencyIDs produces the adjacency IDs of both ends of an edge.
+func EdgeToAdjacencyIDs(edgeID string) (srcAdjacencyID, dstAdjacencyID string, ok bool) {
+	src, dst, ok := ParseEdgeID(edgeID)
+	if !ok {
+		return "", "", false
+	}
+	return MakeAdjacencyID(src), MakeAdjacencyID(dst), true
+}
+
+// AdjacencyIDsToEdge produces the edge ID between the nodes of two
+// adjacency IDs.
+func AdjacencyIDsToEdge(srcAdjacencyID, dstAdjacencyID string) (string, bool) {
+	src, ok := ParseAdjacencyID(srcAdjacencyID)
+	if !ok {
+		return "", false
+	}
+	dst, ok := ParseAdjacencyID(dstAdjacencyID)
+	if !ok {
+		return "", false
+	}
+	return MakeEdgeID(src, dst), true
+}
+
+// MakeWeightedEdgeID produces an edge ID carrying a numeric weight.
+func MakeWeightedEdgeID(srcNodeID, dstNodeID string, weight int) string {
+	return MakeEdgeID(srcNodeID, dstNodeID) + WeightDelim + strconv.Itoa(weight)
+}
+
+// ParseWeightedEdgeID splits a weighted edge ID into its source and
+// destination node IDs and its weight.
+func ParseWeightedEdgeID(edgeID string) (srcNodeID, dstNodeID string, weight int, ok bool) {
+	// The weight is always last, so node IDs containing WeightDelim are fine
+	pos := strings.LastIndex(edgeID, WeightDelim)
+	if pos == -1 {
+		return "", "", 0, false
+	}
+	weight, err := strconv.Atoi(edgeID[pos+len(WeightDelim):])
+	if err != nil {
+		return "", "", 0, false
+	}
+	srcNodeID, dstNodeID, ok = ParseEdgeID(edgeID[:pos])
+	if !ok {
+		return "", "", 0, false
+	}
+	return srcNodeID, dstNodeID, weight, true
+}
+
 // MakeEndpointNodeID produces an endpoint node ID from its composite parts.
 func MakeEndpointNodeID(hostID, namespaceID, address, port string) string {
-	addressIP := net.ParseIP(address)
+	addressIP := parseZonedIP(address)
 	return makeAddressID(hostID, namespaceID, address, addressIP) + ScopeDelim + port
 }
 
+// MakeL7EndpointNodeID is like MakeEndpointNodeID, but additionally carries
+// the application protocol (e.g. "http" or "grpc") of the connection. The
+// protocol is appended to the port after L7Delim; ParseEndpointNodeID drops
+// it again, so the port helpers and EndpointIDAddresser see the plain port.
+func MakeL7EndpointNodeID(hostID, namespaceID, address, port, l7proto string) string {
+	return withL7Proto(MakeEndpointNodeID(hostID, namespaceID, address, port), l7proto)
+}
+
+func withL7Proto(id, l7proto string) string {
+	if l7proto == "" {
+		return id
+	}
+	return id + L7Delim + l7proto
+}
+
+// ParseL7EndpointNodeID produces the scope, address, port and application
+// protocol from an endpoint node ID. The protocol is blank for plain
+// endpoint node IDs.
+func ParseL7EndpointNodeID(endpointNodeID string) (scope, address, port, l7proto string, ok bool) {
+	scope, address, port, ok = parseEndpointNodeID(endpointNodeID)
+	if !ok {
+		return "", "", "", "", false
+	}
+	port, l7proto = splitL7Proto(port)
+	return scope, address, port, l7proto, true
+}
+
+func splitL7Proto(port string) (string, string) {
+	if pos := strings.Index(port, L7Delim); pos != -1 {
+		return port[:pos], port[pos+len(L7Delim):]
+	}
+	return port, ""
+}
+
+// MakeEndpointNodeIDChecked is like MakeEndpointNodeID, but normalizes the
+// port with SanitizePort, returning false if it is invalid.
+func MakeEndpointNodeIDChecked(hostID, namespaceID, address, port string) (string, bool) {
+	port, ok := SanitizePort(port)
+	if !ok {
+		return "", false
+	}
+	return MakeEndpointNodeID(hostID, namespaceID, address, port), true
+}
+
+// SanitizePort normalizes a port number, stripping leading zeros so that
+// "080" and "80" produce the same node ID. It returns false if port is not
+// a number in the range 1-65535.
+func SanitizePort(port string) (string, bool) {
+	if !isDigits(port) {
+		return "", false
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || p == 0 {
+		return "", false
+	}
+	return strconv.FormatUint(p, 10), true
+}
+
 // MakeEndpointNodeIDB produces an endpoint node ID from its composite parts in binary, not strings.
 func MakeEndpointNodeIDB(hostID string, namespaceID uint32, addressIP net.IP, port uint16) string {
 	namespace := ""
@@ -45,17 +220,32 @@ func MakeEndpointNodeIDB(hostID string, namespaceID uint32, addressIP net.IP, po
 
 // MakeAddressNodeID produces an address node ID from its composite parts.
 func MakeAddressNodeID(hostID, address string) string {
-	addressIP := net.ParseIP(address)
+	addressIP := parseZonedIP(address)
 	return makeAddressID(hostID, "", address, addressIP)
 }
 
+// MakeNetnsAddressNodeID is like MakeAddressNodeID, but scopes loopback
+// addresses by network namespace as well as host, like MakeEndpointNodeID,
+// so that loopback addresses in different container network namespaces
+// stay distinct. Use MakeAddressNodeID for the host network namespace.
+func MakeNetnsAddressNodeID(hostID, netns, address string) string {
+	return makeAddressID(hostID, netns, address, parseZonedIP(address))
+}
+
 // MakeAddressNodeIDB produces an address node ID from its composite parts, in binary not string.
 func MakeAddressNodeIDB(hostID string, addressIP net.IP) string {
 	return makeAddressID(hostID, "", addressIP.String(), addressIP)
 }
 
+// MakeAddressNodeIDWithName is like MakeAddressNodeID, but additionally
+// carries a DNS name for the address.
+func MakeAddressNodeIDWithName(hostID, address, name string) string {
+	return MakeAddressNodeID(hostID, address) + NameDelim + name
+}
+
 func makeAddressID(hostID, namespaceID, address string, addressIP net.IP) string {
 	var scope string
+	address = unbracketAddress(address)
 
 	// Loopback addresses and addresses explicitly marked as local get
 	// scoped by hostID
@@ -79,17 +269,124 @@ func MakeScopedEndpointNodeID(scope, address, port string) string {
 	return scope + ScopeDelim + address + ScopeDelim + port
 }
 
+// containerScopePrefix marks the scope of container-scoped endpoint node IDs.
+const containerScopePrefix = "<container>"
+
+// MakeContainerScopedEndpointNodeID produces an endpoint node ID scoped by
+// the container it belongs to rather than by host. The layout is
+// "<container>containerID;address;port", so it still parses with
+// ParseEndpointNodeID.
+func MakeContainerScopedEndpointNodeID(containerID, address, port string) string {
+	return MakeScopedEndpointNodeID(containerScopePrefix+containerID, address, port)
+}
+
+// ContainerIDFromEndpoint extracts the container ID from a container-scoped
+// endpoint node ID. It returns false for any other ID.
+func ContainerIDFromEndpoint(id string) (containerID string, ok bool) {
+	scope, _, _, ok := ParseEndpointNodeID(id)
+	if !ok || !strings.HasPrefix(scope, containerScopePrefix) {
+		return "", false
+	}
+	return scope[len(containerScopePrefix):], true
+}
+
+// MakeWildcardEndpointNodeID produces the canonical node ID for a socket
+// listening on all interfaces of a host. It is always scoped by host, since
+// the same port may be listened on by every host.
+func MakeWildcardEndpointNodeID(hostID, port string) string {
+	return MakeScopedEndpointNodeID(hostID, net.IPv4zero.String(), port)
+}
+
+// IsWildcardEndpoint determines whether an endpoint node ID is for a socket
+// bound to all interfaces, i.e. to 0.0.0.0 or ::.
+func IsWildcardEndpoint(id string) bool {
+	ip := EndpointIDAddresser(id)
+	return ip != nil && ip.IsUnspecified()
+}
+
+// MakeEndpointNodeIDBoth produces both the host-scoped and the unscoped
+// endpoint node IDs for an address, whether or not the address would
+// normally be scoped. This allows a loopback endpoint on one side of a NAT
+// to be matched to the forwarded public endpoint on the other side.
+func MakeEndpointNodeIDBoth(hostID, address, port string) (scoped, unscoped string) {
+	return MakeScopedEndpointNodeID(hostID, address, port), MakeScopedEndpointNodeID("", address, port)
+}
+
 // MakeScopedAddressNodeID is like MakeAddressNodeID, but it always
 // prefixes the ID witha scope.
 func MakeScopedAddressNodeID(scope, address string) string {
 	return scope + ScopeDelim + address
 }
 
+// MakeProcessNodeIDWithStart is like MakeProcessNodeID, but includes the
+// process start time, so that a reused PID doesn't conflate two processes.
+func MakeProcessNodeIDWithStart(hostID, pid, startTime string) string {
+	return hostID + ScopeDelim + pid + ScopeDelim + startTime
+}
+
+// JoinHostAndRemainder scopes a pre-built ID remainder by hostID, inserting
+// exactly one ScopeDelim between them even if the remainder already begins
+// with one.
+func JoinHostAndRemainder(hostID, remainder string) string {
+	return hostID + ScopeDelim + strings.TrimLeft(remainder, ScopeDelim)
+}
+
 // MakeProcessNodeID produces a process node ID from its composite parts.
 func MakeProcessNodeID(hostID, pid string) string {
 	return hostID + ScopeDelim + pid
 }
 
+// MakeCgroupNodeID produces a node ID for a cgroup (v2) on a host. The
+// path is escaped, since it contains slashes and may contain ScopeDelim.
+func MakeCgroupNodeID(hostID, cgroupPath string) string {
+	return hostID + ScopeDelim + url.PathEscape(cgroupPath) + ScopeDelim + "<cgroup>"
+}
+
+// ParseCgroupNodeID produces the host ID and cgroup path from a cgroup node ID.
+func ParseCgroupNodeID(cgroupNodeID string) (hostID, cgroupPath string, ok bool) {
+	fields := strings.Split(cgroupNodeID, ScopeDelim)
+	if len(fields) != 3 || fields[2] != "<cgroup>" {
+		return "", "", false
+	}
+	cgroupPath, err := url.PathUnescape(fields[1])
+	if err != nil {
+		return "", "", false
+	}
+	return fields[0], cgroupPath, true
+}
+
+// MakeFlowNodeID produces a node ID for a connection-tracked flow from its
+// 5-tuple. The fields are ordered protocol, then source address and port,
+// then destination address and port, followed by a "<flow>" tag:
+// "tcp;10.0.0.1;40000;10.0.0.2;80;<flow>".
+func MakeFlowNodeID(proto, srcIP, srcPort, dstIP, dstPort string) string {
+	return strings.Join([]string{proto, srcIP, srcPort, dstIP, dstPort, "<flow>"}, ScopeDelim)
+}
+
+// ParseFlowNodeID produces the 5-tuple from a flow node ID.
+func ParseFlowNodeID(flowNodeID string) (proto, srcIP, srcPort, dstIP, dstPort string, ok bool) {
+	fields := strings.Split(flowNodeID, ScopeDelim)
+	if len(fields) != 6 || fields[5] != "<flow>" {
+		return "", "", "", "", "", false
+	}
+	return fields[0], fields[1], fields[2], fields[3], fields[4], true
+}
+
+// MakeSocketNodeID produces a node ID for a socket, identified by its inode,
+// which is unique per host.
+func MakeSocketNodeID(hostID, inode string) string {
+	return hostID + ScopeDelim + inode + ScopeDelim + "<socket>"
+}
+
+// ParseSocketNodeID produces the host ID and inode from a socket node ID.
+func ParseSocketNodeID(socketNodeID string) (hostID, inode string, ok bool) {
+	fields := strings.Split(socketNodeID, ScopeDelim)
+	if len(fields) != 3 || fields[2] != "<socket>" {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
 // MakeECSServiceNodeID produces an ECS Service node ID from its composite parts.
 func MakeECSServiceNodeID(cluster, serviceName string) string {
 	return cluster + ScopeDelim + serviceName
@@ -103,7 +400,7 @@ var (
 	ParseHostNodeID = parseSingleComponentID("host")
 
 	// MakeContainerNodeID produces a container node ID from its composite parts.
-	MakeContainerNodeID = makeSingleComponentID("container")
+	MakeContainerNodeID = makeContainerNodeID
 
 	// ParseContainerNodeID parses a container node ID
 	ParseContainerNodeID = parseSingleComponentID("container")
@@ -211,6 +508,52 @@ var (
 	ParseVolumeSnapshotDataNodeID = parseSingleComponentID("volume_snapshot_data")
 )
 
+// NormalizeContainerIDs makes MakeContainerNodeID lowercase hexadecimal
+// container IDs, so runtimes reporting them with different casing don't
+// produce duplicate nodes. It is off by default.
+var NormalizeContainerIDs = false
+
+var makeRawContainerNodeID = makeSingleComponentID("container")
+
+func makeContainerNodeID(id string) string {
+	if NormalizeContainerIDs {
+		id = NormalizeHexField(id)
+	}
+	return makeRawContainerNodeID(id)
+}
+
+// ShortContainerIDLength is the length container IDs are displayed at.
+const ShortContainerIDLength = 12
+
+// ShortContainerID truncates the container ID in a container node ID to
+// ShortContainerIDLength characters, for compact display keys. It returns
+// true if the ID was truncated, and false, with the ID unchanged, if it is
+// not a container node ID or is already short.
+func ShortContainerID(id string) (string, bool) {
+	containerID, ok := ParseContainerNodeID(id)
+	if !ok || len(containerID) <= ShortContainerIDLength {
+		return id, false
+	}
+	return MakeContainerNodeID(containerID[:ShortContainerIDLength]), true
+}
+
+// NormalizeHexField lowercases s if it is a hexadecimal string, and returns
+// it unchanged otherwise.
+func NormalizeHexField(s string) string {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return s
+		}
+	}
+	return strings.ToLower(s)
+}
+
+// isTag determines whether s is a node ID tag, such as "<host>".
+func isTag(s string) bool {
+	return strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">")
+}
+
 // makeSingleComponentID makes a single-component node id encoder
 func makeSingleComponentID(tag string) func(string) string {
 	return func(id string) string {
@@ -262,14 +605,42 @@ func split2(s, sep string) (s1, s2 string, ok bool) {
 	return s[:pos], s[pos+1:], true
 }
 
+// IDParseError is returned when a node ID cannot be parsed.
+type IDParseError struct {
+	ID     string
+	Reason string
+}
+
+func (e *IDParseError) Error() string {
+	return fmt.Sprintf("invalid node ID %q: %s", e.ID, e.Reason)
+}
+
 // ParseNodeID produces the id and tag of a single-component node ID.
 func ParseNodeID(nodeID string) (id string, tag string, ok bool) {
 	return split2(nodeID, ScopeDelim)
 }
 
+// HostField returns the first field of a node ID, which holds the host for
+// host-scoped node IDs, without allocating.
+func HostField(id string) (string, bool) {
+	pos := strings.IndexByte(id, ScopeDelim[0])
+	if pos == -1 {
+		return "", false
+	}
+	return id[:pos], true
+}
+
 // ParseEndpointNodeID produces the scope, address, and port and remainder.
-// Note that scope may be blank.
+// Note that scope may be blank. Any application protocol added by
+// MakeL7EndpointNodeID is dropped from the port; use ParseL7EndpointNodeID
+// to get it.
 func ParseEndpointNodeID(endpointNodeID string) (scope, address, port string, ok bool) {
+	scope, address, port, ok = parseEndpointNodeID(endpointNodeID)
+	port, _ = splitL7Proto(port)
+	return scope, address, port, ok
+}
+
+func parseEndpointNodeID(endpointNodeID string) (scope, address, port string, ok bool) {
 	// Not using strings.SplitN() to avoid a heap allocation
 	first := strings.Index(endpointNodeID, ScopeDelim)
 	if first == -1 {
@@ -282,16 +653,283 @@ func ParseEndpointNodeID(endpointNodeID string) (scope, address, port string, ok
 	return endpointNodeID[:first], endpointNodeID[first+1 : first+1+second], endpointNodeID[first+1+second+1:], true
 }
 
+// CoalesceEphemeralPort rewrites the port of an endpoint node ID to
+// EphemeralPort if it falls within [low, high], so that the many endpoints
+// of a high-churn client collapse into one node. It returns false, and the
+// ID unchanged, if the ID is not an endpoint or its port is out of range.
+func CoalesceEphemeralPort(id string, low, high int) (string, bool) {
+	scope, address, port, l7proto, ok := ParseL7EndpointNodeID(id)
+	if !ok {
+		return id, false
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < low || p > high {
+		return id, false
+	}
+	return withL7Proto(MakeScopedEndpointNodeID(scope, address, EphemeralPort), l7proto), true
+}
+
+// RedactPort rewrites the port of an endpoint node ID to RedactedPort if it
+// is in hidden, so that the service cannot be identified while the node
+// stays in the graph. It returns false, and the ID unchanged, if the ID is
+// not an endpoint or its port is not hidden.
+func RedactPort(id string, hidden map[string]bool) (string, bool) {
+	scope, address, port, l7proto, ok := ParseL7EndpointNodeID(id)
+	if !ok || !hidden[port] {
+		return id, false
+	}
+	return withL7Proto(MakeScopedEndpointNodeID(scope, address, RedactedPort), l7proto), true
+}
+
+// IsEphemeralEndpoint determines whether an endpoint node ID has been
+// coalesced by CoalesceEphemeralPort.
+func IsEphemeralEndpoint(id string) bool {
+	_, _, port, ok := ParseEndpointNodeID(id)
+	return ok && port == EphemeralPort
+}
+
+// SameExceptEphemeral determines whether two endpoint node IDs have the same
+// scope and address and ports which are both in the default ephemeral
+// range, i.e. they are likely the same client reconnecting.
+func SameExceptEphemeral(idA, idB string) bool {
+	scopeA, addressA, portA, okA := ParseEndpointNodeID(idA)
+	scopeB, addressB, portB, okB := ParseEndpointNodeID(idB)
+	return okA && okB && scopeA == scopeB && addressA == addressB &&
+		isEphemeralPort(portA) && isEphemeralPort(portB)
+}
+
+// HostExposedPorts returns the distinct ports, in numerical order, of the
+// endpoints among ids which are scoped by hostID, taking any endpoint not
+// on an ephemeral port (in the default range) to be listening. Coalesced
+// and redacted ports are left out.
+func HostExposedPorts(ids []string, hostID string) []string {
+	seen := map[string]struct{}{}
+	var ports []string
+	for _, id := range ids {
+		scope, _, port, _, ok := ParseL7EndpointNodeID(id)
+		if !ok || scope != hostID || !isDigits(port) || isEphemeralPort(port) {
+			continue
+		}
+		if _, ok := seen[port]; ok {
+			continue
+		}
+		seen[port] = struct{}{}
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		a, _ := strconv.Atoi(ports[i])
+		b, _ := strconv.Atoi(ports[j])
+		return a < b
+	})
+	return ports
+}
+
+func isEphemeralPort(port string) bool {
+	p, err := strconv.Atoi(port)
+	return err == nil && p >= EphemeralPortLow && p <= EphemeralPortHigh
+}
+
+// wellKnownPorts maps common port numbers to their service names.
+var wellKnownPorts = map[string]string{
+	"21":    "ftp",
+	"22":    "ssh",
+	"23":    "telnet",
+	"25":    "smtp",
+	"53":    "dns",
+	"80":    "http",
+	"110":   "pop3",
+	"123":   "ntp",
+	"143":   "imap",
+	"443":   "https",
+	"2379":  "etcd",
+	"3306":  "mysql",
+	"5432":  "postgresql",
+	"6379":  "redis",
+	"6443":  "kubernetes",
+	"8080":  "http-alt",
+	"9090":  "prometheus",
+	"27017": "mongodb",
+}
+
+// PortDisplayName returns a port for display, annotated with its service
+// name if it is well known, e.g. "80 (http)".
+func PortDisplayName(port string) string {
+	if name, ok := wellKnownPorts[port]; ok {
+		return port + " (" + name + ")"
+	}
+	return port
+}
+
+// EndpointIDPortDisplay returns the port of an endpoint node ID for display,
+// as PortDisplayName does, or "" if id is not an endpoint node ID.
+func EndpointIDPortDisplay(id string) string {
+	_, _, port, ok := ParseEndpointNodeID(id)
+	if !ok {
+		return ""
+	}
+	return PortDisplayName(port)
+}
+
+// NormalizeEndpointNodeID rewrites an endpoint node ID with an IPv4-mapped
+// IPv6 address (e.g. "::ffff:10.0.0.1") to use the plain IPv4 address, so
+// that both forms produce the same node. It returns false, with the ID
+// unchanged, if id is not an endpoint node ID with an IP address.
+func NormalizeEndpointNodeID(id string) (string, bool) {
+	scope, address, port, ok := ParseEndpointNodeID(id)
+	if !ok {
+		return id, false
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return id, false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		address = ip4.String()
+	}
+	return MakeScopedEndpointNodeID(scope, address, port), true
+}
+
+// ParseEndpointNodeIDStrict is like ParseEndpointNodeID, but returns an
+// error describing the problem if the ID has the wrong number of fields or
+// an empty address or port. It is intended for diagnosing buggy probes;
+// production code should use ParseEndpointNodeID.
+func ParseEndpointNodeIDStrict(endpointNodeID string) (scope, address, port string, err error) {
+	fields := strings.Split(endpointNodeID, ScopeDelim)
+	switch {
+	case len(fields) != 3:
+		return "", "", "", &IDParseError{ID: endpointNodeID, Reason: fmt.Sprintf("expected 3 fields, got %d", len(fields))}
+	case fields[1] == "":
+		return "", "", "", &IDParseError{ID: endpointNodeID, Reason: "empty address field"}
+	case fields[2] == "":
+		return "", "", "", &IDParseError{ID: endpointNodeID, Reason: "empty trailing port field"}
+	}
+	port, _ = splitL7Proto(fields[2])
+	return fields[0], fields[1], port, nil
+}
+
 // ParseAddressNodeID produces the host ID, address from an address node ID.
 func ParseAddressNodeID(addressNodeID string) (hostID, address string, ok bool) {
 	return split2(addressNodeID, ScopeDelim)
 }
 
+// ParseAddressNodeIDWithName produces the host ID, address and DNS name
+// from an address node ID. The name is blank for plain address node IDs.
+func ParseAddressNodeIDWithName(addressNodeID string) (hostID, address, name string, ok bool) {
+	hostID, address, ok = ParseAddressNodeID(addressNodeID)
+	if !ok {
+		return "", "", "", false
+	}
+	if pos := strings.Index(address, NameDelim); pos != -1 {
+		address, name = address[:pos], address[pos+len(NameDelim):]
+	}
+	return hostID, address, name, true
+}
+
+// firstNetnsID is the lowest network namespace ID: namespaces are
+// identified by the inode numbers of their /proc/<pid>/ns/net files, which
+// the kernel allocates from PROC_DYNAMIC_FIRST upwards.
+const firstNetnsID = 0xF0000000
+
+// ParseNetnsAddressNodeID produces the host ID, network namespace and
+// address from an address node ID made by MakeNetnsAddressNodeID with a
+// non-empty network namespace. Since host IDs may contain "-", the scope is
+// split at its last "-", and what follows must be a network namespace ID,
+// so that host IDs such as "ip-10-0-0-1" aren't taken for one.
+func ParseNetnsAddressNodeID(addressNodeID string) (hostID, netns, address string, ok bool) {
+	scope, address, ok := ParseAddressNodeID(addressNodeID)
+	if !ok {
+		return "", "", "", false
+	}
+	pos := strings.LastIndex(scope, "-")
+	if pos <= 0 || !isNetnsID(scope[pos+1:]) {
+		return "", "", "", false
+	}
+	return scope[:pos], scope[pos+1:], address, true
+}
+
+func isNetnsID(s string) bool {
+	if !isDigits(s) {
+		return false
+	}
+	id, err := strconv.ParseUint(s, 10, 32)
+	return err == nil && id >= firstNetnsID
+}
+
 // ParseProcessNodeID produces the host ID and PID from a process node ID.
 func ParseProcessNodeID(processNodeID string) (hostID, pid string, ok bool) {
 	return split2(processNodeID, ScopeDelim)
 }
 
+// SameHostProcesses determines whether two process node IDs (with or
+// without start times) are on the same host. It returns false if either
+// isn't a process node ID.
+func SameHostProcesses(idA, idB string) bool {
+	hostA, okA := processHostID(idA)
+	hostB, okB := processHostID(idB)
+	return okA && okB && hostA == hostB
+}
+
+func processHostID(id string) (string, bool) {
+	hostID, rest, ok := split2(id, ScopeDelim)
+	if !ok {
+		return "", false
+	}
+	pid, startTime, hasStart := split2(rest, ScopeDelim)
+	if !hasStart {
+		pid = rest
+	}
+	return hostID, isDigits(pid) && !isTag(startTime)
+}
+
+// ParseProcessNodeIDWithStart produces the host ID, PID and start time from
+// a process node ID made by MakeProcessNodeIDWithStart.
+func ParseProcessNodeIDWithStart(processNodeID string) (hostID, pid, startTime string, ok bool) {
+	fields := strings.Split(processNodeID, ScopeDelim)
+	if len(fields) != 3 || !isDigits(fields[1]) || isTag(fields[2]) {
+		return "", "", "", false
+	}
+	return fields[0], fields[1], fields[2], true
+}
+
+// ParseNodeIDErr is like ParseNodeID, but returns an *IDParseError on failure.
+func ParseNodeIDErr(nodeID string) (id string, tag string, err error) {
+	id, tag, ok := ParseNodeID(nodeID)
+	if !ok {
+		return "", "", &IDParseError{ID: nodeID, Reason: "missing " + ScopeDelim + " delimiter"}
+	}
+	return id, tag, nil
+}
+
+// ParseEndpointNodeIDErr is like ParseEndpointNodeID, but returns an
+// *IDParseError on failure.
+func ParseEndpointNodeIDErr(endpointNodeID string) (scope, address, port string, err error) {
+	scope, address, port, ok := ParseEndpointNodeID(endpointNodeID)
+	if !ok {
+		return "", "", "", &IDParseError{ID: endpointNodeID, Reason: "not an endpoint node ID"}
+	}
+	return scope, address, port, nil
+}
+
+// ParseAddressNodeIDErr is like ParseAddressNodeID, but returns an
+// *IDParseError on failure.
+func ParseAddressNodeIDErr(addressNodeID string) (hostID, address string, err error) {
+	hostID, address, ok := ParseAddressNodeID(addressNodeID)
+	if !ok {
+		return "", "", &IDParseError{ID: addressNodeID, Reason: "not an address node ID"}
+	}
+	return hostID, address, nil
+}
+
+// ParseProcessNodeIDErr is like ParseProcessNodeID, but returns an
+// *IDParseError on failure.
+func ParseProcessNodeIDErr(processNodeID string) (hostID, pid string, err error) {
+	hostID, pid, ok := ParseProcessNodeID(processNodeID)
+	if !ok {
+		return "", "", &IDParseError{ID: processNodeID, Reason: "not a process node ID"}
+	}
+	return hostID, pid, nil
+}
+
 // ParseECSServiceNodeID produces the cluster, service name from an ECS Service node ID
 func ParseECSServiceNodeID(ecsServiceNodeID string) (cluster, serviceName string, ok bool) {
 	cluster, serviceName, ok = split2(ecsServiceNodeID, ScopeDelim)
@@ -306,6 +944,514 @@ func ParseECSServiceNodeID(ecsServiceNodeID string) (cluster, serviceName string
 	return cluster, serviceName, true
 }
 
+// CommonScope returns the most specific scope shared by two node IDs, and
+// its kind. Endpoint, address, process and host node IDs are scoped by their
+// host; namespace node IDs are scoped by the namespace itself. ok is false if
+// the IDs share no scope, e.g. they are on different hosts or one of them is
+// an unscoped (public) address.
+func CommonScope(idA, idB string) (scope string, kind string, ok bool) {
+	scopeA, kindA, okA := nodeIDScope(idA)
+	scopeB, kindB, okB := nodeIDScope(idB)
+	if !okA || !okB || scopeA != scopeB || kindA != kindB {
+		return "", "", false
+	}
+	return scopeA, kindA, true
+}
+
+func nodeIDScope(id string) (scope string, kind string, ok bool) {
+	field0, rest, ok := split2(id, ScopeDelim)
+	if !ok || field0 == "" {
+		return "", "", false
+	}
+	switch {
+	case rest == "<host>":
+		return field0, "host", true
+	case rest == "<namespace>":
+		return field0, "namespace", true
+	case isTag(rest):
+		// Other single-component IDs are not scoped
+		return "", "", false
+	case strings.HasPrefix(field0, containerScopePrefix):
+		return field0[len(containerScopePrefix):], "container", true
+	}
+	return field0, "host", true
+}
+
+// CollectHosts returns the sorted, unique host IDs referenced by the given
+// node IDs: the scope of host-scoped endpoint, address and process node
+// IDs, and the ID of host node IDs. Pseudo node IDs (including the legacy
+// "pseudo;" form) are ignored.
+func CollectHosts(ids []string) []string {
+	var hosts []string
+	for _, id := range ids {
+		if isPseudoID(id) {
+			continue
+		}
+		if scope, kind, ok := nodeIDScope(id); ok && kind == "host" {
+			hosts = append(hosts, scope)
+		}
+	}
+	return []string(MakeStringSet(hosts...))
+}
+
+// FindCaseDuplicateHosts finds node IDs whose hosts (as collected by
+// CollectHosts) are the same but for case, as when probes report hostnames
+// inconsistently cased. The IDs are grouped under the lower-cased host; only
+// hosts seen with more than one casing are included. Each group is sorted.
+func FindCaseDuplicateHosts(ids []string) map[string][]string {
+	groups := map[string][]string{}
+	casings := map[string]map[string]struct{}{}
+	for _, id := range ids {
+		if isPseudoID(id) {
+			continue
+		}
+		host, kind, ok := nodeIDScope(id)
+		if !ok || kind != "host" {
+			continue
+		}
+		key := strings.ToLower(host)
+		if casings[key] == nil {
+			casings[key] = map[string]struct{}{}
+		}
+		casings[key][host] = struct{}{}
+		groups[key] = append(groups[key], id)
+	}
+	for key, group := range groups {
+		if len(casings[key]) < 2 {
+			delete(groups, key)
+			continue
+		}
+		groups[key] = []string(MakeStringSet(group...))
+	}
+	return groups
+}
+
+// GroupAddressesByHost groups the address node IDs of multi-homed hosts
+// under their host ID. Host-scoped address node IDs, e.g. of loopback
+// addresses, are grouped by their scope. Public addresses have no scope, so
+// they are grouped by the host lookup returns for the address, if any; lookup
+// may be nil. IDs which are not address node IDs, or whose host is unknown,
+// are left out. Each group is sorted.
+func GroupAddressesByHost(ids []string, lookup func(address string) (hostID string, ok bool)) map[string][]string {
+	groups := map[string][]string{}
+	for _, id := range ids {
+		hostID, address, ok := ParseAddressNodeID(id)
+		if !ok || AddressIDAddresser(id) == nil {
+			continue
+		}
+		if hostID == "" {
+			if lookup == nil {
+				continue
+			}
+			if hostID, ok = lookup(address); !ok {
+				continue
+			}
+		}
+		groups[hostID] = append(groups[hostID], id)
+	}
+	for hostID, group := range groups {
+		groups[hostID] = []string(MakeStringSet(group...))
+	}
+	return groups
+}
+
+// isPseudoID determines whether id is a pseudo node ID, as made by the
+// render package, or in the legacy "pseudo;" form.
+func isPseudoID(id string) bool {
+	return strings.HasPrefix(id, "pseudo:") || strings.HasPrefix(id, "pseudo"+ScopeDelim)
+}
+
+// IsLocalHostID returns a predicate which determines whether a node ID is
+// scoped by (or, for host node IDs, is) localHost. Pseudo and internet node
+// IDs are never local.
+func IsLocalHostID(localHost string) func(id string) bool {
+	return func(id string) bool {
+		if isPseudoID(id) {
+			return false
+		}
+		scope, kind, ok := nodeIDScope(id)
+		return ok && kind == "host" && scope == localHost
+	}
+}
+
+// AnonymizeNodeID replaces the potentially sensitive fields of a node ID
+// (hosts, non-loopback addresses and DNS names) with salted hashes,
+// preserving the structure of the ID so an anonymized report still renders
+// the same graph. Addresses are mapped to addresses of the same family, and
+// keep any IPv6 zone. The network namespace of loopback scopes is kept, as
+// are container scopes, since container IDs aren't anonymized. The result is
+// deterministic for a given id and salt, so adjacencies stay consistent.
+// IDs ClassifyNodeID doesn't recognise are returned unchanged.
+func AnonymizeNodeID(id string, salt []byte) string {
+	typ, ok := ClassifyNodeID(id)
+	if !ok {
+		return id
+	}
+	fields := strings.Split(id, ScopeDelim)
+	switch typ {
+	case EndpointNodeIDType:
+		fields[0] = anonymizeScope(fields[0], fields[1], salt)
+		fields[1] = anonymizeAddress(fields[1], salt)
+	case AddressNodeIDType:
+		address, name := fields[1], ""
+		if pos := strings.Index(address, NameDelim); pos != -1 {
+			address, name = address[:pos], address[pos+len(NameDelim):]
+		}
+		fields[0] = anonymizeScope(fields[0], address, salt)
+		fields[1] = anonymizeAddress(address, salt)
+		if name != "" {
+			fields[1] += NameDelim + anonymizeField(name, salt)
+		}
+	case ProcessNodeIDType, CgroupNodeIDType, SocketNodeIDType, HostNodeIDType, ECSServiceNodeIDType:
+		// The host, or for ECS services the cluster, comes first
+		fields[0] = anonymizeField(fields[0], salt)
+	case FlowNodeIDType:
+		fields[1] = anonymizeAddress(fields[1], salt)
+		fields[3] = anonymizeAddress(fields[3], salt)
+	}
+	return strings.Join(fields, ScopeDelim)
+}
+
+// anonymizeScope anonymizes the scope of an address or endpoint node ID for
+// address, as made by makeAddressID or MakeContainerScopedEndpointNodeID.
+func anonymizeScope(scope, address string, salt []byte) string {
+	if strings.HasPrefix(scope, containerScopePrefix) {
+		return scope
+	}
+	hostID, netns := splitNetnsScope(scope, address)
+	hostID = anonymizeField(hostID, salt)
+	if netns != "" {
+		return hostID + "-" + netns
+	}
+	return hostID
+}
+
+func anonymizeHash(s string, salt []byte) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+func anonymizeField(s string, salt []byte) string {
+	if s == "" {
+		return ""
+	}
+	return hex.EncodeToString(anonymizeHash(s, salt)[:8])
+}
+
+func anonymizeAddress(address string, salt []byte) string {
+	ip := parseZonedIP(address)
+	if ip == nil {
+		return anonymizeField(address, salt)
+	}
+	if ip.IsLoopback() {
+		return address
+	}
+	zone := ""
+	if pos := strings.LastIndexByte(address, '%'); pos != -1 {
+		zone = address[pos:]
+	}
+	sum := anonymizeHash(address, salt)
+	if ip.To4() != nil {
+		return net.IP(sum[:net.IPv4len]).String()
+	}
+	return net.IP(sum[:net.IPv6len]).String() + zone
+}
+
+// CanHaveEdges returns false for node IDs which never have connectivity of
+// their own (hosts and container images) and true otherwise, so that edge
+// building code can skip them.
+func CanHaveEdges(id string) bool {
+	if _, ok := ParseHostNodeID(id); ok {
+		return false
+	}
+	if _, ok := ParseContainerImageNodeID(id); ok {
+		return false
+	}
+	return true
+}
+
+// BareID returns the stable part of a node ID: the scope and address of an
+// endpoint node ID (dropping the port), or the id of a single-component node
+// ID (dropping the tag). Other IDs are returned unchanged.
+func BareID(id string) string {
+	if field0, tag, ok := ParseNodeID(id); ok && isTag(tag) {
+		return field0
+	}
+	if scope, address, _, ok := ParseEndpointNodeID(id); ok {
+		return MakeScopedAddressNodeID(scope, address)
+	}
+	return id
+}
+
+// HashNodeID returns a stable 64-bit hash of a node ID.
+func HashNodeID(id string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return h.Sum64()
+}
+
+// ColorBucket assigns a node ID to one of buckets colour buckets, based on
+// its BareID, so a process keeps its colour as its connections change.
+func ColorBucket(id string, buckets int) int {
+	if buckets <= 0 {
+		return 0
+	}
+	return int(HashNodeID(BareID(id)) % uint64(buckets))
+}
+
+// IDAddresser tries to convert a node ID to a net.IP, if possible.
+type IDAddresser func(string) net.IP
+
+// EndpointIDAddresser converts an endpoint node ID to an IP.
+func EndpointIDAddresser(id string) net.IP {
+	_, address, _, ok := ParseEndpointNodeID(id)
+	if !ok {
+		return nil
+	}
+	return parseZonedIP(address)
+}
+
+// AddressIDAddresser converts an address node ID, possibly carrying a DNS
+// name, to an IP.
+func AddressIDAddresser(id string) net.IP {
+	_, address, _, ok := ParseAddressNodeIDWithName(id)
+	if !ok {
+		return nil
+	}
+	return parseZonedIP(address)
+}
+
+// addressFromID extracts the IP from an endpoint or address node ID.
+func addressFromID(id string) net.IP {
+	if ip := EndpointIDAddresser(id); ip != nil {
+		return ip
+	}
+	return AddressIDAddresser(id)
+}
+
+var privateNetworks = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"} {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets
+}()
+
+// IsPrivateAddressID determines whether the address in an endpoint or
+// address node ID is private: RFC1918, link-local or an IPv6 unique local
+// address.
+func IsPrivateAddressID(id string) bool {
+	ip := addressFromID(id)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLinkLocalUnicast() {
+		return true
+	}
+	for _, ipnet := range privateNetworks {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CollapsesToSelfLoop determines whether rewriting the host oldHost to
+// newHost in both node IDs of an edge would make them identical, in which
+// case the edge should be dropped when merging the two hosts.
+func CollapsesToSelfLoop(edgeID, oldHost, newHost string) bool {
+	src, dst, ok := ParseEdgeID(edgeID)
+	if !ok {
+		return false
+	}
+	return rewriteHost(src, oldHost, newHost) == rewriteHost(dst, oldHost, newHost)
+}
+
+// rewriteHost replaces the host field of a node ID if it is oldHost.
+func rewriteHost(id, oldHost, newHost string) string {
+	field0, rest, ok := split2(id, ScopeDelim)
+	if !ok || field0 != oldHost {
+		return id
+	}
+	return newHost + ScopeDelim + rest
+}
+
+// HasEmptyHost determines whether a node ID has an empty host field, as
+// unscoped (public) address and endpoint node IDs do, without parsing it.
+func HasEmptyHost(id string) bool {
+	return strings.HasPrefix(id, ScopeDelim)
+}
+
+// DOTSafeID encodes a node ID as an identifier which can be used unquoted
+// in Graphviz DOT files. Characters other than ASCII letters and digits are
+// escaped as "_" followed by two hex digits, and the result is prefixed
+// with "n" so it never begins with a digit. ParseDOTSafeID reverses it.
+func DOTSafeID(id string) string {
+	var b strings.Builder
+	b.WriteByte('n')
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "_%02x", c)
+	}
+	return b.String()
+}
+
+// ParseDOTSafeID decodes an identifier produced by DOTSafeID.
+func ParseDOTSafeID(dotID string) (string, bool) {
+	if !strings.HasPrefix(dotID, "n") {
+		return "", false
+	}
+	dotID = dotID[1:]
+	var b strings.Builder
+	for i := 0; i < len(dotID); i++ {
+		if dotID[i] != '_' {
+			b.WriteByte(dotID[i])
+			continue
+		}
+		if i+2 >= len(dotID) {
+			return "", false
+		}
+		c, err := hex.DecodeString(dotID[i+1 : i+3])
+		if err != nil {
+			return "", false
+		}
+		b.Write(c)
+		i += 2
+	}
+	return b.String(), true
+}
+
+// IsMulticastOrBroadcastID determines whether the address in an endpoint or
+// address node ID is a multicast address or the IPv4 limited broadcast
+// address, 255.255.255.255.
+func IsMulticastOrBroadcastID(id string) bool {
+	ip := addressFromID(id)
+	return ip != nil && (ip.IsMulticast() || ip.Equal(net.IPv4bcast))
+}
+
+// IsUnspecifiedAddressID determines whether the address in an endpoint or
+// address node ID is the unspecified address, 0.0.0.0 or ::. Unlike wildcard
+// listening endpoints (see IsWildcardEndpoint), a connection to such an
+// address indicates a bug or a default route.
+func IsUnspecifiedAddressID(id string) bool {
+	ip := addressFromID(id)
+	return ip != nil && ip.IsUnspecified()
+}
+
+// cloudMetadataAddresses are the addresses of the instance metadata services
+// of the major cloud providers.
+var cloudMetadataAddresses = []net.IP{
+	net.ParseIP("169.254.169.254"),
+	net.ParseIP("fd00:ec2::254"),
+}
+
+// IsCloudMetadataEndpoint determines whether an endpoint node ID is for a
+// cloud instance metadata service, so that workloads accessing it can be
+// flagged.
+func IsCloudMetadataEndpoint(id string) bool {
+	ip := EndpointIDAddresser(id)
+	if ip == nil {
+		return false
+	}
+	for _, metadata := range cloudMetadataAddresses {
+		if ip.Equal(metadata) {
+			return true
+		}
+	}
+	return false
+}
+
+// MakeTimestampedNodeID keys a node ID by the time of the report snapshot
+// it comes from, in Unix nanoseconds, for time-travel debugging.
+func MakeTimestampedNodeID(baseID string, ts int64) string {
+	return baseID + TimestampDelim + strconv.FormatInt(ts, 10)
+}
+
+// ParseTimestampedNodeID produces the base node ID and snapshot time from a
+// timestamped node ID.
+func ParseTimestampedNodeID(id string) (baseID string, ts int64, ok bool) {
+	// The timestamp is always last, so base IDs containing TimestampDelim are fine
+	pos := strings.LastIndex(id, TimestampDelim)
+	if pos == -1 {
+		return "", 0, false
+	}
+	ts, err := strconv.ParseInt(id[pos+len(TimestampDelim):], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return id[:pos], ts, true
+}
+
+// MakeQualifiedID produces an ID carrying both a node ID and the name of
+// the topology it belongs in, for passing between subsystems.
+func MakeQualifiedID(topology, nodeID string) string {
+	return topology + TopologyDelim + nodeID
+}
+
+// ParseQualifiedID produces the topology and node ID from a qualified ID.
+func ParseQualifiedID(id string) (topology, nodeID string, ok bool) {
+	// Topology names never contain TopologyDelim, so node IDs may
+	pos := strings.Index(id, TopologyDelim)
+	if pos <= 0 {
+		return "", "", false
+	}
+	return id[:pos], id[pos+len(TopologyDelim):], true
+}
+
+// MakeLineageID produces an ID for the chain of a node and its ancestors,
+// e.g. a process, its container and their host. Every element must be a
+// node ID recognised by IsRecognizedNodeID, and not contain LineageDelim.
+func MakeLineageID(chain ...string) (string, error) {
+	if len(chain) == 0 {
+		return "", fmt.Errorf("empty lineage")
+	}
+	for _, id := range chain {
+		if !IsRecognizedNodeID(id) {
+			return "", fmt.Errorf("invalid node ID %q in lineage", id)
+		}
+		if strings.Contains(id, LineageDelim) {
+			return "", fmt.Errorf("node ID %q in lineage contains %q", id, LineageDelim)
+		}
+	}
+	return strings.Join(chain, LineageDelim), nil
+}
+
+// ParseLineageID produces the chain of node IDs, node first, from a lineage
+// ID. It returns false if any element is not a recognised node ID.
+func ParseLineageID(id string) ([]string, bool) {
+	chain := strings.Split(id, LineageDelim)
+	for _, element := range chain {
+		if !IsRecognizedNodeID(element) {
+			return nil, false
+		}
+	}
+	return chain, true
+}
+
+// ValidHostID checks that a host ID can be embedded in node IDs: it must be
+// non-empty and contain neither ScopeDelim nor EdgeDelim.
+func ValidHostID(hostID string) error {
+	switch {
+	case hostID == "":
+		return fmt.Errorf("empty host ID")
+	case strings.Contains(hostID, ScopeDelim):
+		return fmt.Errorf("host ID %q contains %q", hostID, ScopeDelim)
+	case strings.Contains(hostID, EdgeDelim):
+		return fmt.Errorf("host ID %q contains %q", hostID, EdgeDelim)
+	}
+	return nil
+}
+
 // ExtractHostID extracts the host id from Node
 func ExtractHostID(m Node) string {
 	hostNodeID, _ := m.Latest.Lookup(HostNodeID)
@@ -315,10 +1461,30 @@ func ExtractHostID(m Node) string {
 
 // IsLoopback ascertains if an address comes from a loopback interface.
 func IsLoopback(address string) bool {
-	ip := net.ParseIP(address)
+	ip := parseZonedIP(address)
 	return ip != nil && ip.IsLoopback()
 }
 
+// parseZonedIP is like net.ParseIP, but ignores any IPv6 zone, as in
+// "::1%lo0".
+func parseZonedIP(address string) net.IP {
+	address = unbracketAddress(address)
+	if i := strings.LastIndexByte(address, '%'); i != -1 {
+		address = address[:i]
+	}
+	return net.ParseIP(address)
+}
+
+// unbracketAddress strips the brackets from an IPv6 literal in the
+// "[fe80::1%eth0]" form used in URLs and host:port strings, so that it
+// produces the same node IDs as the bare address.
+func unbracketAddress(address string) string {
+	if len(address) > 2 && address[0] == '[' && address[len(address)-1] == ']' {
+		return address[1 : len(address)-1]
+	}
+	return address
+}
+
 // IsPauseImageName indicates whether an image name corresponds to a
 // kubernetes pause container image.
 func IsPauseImageName(imageName string) bool {
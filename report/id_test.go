@@ -1,6 +1,10 @@
 package report_test
 
 import (
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/weaveworks/scope/report"
@@ -67,6 +71,40 @@ func TestEndpointNodeID(t *testing.T) {
 	}
 }
 
+func TestIPv6NodeIDs(t *testing.T) {
+	for _, tc := range []struct {
+		address, wantScope, wantAddress string
+	}{
+		{"fd00::1", "", "fd00::1"},
+		{"[fd00::1]", "", "fd00::1"},
+		{"::1", "host.com", "::1"},
+		{"[::1]", "host.com", "::1"},
+		{"fe80::1%eth0", "", "fe80::1%eth0"},
+		{"[fe80::1%eth0]", "", "fe80::1%eth0"},
+		{"::ffff:10.0.0.1", "", "::ffff:10.0.0.1"},
+	} {
+		wantIP := net.ParseIP(strings.SplitN(tc.wantAddress, "%", 2)[0])
+
+		endpointID := report.MakeEndpointNodeID("host.com", "", tc.address, "80")
+		scope, address, port, ok := report.ParseEndpointNodeID(endpointID)
+		if !ok || scope != tc.wantScope || address != tc.wantAddress || port != "80" {
+			t.Errorf("%q: endpoint %q parsed as {%q, %q, %q, %v}", tc.address, endpointID, scope, address, port, ok)
+		}
+		if ip := report.EndpointIDAddresser(endpointID); !ip.Equal(wantIP) {
+			t.Errorf("%q: endpoint %q: want %v, have %v", tc.address, endpointID, wantIP, ip)
+		}
+
+		addressID := report.MakeAddressNodeID("host.com", tc.address)
+		scope, address, ok = report.ParseAddressNodeID(addressID)
+		if !ok || scope != tc.wantScope || address != tc.wantAddress {
+			t.Errorf("%q: address %q parsed as {%q, %q, %v}", tc.address, addressID, scope, address, ok)
+		}
+		if ip := report.AddressIDAddresser(addressID); !ip.Equal(wantIP) {
+			t.Errorf("%q: address %q: want %v, have %v", tc.address, addressID, wantIP, ip)
+		}
+	}
+}
+
 func TestECSServiceNodeIDCompat(t *testing.T) {
 	testID := "my-service;<ecs_service>"
 	testName := "my-service"
@@ -78,3 +116,1066 @@ func TestECSServiceNodeIDCompat(t *testing.T) {
 		t.Errorf("Backwards-compatible id %q parsed name to %q, expected %q", testID, name, testName)
 	}
 }
+
+func TestCommonScope(t *testing.T) {
+	for _, tc := range []struct {
+		a, b  string
+		scope string
+		kind  string
+		ok    bool
+	}{
+		{client54001EndpointNodeID, client54002EndpointNodeID, "", "", false},
+		{report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"), report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "8080"), clientHostID, "host", true},
+		{report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"), report.MakeProcessNodeID(clientHostID, "42"), clientHostID, "host", true},
+		{report.MakeProcessNodeID(clientHostID, "42"), clientHostNodeID, clientHostID, "host", true},
+		{report.MakeNamespaceNodeID("default"), report.MakeNamespaceNodeID("default"), "default", "namespace", true},
+		{report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"), report.MakeEndpointNodeID(serverHostID, "", "127.0.0.1", "80"), "", "", false},
+		{clientHostNodeID, serverHostNodeID, "", "", false},
+		{clientHostNodeID, report.MakeNamespaceNodeID(clientHostID), "", "", false},
+		{report.MakeContainerNodeID("abc"), report.MakeContainerNodeID("abc"), "", "", false},
+	} {
+		scope, kind, ok := report.CommonScope(tc.a, tc.b)
+		if ok != tc.ok || scope != tc.scope || kind != tc.kind {
+			t.Errorf("CommonScope(%q, %q) = (%q, %q, %v), want (%q, %q, %v)", tc.a, tc.b, scope, kind, ok, tc.scope, tc.kind, tc.ok)
+		}
+	}
+}
+
+func TestAnonymizeNodeID(t *testing.T) {
+	salt := []byte("salt")
+	for _, id := range []string{
+		client54001EndpointNodeID,
+		server80EndpointNodeID,
+		report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"),
+		report.MakeEndpointNodeID(clientHostID, "", "fe80::1", "80"),
+		clientAddressNodeID,
+		report.MakeProcessNodeID(clientHostID, "42"),
+		clientHostNodeID,
+		report.MakeContainerNodeID("abc"),
+	} {
+		have := report.AnonymizeNodeID(id, salt)
+		if have != report.AnonymizeNodeID(id, salt) {
+			t.Errorf("%q: anonymization is not deterministic", id)
+		}
+		if strings.Count(have, ";") != strings.Count(id, ";") {
+			t.Errorf("%q: structure not preserved: %q", id, have)
+		}
+		if strings.Contains(have, clientHostID) || strings.Contains(have, clientAddress) || strings.Contains(have, serverAddress) {
+			t.Errorf("%q: sensitive fields leaked: %q", id, have)
+		}
+	}
+
+	// Zoned addresses, start times and DNS names are recognised
+	for _, c := range []struct{ id, secret string }{
+		{report.MakeEndpointNodeID(clientHostID, "", "fe80::1234%eth0", "80"), "fe80::1234"},
+		{report.MakeAddressNodeID(clientHostID, "fe80::1234%eth0"), "fe80::1234"},
+		{report.MakeProcessNodeIDWithStart(clientHostID, "42", "1000"), clientHostID},
+		{report.MakeAddressNodeIDWithName(clientHostID, serverAddress, "db.example.com"), "db.example.com"},
+		{report.MakeFlowNodeID("tcp", clientAddress, "54001", serverAddress, "80"), serverAddress},
+	} {
+		have := report.AnonymizeNodeID(c.id, salt)
+		if strings.Contains(have, c.secret) || strings.Count(have, ";") != strings.Count(c.id, ";") {
+			t.Errorf("%q: not anonymized: %q", c.id, have)
+		}
+		want, _ := report.ClassifyNodeID(c.id)
+		if typ, ok := report.ClassifyNodeID(have); !ok || typ != want {
+			t.Errorf("%q: type changed: %q", c.id, have)
+		}
+	}
+	if _, address, _, ok := report.ParseEndpointNodeID(report.AnonymizeNodeID(report.MakeEndpointNodeID(clientHostID, "", "fe80::1234%eth0", "80"), salt)); !ok || !strings.HasSuffix(address, "%eth0") {
+		t.Errorf("zone not kept: %q", address)
+	}
+
+	// Container scopes and network namespaces are kept
+	containerScoped := report.MakeContainerScopedEndpointNodeID("abc", serverAddress, "80")
+	if scope, _, _, _ := report.ParseEndpointNodeID(report.AnonymizeNodeID(containerScoped, salt)); scope != "<container>abc" {
+		t.Errorf("container scope not kept: %q", scope)
+	}
+	netnsScoped := report.MakeEndpointNodeID(clientHostID, "4026531993", "127.0.0.1", "80")
+	if scope, _, _, _ := report.ParseEndpointNodeID(report.AnonymizeNodeID(netnsScoped, salt)); !strings.HasSuffix(scope, "-4026531993") || strings.Contains(scope, clientHostID) {
+		t.Errorf("network namespace not kept: %q", scope)
+	}
+
+	// Anonymized IDs keep their type
+	id := report.AnonymizeNodeID(server80EndpointNodeID, salt)
+	if _, address, port, ok := report.ParseEndpointNodeID(id); !ok || net.ParseIP(address).To4() == nil || port != "80" {
+		t.Errorf("%q: not an IPv4 endpoint", id)
+	}
+	if _, ok := report.ParseHostNodeID(report.AnonymizeNodeID(clientHostNodeID, salt)); !ok {
+		t.Errorf("anonymized host node ID is not a host node ID")
+	}
+	if have := report.AnonymizeNodeID(report.MakeContainerNodeID("abc"), salt); have != report.MakeContainerNodeID("abc") {
+		t.Errorf("container node ID changed: %q", have)
+	}
+
+	// Both ends of an edge anonymize consistently
+	client := report.MakeNode(client54001EndpointNodeID).WithAdjacent(server80EndpointNodeID)
+	server := report.MakeNode(server80EndpointNodeID)
+	if report.AnonymizeNodeID(client.Adjacency[0], salt) != report.AnonymizeNodeID(server.ID, salt) {
+		t.Errorf("edge endpoints anonymized inconsistently")
+	}
+	if report.AnonymizeNodeID(server.ID, salt) == report.AnonymizeNodeID(server.ID, []byte("pepper")) {
+		t.Errorf("salt has no effect")
+	}
+}
+
+func TestCanHaveEdges(t *testing.T) {
+	for id, want := range map[string]bool{
+		client54001EndpointNodeID:                   true,
+		serverAddressNodeID:                         true,
+		report.MakeProcessNodeID(clientHostID, "1"): true,
+		report.MakeContainerNodeID("abc"):           true,
+		report.MakePodNodeID("uid"):                 true,
+		clientHostNodeID:                            false,
+		report.MakeContainerImageNodeID("nginx"):    false,
+	} {
+		if have := report.CanHaveEdges(id); have != want {
+			t.Errorf("CanHaveEdges(%q) = %v, want %v", id, have, want)
+		}
+	}
+}
+
+func TestMakeEndpointNodeIDBoth(t *testing.T) {
+	scoped, unscoped := report.MakeEndpointNodeIDBoth(clientHostID, "127.0.0.1", "8080")
+	if want := report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "8080"); scoped != want {
+		t.Errorf("scoped: want %q, have %q", want, scoped)
+	}
+	if want := ";127.0.0.1;8080"; unscoped != want {
+		t.Errorf("unscoped: want %q, have %q", want, unscoped)
+	}
+	if scope, address, port, ok := report.ParseEndpointNodeID(unscoped); !ok || scope != "" || address != "127.0.0.1" || port != "8080" {
+		t.Errorf("unscoped: parsed as {%q, %q, %q}", scope, address, port)
+	}
+}
+
+func TestParseEndpointNodeIDStrict(t *testing.T) {
+	for bad, reason := range map[string]string{
+		"host;127.0.0.1;":    "empty trailing port field",
+		";;127.0.0.1;80":     "expected 3 fields, got 4",
+		"host;;80":           "empty address field",
+		"host;127.0.0.1;;80": "expected 3 fields, got 4",
+		"host;127.0.0.1":     "expected 3 fields, got 2",
+	} {
+		_, _, _, err := report.ParseEndpointNodeIDStrict(bad)
+		if err == nil {
+			t.Errorf("%q: expected error", bad)
+			continue
+		}
+		if !strings.Contains(err.Error(), reason) || !strings.Contains(err.Error(), bad) {
+			t.Errorf("%q: unexpected error %v", bad, err)
+		}
+	}
+
+	for _, good := range []string{server80EndpointNodeID, report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80")} {
+		wantScope, wantAddress, wantPort, _ := report.ParseEndpointNodeID(good)
+		scope, address, port, err := report.ParseEndpointNodeIDStrict(good)
+		if err != nil || scope != wantScope || address != wantAddress || port != wantPort {
+			t.Errorf("%q: have {%q, %q, %q, %v}", good, scope, address, port, err)
+		}
+	}
+}
+
+func TestBareID(t *testing.T) {
+	for id, want := range map[string]string{
+		client54001EndpointNodeID:         clientAddressNodeID,
+		clientAddressNodeID:               clientAddressNodeID,
+		clientHostNodeID:                  clientHostID,
+		report.MakeContainerNodeID("abc"): "abc",
+	} {
+		if have := report.BareID(id); have != want {
+			t.Errorf("BareID(%q) = %q, want %q", id, have, want)
+		}
+	}
+}
+
+func TestColorBucket(t *testing.T) {
+	const buckets = 16
+	a := report.ColorBucket(client54001EndpointNodeID, buckets)
+	b := report.ColorBucket(client54002EndpointNodeID, buckets)
+	if a != b {
+		t.Errorf("endpoints differing only by port got buckets %d and %d", a, b)
+	}
+	if a < 0 || a >= buckets {
+		t.Errorf("bucket %d out of range", a)
+	}
+	if have := report.ColorBucket(client54001EndpointNodeID, 0); have != 0 {
+		t.Errorf("zero buckets: have %d", have)
+	}
+}
+
+func TestIsPrivateAddressID(t *testing.T) {
+	for id, want := range map[string]bool{
+		report.MakeAddressNodeID("", "10.1.2.3"):              true,
+		report.MakeAddressNodeID("", "192.168.0.1"):           true,
+		report.MakeEndpointNodeID("", "", "172.16.5.4", "80"): true,
+		report.MakeEndpointNodeID("", "", "172.32.0.1", "80"): false,
+		report.MakeAddressNodeID("", "169.254.1.1"):           true,
+		report.MakeAddressNodeID("", "fd00::1"):               true,
+		report.MakeEndpointNodeID("", "", "fe80::1", "80"):    true,
+		report.MakeEndpointNodeID("", "", "8.8.8.8", "53"):    false,
+		report.MakeAddressNodeID("", "2001:4860:4860::8888"):  false,
+		clientHostNodeID: false,
+	} {
+		if have := report.IsPrivateAddressID(id); have != want {
+			t.Errorf("IsPrivateAddressID(%q) = %v, want %v", id, have, want)
+		}
+	}
+}
+
+func TestJoinHostAndRemainder(t *testing.T) {
+	for remainder, want := range map[string]string{
+		"1.2.3.4;80":  "host.com;1.2.3.4;80",
+		";1.2.3.4;80": "host.com;1.2.3.4;80",
+		";;1234":      "host.com;1234",
+	} {
+		if have := report.JoinHostAndRemainder("host.com", remainder); have != want {
+			t.Errorf("JoinHostAndRemainder(%q) = %q, want %q", remainder, have, want)
+		}
+	}
+}
+
+func TestCoalesceEphemeralPort(t *testing.T) {
+	a, okA := report.CoalesceEphemeralPort(report.MakeEndpointNodeID("", "", clientAddress, "40000"), 32768, 60999)
+	b, okB := report.CoalesceEphemeralPort(report.MakeEndpointNodeID("", "", clientAddress, "50000"), 32768, 60999)
+	if !okA || !okB || a != b {
+		t.Errorf("expected ephemeral ports to coalesce, have %q (%v) and %q (%v)", a, okA, b, okB)
+	}
+	if !report.IsEphemeralEndpoint(a) {
+		t.Errorf("%q: expected ephemeral endpoint", a)
+	}
+
+	if have, ok := report.CoalesceEphemeralPort(server80EndpointNodeID, 32768, 60999); ok || have != server80EndpointNodeID {
+		t.Errorf("port 80 coalesced to %q", have)
+	}
+	if report.IsEphemeralEndpoint(server80EndpointNodeID) {
+		t.Errorf("%q: unexpected ephemeral endpoint", server80EndpointNodeID)
+	}
+	if _, ok := report.CoalesceEphemeralPort(clientAddressNodeID, 32768, 60999); ok {
+		t.Errorf("address node ID coalesced")
+	}
+}
+
+func TestL7EndpointNodeID(t *testing.T) {
+	http := report.MakeL7EndpointNodeID(serverHostID, "", serverAddress, "80", "http")
+	if scope, address, port, l7proto, ok := report.ParseL7EndpointNodeID(http); !ok || scope != "" || address != serverAddress || port != "80" || l7proto != "http" {
+		t.Errorf("%q: parsed as {%q, %q, %q, %q, %v}", http, scope, address, port, l7proto, ok)
+	}
+	if ip := report.EndpointIDAddresser(http); !ip.Equal(net.ParseIP(serverAddress)) {
+		t.Errorf("%q: want %s, have %v", http, serverAddress, ip)
+	}
+	if typ, ok := report.ClassifyNodeID(http); !ok || typ != report.EndpointNodeIDType {
+		t.Errorf("%q: classified as %q (%v)", http, typ, ok)
+	}
+
+	if base := report.MakeL7EndpointNodeID(serverHostID, "", serverAddress, "80", ""); base != server80EndpointNodeID {
+		t.Errorf("want %q, have %q", server80EndpointNodeID, base)
+	}
+	if _, _, port, l7proto, ok := report.ParseL7EndpointNodeID(server80EndpointNodeID); !ok || port != "80" || l7proto != "" {
+		t.Errorf("%q: parsed as {%q, %q, %v}", server80EndpointNodeID, port, l7proto, ok)
+	}
+	if _, _, _, _, ok := report.ParseL7EndpointNodeID(clientHostNodeID); ok {
+		t.Errorf("%q: expected failure", clientHostNodeID)
+	}
+
+	if scoped := report.MakeL7EndpointNodeID(serverHostID, "4026531969", "127.0.0.1", "80", "http"); scoped != "server.host.com-4026531969;127.0.0.1;80/http" {
+		t.Errorf("namespaced: have %q", scoped)
+	}
+}
+
+func TestL7EndpointNodeIDPortHelpers(t *testing.T) {
+	http := report.MakeL7EndpointNodeID(serverHostID, "", serverAddress, "80", "http")
+	if _, _, port, err := report.ParseEndpointNodeIDStrict(http); err != nil || port != "80" {
+		t.Errorf("%q: want port 80, have %q (%v)", http, port, err)
+	}
+	if _, _, port, ok := report.ParseEndpointNodeID(http); !ok || port != "80" {
+		t.Errorf("%q: want port 80, have %q (%v)", http, port, ok)
+	} else if sanitized, ok := report.SanitizePort(port); !ok || sanitized != "80" {
+		t.Errorf("%q: port sanitized to %q (%v)", http, sanitized, ok)
+	}
+	if report.IsEphemeralEndpoint(http) {
+		t.Errorf("%q: unexpected ephemeral endpoint", http)
+	}
+	if !report.IsStableIdentity(http) {
+		t.Errorf("%q: expected stable identity", http)
+	}
+
+	client := report.MakeL7EndpointNodeID(clientHostID, "", clientAddress, "54001", "http")
+	coalesced, ok := report.CoalesceEphemeralPort(client, 32768, 60999)
+	if want := report.MakeL7EndpointNodeID(clientHostID, "", clientAddress, report.EphemeralPort, "http"); !ok || coalesced != want {
+		t.Errorf("%q: want %q, have %q (%v)", client, want, coalesced, ok)
+	}
+	if !report.IsEphemeralEndpoint(coalesced) {
+		t.Errorf("%q: expected ephemeral endpoint", coalesced)
+	}
+	if !report.SameExceptEphemeral(client, report.MakeL7EndpointNodeID(clientHostID, "", clientAddress, "54002", "http")) {
+		t.Errorf("%q: expected same except ephemeral port", client)
+	}
+
+	redacted, ok := report.RedactPort(http, map[string]bool{"80": true})
+	if want := report.MakeL7EndpointNodeID(serverHostID, "", serverAddress, report.RedactedPort, "http"); !ok || redacted != want {
+		t.Errorf("%q: want %q, have %q (%v)", http, want, redacted, ok)
+	}
+	if _, _, _, l7proto, _ := report.ParseL7EndpointNodeID(redacted); l7proto != "http" {
+		t.Errorf("%q: protocol lost, have %q", redacted, l7proto)
+	}
+}
+
+func TestValidHostID(t *testing.T) {
+	for _, hostID := range []string{clientHostID, "host-1", "ip-10-0-0-1.ec2.internal"} {
+		if err := report.ValidHostID(hostID); err != nil {
+			t.Errorf("%q: unexpected error: %v", hostID, err)
+		}
+	}
+	for _, hostID := range []string{"", "evil;host", "evil|host"} {
+		if err := report.ValidHostID(hostID); err == nil {
+			t.Errorf("%q: expected error", hostID)
+		}
+	}
+}
+
+func TestRedactPort(t *testing.T) {
+	hidden := map[string]bool{"22": true}
+	ssh := report.MakeEndpointNodeID(serverHostID, "", serverAddress, "22")
+	want := report.MakeEndpointNodeID(serverHostID, "", serverAddress, report.RedactedPort)
+	if have, ok := report.RedactPort(ssh, hidden); !ok || have != want {
+		t.Errorf("want %q, have %q (%v)", want, have, ok)
+	}
+	if have, ok := report.RedactPort(server80EndpointNodeID, hidden); ok || have != server80EndpointNodeID {
+		t.Errorf("port 80 redacted to %q", have)
+	}
+	if _, ok := report.RedactPort(clientAddressNodeID, hidden); ok {
+		t.Errorf("address node ID redacted")
+	}
+}
+
+func TestHostExposedPorts(t *testing.T) {
+	ids := []string{
+		report.MakeWildcardEndpointNodeID(serverHostID, "443"),
+		report.MakeWildcardEndpointNodeID(serverHostID, "8080"),
+		report.MakeEndpointNodeID(serverHostID, "", "127.0.0.1", "80"),
+		report.MakeEndpointNodeID(serverHostID, "", "127.0.0.1", "443"),
+		report.MakeEndpointNodeID(serverHostID, "", "127.0.0.1", "45000"),
+		report.MakeWildcardEndpointNodeID(clientHostID, "22"),
+		server80EndpointNodeID, // unscoped
+		serverHostNodeID,
+	}
+	if want, have := []string{"80", "443", "8080"}, report.HostExposedPorts(ids, serverHostID); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestIDParseError(t *testing.T) {
+	for bad, parse := range map[string]func(string) error{
+		"nodelimiter": func(id string) error { _, _, err := report.ParseNodeIDErr(id); return err },
+		"a;b":         func(id string) error { _, _, _, err := report.ParseEndpointNodeIDErr(id); return err },
+		"noaddress":   func(id string) error { _, _, err := report.ParseAddressNodeIDErr(id); return err },
+		"nopid":       func(id string) error { _, _, err := report.ParseProcessNodeIDErr(id); return err },
+		"a;b;":        func(id string) error { _, _, _, err := report.ParseEndpointNodeIDStrict(id); return err },
+	} {
+		err := parse(bad)
+		if err == nil {
+			t.Errorf("%q: expected error", bad)
+			continue
+		}
+		parseErr, ok := err.(*report.IDParseError)
+		if !ok {
+			t.Errorf("%q: expected *IDParseError, got %T", bad, err)
+			continue
+		}
+		if parseErr.ID != bad || !strings.Contains(err.Error(), bad) {
+			t.Errorf("%q: error does not include the bad ID: %v", bad, err)
+		}
+	}
+
+	if _, _, _, err := report.ParseEndpointNodeIDErr(server80EndpointNodeID); err != nil {
+		t.Errorf("%q: unexpected error %v", server80EndpointNodeID, err)
+	}
+}
+
+func TestEdgeID(t *testing.T) {
+	edgeID := report.MakeEdgeID(client54001EndpointNodeID, server80EndpointNodeID)
+	src, dst, ok := report.ParseEdgeID(edgeID)
+	if !ok || src != client54001EndpointNodeID || dst != server80EndpointNodeID {
+		t.Errorf("%q: parsed as {%q, %q, %v}", edgeID, src, dst, ok)
+	}
+	if _, _, ok := report.ParseEdgeID(client54001EndpointNodeID); ok {
+		t.Errorf("%q: expected failure", client54001EndpointNodeID)
+	}
+}
+
+func TestCollapsesToSelfLoop(t *testing.T) {
+	edgeID := report.MakeEdgeID(clientHostNodeID, serverHostNodeID)
+	if !report.CollapsesToSelfLoop(edgeID, clientHostID, serverHostID) {
+		t.Errorf("%q: expected self-loop after rewriting %q to %q", edgeID, clientHostID, serverHostID)
+	}
+	if report.CollapsesToSelfLoop(edgeID, clientHostID, "other.host.com") {
+		t.Errorf("%q: unexpected self-loop", edgeID)
+	}
+
+	loopback := report.MakeEdgeID(
+		report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"),
+		report.MakeEndpointNodeID(serverHostID, "", "127.0.0.1", "8080"),
+	)
+	if report.CollapsesToSelfLoop(loopback, clientHostID, serverHostID) {
+		t.Errorf("%q: unexpected self-loop", loopback)
+	}
+	if report.CollapsesToSelfLoop("not an edge", clientHostID, serverHostID) {
+		t.Errorf("malformed edge collapsed")
+	}
+}
+
+func TestNormalizeHexField(t *testing.T) {
+	for input, want := range map[string]string{
+		"ABC123": "abc123",
+		"abc123": "abc123",
+		"AbC123": "abc123",
+		"MyName": "MyName",
+		"":       "",
+	} {
+		if have := report.NormalizeHexField(input); have != want {
+			t.Errorf("NormalizeHexField(%q) = %q, want %q", input, have, want)
+		}
+	}
+}
+
+func TestNormalizeContainerIDs(t *testing.T) {
+	if report.MakeContainerNodeID("ABC123") == report.MakeContainerNodeID("abc123") {
+		t.Errorf("container IDs normalized by default")
+	}
+
+	report.NormalizeContainerIDs = true
+	defer func() { report.NormalizeContainerIDs = false }()
+	if have, want := report.MakeContainerNodeID("ABC123"), report.MakeContainerNodeID("abc123"); have != want {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if id, ok := report.ParseContainerNodeID(report.MakeContainerNodeID("AbC123")); !ok || id != "abc123" {
+		t.Errorf("parsed as {%q, %v}", id, ok)
+	}
+}
+
+func TestZonedLoopback(t *testing.T) {
+	for _, address := range []string{"::1%lo0", "127.0.0.1", "::1"} {
+		if !report.IsLoopback(address) {
+			t.Errorf("%q: expected loopback", address)
+		}
+		if have, want := report.MakeAddressNodeID(clientHostID, address), clientHostID+";"+address; have != want {
+			t.Errorf("%q: want %q, have %q", address, want, have)
+		}
+		if have, want := report.MakeEndpointNodeID(clientHostID, "", address, "80"), clientHostID+";"+address+";80"; have != want {
+			t.Errorf("%q: want %q, have %q", address, want, have)
+		}
+		if ip := report.AddressIDAddresser(report.MakeAddressNodeID(clientHostID, address)); ip == nil || !ip.IsLoopback() {
+			t.Errorf("%q: address ID addressed as %v", address, ip)
+		}
+		if ip := report.EndpointIDAddresser(report.MakeEndpointNodeID(clientHostID, "", address, "80")); ip == nil || !ip.IsLoopback() {
+			t.Errorf("%q: endpoint ID addressed as %v", address, ip)
+		}
+	}
+	if report.IsLoopback("fe80::1%eth0") {
+		t.Errorf("fe80::1%%eth0: unexpected loopback")
+	}
+}
+
+func TestHasEmptyHost(t *testing.T) {
+	for id, want := range map[string]bool{
+		unknownAddressNodeID:                                           true,
+		client54001EndpointNodeID:                                      true,
+		report.MakeAddressNodeID(clientHostID, "127.0.0.1"):            false,
+		report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"): false,
+		clientHostNodeID:                                               false,
+		"":                                                             false,
+	} {
+		if have := report.HasEmptyHost(id); have != want {
+			t.Errorf("HasEmptyHost(%q) = %v, want %v", id, have, want)
+		}
+	}
+}
+
+func TestWeightedEdgeID(t *testing.T) {
+	for _, weight := range []int{0, 1, 1 << 40, -3} {
+		edgeID := report.MakeWeightedEdgeID(client54001EndpointNodeID, server80EndpointNodeID, weight)
+		src, dst, have, ok := report.ParseWeightedEdgeID(edgeID)
+		if !ok || src != client54001EndpointNodeID || dst != server80EndpointNodeID || have != weight {
+			t.Errorf("%q: parsed as {%q, %q, %d, %v}", edgeID, src, dst, have, ok)
+		}
+	}
+	for _, bad := range []string{
+		report.MakeEdgeID(client54001EndpointNodeID, server80EndpointNodeID),
+		client54001EndpointNodeID + "^3",
+		report.MakeEdgeID("a", "b") + "^x",
+	} {
+		if _, _, _, ok := report.ParseWeightedEdgeID(bad); ok {
+			t.Errorf("%q: expected failure", bad)
+		}
+	}
+}
+
+func TestContainerIDFromEndpoint(t *testing.T) {
+	id := report.MakeContainerScopedEndpointNodeID("abc123", "127.0.0.1", "80")
+	if containerID, ok := report.ContainerIDFromEndpoint(id); !ok || containerID != "abc123" {
+		t.Errorf("%q: have {%q, %v}", id, containerID, ok)
+	}
+	if _, address, port, ok := report.ParseEndpointNodeID(id); !ok || address != "127.0.0.1" || port != "80" {
+		t.Errorf("%q: not parseable as an endpoint", id)
+	}
+	if _, kind, ok := report.CommonScope(id, report.MakeContainerScopedEndpointNodeID("abc123", "127.0.0.1", "8080")); !ok || kind != "container" {
+		t.Errorf("%q: expected a common container scope, have %q", id, kind)
+	}
+
+	for _, plain := range []string{
+		report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"),
+		server80EndpointNodeID,
+		report.MakeContainerNodeID("abc123"),
+	} {
+		if containerID, ok := report.ContainerIDFromEndpoint(plain); ok {
+			t.Errorf("%q: unexpected container %q", plain, containerID)
+		}
+	}
+}
+
+func TestSanitizePort(t *testing.T) {
+	for port, want := range map[string]string{
+		"080":   "80",
+		"80":    "80",
+		"65535": "65535",
+		"0":     "",
+		"70000": "",
+		"-1":    "",
+		"+80":   "",
+		"http":  "",
+		"":      "",
+	} {
+		have, ok := report.SanitizePort(port)
+		if ok != (want != "") || have != want {
+			t.Errorf("SanitizePort(%q) = (%q, %v), want %q", port, have, ok, want)
+		}
+	}
+
+	a, okA := report.MakeEndpointNodeIDChecked(serverHostID, "", serverAddress, "080")
+	b, okB := report.MakeEndpointNodeIDChecked(serverHostID, "", serverAddress, "80")
+	if !okA || !okB || a != b || a != server80EndpointNodeID {
+		t.Errorf("want %q, have %q (%v) and %q (%v)", server80EndpointNodeID, a, okA, b, okB)
+	}
+	if id, ok := report.MakeEndpointNodeIDChecked(serverHostID, "", serverAddress, "70000"); ok {
+		t.Errorf("unexpected ID %q for invalid port", id)
+	}
+}
+
+func TestAddressNodeIDWithName(t *testing.T) {
+	id := report.MakeAddressNodeIDWithName("", "8.8.8.8", "dns.google")
+	hostID, address, name, ok := report.ParseAddressNodeIDWithName(id)
+	if !ok || hostID != "" || address != "8.8.8.8" || name != "dns.google" {
+		t.Errorf("%q: parsed as {%q, %q, %q, %v}", id, hostID, address, name, ok)
+	}
+	if ip := report.AddressIDAddresser(id); !ip.Equal(net.ParseIP("8.8.8.8")) {
+		t.Errorf("%q: addressed as %v", id, ip)
+	}
+
+	hostID, address, name, ok = report.ParseAddressNodeIDWithName(clientAddressNodeID)
+	if !ok || address != clientAddress || name != "" {
+		t.Errorf("%q: parsed as {%q, %q, %q, %v}", clientAddressNodeID, hostID, address, name, ok)
+	}
+	if ip := report.AddressIDAddresser(clientAddressNodeID); !ip.Equal(net.ParseIP(clientAddress)) {
+		t.Errorf("%q: addressed as %v", clientAddressNodeID, ip)
+	}
+}
+
+func TestSameExceptEphemeral(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{report.MakeEndpointNodeID("", "", clientAddress, "40000"), report.MakeEndpointNodeID("", "", clientAddress, "50001"), true},
+		{report.MakeEndpointNodeID("", "", clientAddress, "80"), report.MakeEndpointNodeID("", "", clientAddress, "81"), false},
+		{report.MakeEndpointNodeID("", "", clientAddress, "40000"), report.MakeEndpointNodeID("", "", serverAddress, "40000"), false},
+		{report.MakeEndpointNodeID("", "", clientAddress, "40000"), report.MakeEndpointNodeID("", "", clientAddress, "80"), false},
+		{clientAddressNodeID, clientAddressNodeID, false},
+	} {
+		if have := report.SameExceptEphemeral(tc.a, tc.b); have != tc.want {
+			t.Errorf("SameExceptEphemeral(%q, %q) = %v, want %v", tc.a, tc.b, have, tc.want)
+		}
+	}
+}
+
+func TestDOTSafeID(t *testing.T) {
+	dotID := regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	for _, id := range []string{
+		client54001EndpointNodeID,
+		report.MakeEndpointNodeID(clientHostID, "", "::1", "80"),
+		report.MakeEdgeID(client54001EndpointNodeID, server80EndpointNodeID),
+		clientHostNodeID,
+		"with_underscore",
+		"1leadingdigit",
+		"",
+	} {
+		safe := report.DOTSafeID(id)
+		if !dotID.MatchString(safe) {
+			t.Errorf("%q: %q is not a valid DOT ID", id, safe)
+		}
+		if have, ok := report.ParseDOTSafeID(safe); !ok || have != id {
+			t.Errorf("%q: round-tripped to {%q, %v}", id, have, ok)
+		}
+	}
+	if report.DOTSafeID("a;b") == report.DOTSafeID("a|b") {
+		t.Errorf("distinct IDs encoded identically")
+	}
+	for _, bad := range []string{"x", "n_3", "n_zz"} {
+		if have, ok := report.ParseDOTSafeID(bad); ok {
+			t.Errorf("%q: expected failure, got %q", bad, have)
+		}
+	}
+}
+
+func TestCgroupNodeID(t *testing.T) {
+	for _, path := range []string{
+		"/",
+		"/system.slice/docker.service",
+		"/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-abc123.scope",
+		"/weird;path|with%chars",
+	} {
+		id := report.MakeCgroupNodeID(clientHostID, path)
+		if strings.Count(id, ";") != 2 {
+			t.Errorf("%q: path not escaped: %q", path, id)
+		}
+		hostID, have, ok := report.ParseCgroupNodeID(id)
+		if !ok || hostID != clientHostID || have != path {
+			t.Errorf("%q: parsed as {%q, %q, %v}", id, hostID, have, ok)
+		}
+	}
+	for _, bad := range []string{clientHostNodeID, server80EndpointNodeID, "host;%zz;<cgroup>"} {
+		if _, _, ok := report.ParseCgroupNodeID(bad); ok {
+			t.Errorf("%q: expected failure", bad)
+		}
+	}
+}
+
+func TestNetnsAddressNodeID(t *testing.T) {
+	a := report.MakeNetnsAddressNodeID("ip-10-0-0-1", "4026531993", "127.0.0.1")
+	b := report.MakeNetnsAddressNodeID("ip-10-0-0-1", "4026532281", "127.0.0.1")
+	if a == b {
+		t.Errorf("loopback addresses in different network namespaces share the ID %q", a)
+	}
+	if want := report.MakeEndpointNodeID("ip-10-0-0-1", "4026531993", "127.0.0.1", "80"); !strings.HasPrefix(want, a+";") {
+		t.Errorf("address ID %q doesn't match endpoint ID %q", a, want)
+	}
+	hostID, netns, address, ok := report.ParseNetnsAddressNodeID(a)
+	if !ok || hostID != "ip-10-0-0-1" || netns != "4026531993" || address != "127.0.0.1" {
+		t.Errorf("%q: parsed as {%q, %q, %q, %v}", a, hostID, netns, address, ok)
+	}
+	for _, bad := range []string{
+		"ip-10-0-0-1;127.0.0.1", // host ID without a network namespace
+		"host-abc;127.0.0.1",
+		"host-99999999999;127.0.0.1",
+		"-4026531993;127.0.0.1",
+		clientAddressNodeID,
+	} {
+		if hostID, netns, address, ok := report.ParseNetnsAddressNodeID(bad); ok {
+			t.Errorf("%q: expected failure, parsed as {%q, %q, %q}", bad, hostID, netns, address)
+		}
+	}
+
+	// Non-loopback addresses aren't scoped by network namespace
+	if have, want := report.MakeNetnsAddressNodeID(clientHostID, "4026531993", clientAddress), clientAddressNodeID; have != want {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestCollectHosts(t *testing.T) {
+	have := report.CollectHosts([]string{
+		report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"),
+		client54001EndpointNodeID, // public, so unscoped
+		report.MakeProcessNodeID(serverHostID, "42"),
+		clientHostNodeID,
+		report.MakeHostNodeID("other.host.com"),
+		report.MakeContainerNodeID("abc"),
+		"pseudo:uncontained:" + clientHostID,
+		"pseudo;theinternet",
+		"in-theinternet",
+	})
+	if want := []string{clientHostID, "other.host.com", serverHostID}; !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestFlowNodeID(t *testing.T) {
+	for _, want := range [][5]string{
+		{"tcp", clientAddress, "54001", serverAddress, "80"},
+		{"udp", "fd00::1", "40000", "fd00::53", "53"},
+	} {
+		id := report.MakeFlowNodeID(want[0], want[1], want[2], want[3], want[4])
+		proto, srcIP, srcPort, dstIP, dstPort, ok := report.ParseFlowNodeID(id)
+		if have := [5]string{proto, srcIP, srcPort, dstIP, dstPort}; !ok || have != want {
+			t.Errorf("%q: parsed as %v (%v), want %v", id, have, ok, want)
+		}
+	}
+	if report.MakeFlowNodeID("tcp", clientAddress, "54001", serverAddress, "80") == report.MakeFlowNodeID("tcp", serverAddress, "80", clientAddress, "54001") {
+		t.Errorf("flows in opposite directions share an ID")
+	}
+	if _, _, _, _, _, ok := report.ParseFlowNodeID(server80EndpointNodeID); ok {
+		t.Errorf("%q: expected failure", server80EndpointNodeID)
+	}
+}
+
+func TestProcessNodeIDWithStart(t *testing.T) {
+	a := report.MakeProcessNodeIDWithStart(clientHostID, "42", "1697000000")
+	b := report.MakeProcessNodeIDWithStart(clientHostID, "42", "1697000500")
+	if a == b {
+		t.Errorf("processes with the same PID and different start times share the ID %q", a)
+	}
+	hostID, pid, startTime, ok := report.ParseProcessNodeIDWithStart(a)
+	if !ok || hostID != clientHostID || pid != "42" || startTime != "1697000000" {
+		t.Errorf("%q: parsed as {%q, %q, %q, %v}", a, hostID, pid, startTime, ok)
+	}
+	if _, _, _, ok := report.ParseProcessNodeIDWithStart(server80EndpointNodeID); ok {
+		t.Errorf("%q: expected failure", server80EndpointNodeID)
+	}
+}
+
+func TestPortDisplayName(t *testing.T) {
+	for port, want := range map[string]string{
+		"80":    "80 (http)",
+		"443":   "443 (https)",
+		"22":    "22 (ssh)",
+		"54001": "54001",
+	} {
+		if have := report.PortDisplayName(port); have != want {
+			t.Errorf("PortDisplayName(%q) = %q, want %q", port, have, want)
+		}
+	}
+	if have, want := report.EndpointIDPortDisplay(server80EndpointNodeID), "80 (http)"; have != want {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if have := report.EndpointIDPortDisplay(clientHostNodeID); have != "" {
+		t.Errorf("%q: unexpected port %q", clientHostNodeID, have)
+	}
+}
+
+func TestAdjacencyIDs(t *testing.T) {
+	edgeID := report.MakeEdgeID(client54001EndpointNodeID, server80EndpointNodeID)
+	srcAdj, dstAdj, ok := report.EdgeToAdjacencyIDs(edgeID)
+	if !ok {
+		t.Fatalf("%q: expected success", edgeID)
+	}
+	if src, ok := report.ParseAdjacencyID(srcAdj); !ok || src != client54001EndpointNodeID {
+		t.Errorf("%q: parsed as {%q, %v}", srcAdj, src, ok)
+	}
+	if have, ok := report.AdjacencyIDsToEdge(srcAdj, dstAdj); !ok || have != edgeID {
+		t.Errorf("want %q, have {%q, %v}", edgeID, have, ok)
+	}
+
+	if _, ok := report.AdjacencyIDsToEdge(client54001EndpointNodeID, dstAdj); ok {
+		t.Errorf("expected failure for a node ID which isn't an adjacency ID")
+	}
+	if _, ok := report.AdjacencyIDsToEdge(srcAdj, server80EndpointNodeID); ok {
+		t.Errorf("expected failure for a node ID which isn't an adjacency ID")
+	}
+}
+
+func TestIsMulticastOrBroadcastID(t *testing.T) {
+	for id, want := range map[string]bool{
+		report.MakeAddressNodeID("", "224.0.0.1"):                  true,
+		report.MakeEndpointNodeID("", "", "224.0.0.251", "5353"):   true,
+		report.MakeAddressNodeID("", "ff02::1"):                    true,
+		report.MakeEndpointNodeID("", "", "255.255.255.255", "67"): true,
+		serverAddressNodeID:    false,
+		server80EndpointNodeID: false,
+		clientHostNodeID:       false,
+	} {
+		if have := report.IsMulticastOrBroadcastID(id); have != want {
+			t.Errorf("IsMulticastOrBroadcastID(%q) = %v, want %v", id, have, want)
+		}
+	}
+}
+
+func TestIsUnspecifiedAddressID(t *testing.T) {
+	for id, want := range map[string]bool{
+		report.MakeAddressNodeID("", "0.0.0.0"):            true,
+		report.MakeEndpointNodeID("", "", "0.0.0.0", "80"): true,
+		report.MakeAddressNodeID("", "::"):                 true,
+		report.MakeEndpointNodeID("", "", "::", "443"):     true,
+		serverAddressNodeID:                                false,
+		server80EndpointNodeID:                             false,
+		clientHostNodeID:                                   false,
+	} {
+		if have := report.IsUnspecifiedAddressID(id); have != want {
+			t.Errorf("IsUnspecifiedAddressID(%q) = %v, want %v", id, have, want)
+		}
+	}
+}
+
+func TestIsCloudMetadataEndpoint(t *testing.T) {
+	for id, want := range map[string]bool{
+		report.MakeEndpointNodeID("", "", "169.254.169.254", "80"): true,
+		report.MakeEndpointNodeID("", "", "fd00:ec2::254", "80"):   true,
+		server80EndpointNodeID:                          false,
+		report.MakeAddressNodeID("", "169.254.169.254"): false,
+	} {
+		if have := report.IsCloudMetadataEndpoint(id); have != want {
+			t.Errorf("IsCloudMetadataEndpoint(%q) = %v, want %v", id, have, want)
+		}
+	}
+}
+
+func TestShortContainerID(t *testing.T) {
+	full := report.MakeContainerNodeID("4a1b5c96d7b4e6e3b4dd1f02fb1decd1a1e6a1a5c21a6b2c4b4fbe4c3a7d5e6f")
+	short, truncated := report.ShortContainerID(full)
+	if !truncated || short != report.MakeContainerNodeID("4a1b5c96d7b4") {
+		t.Errorf("%q: have {%q, %v}", full, short, truncated)
+	}
+	if containerID, ok := report.ParseContainerNodeID(short); !ok || containerID != "4a1b5c96d7b4" {
+		t.Errorf("%q: parsed as {%q, %v}", short, containerID, ok)
+	}
+	if ip := report.AddressIDAddresser(short); ip != nil {
+		t.Errorf("%q: unexpected address %v", short, ip)
+	}
+
+	if have, truncated := report.ShortContainerID(short); truncated || have != short {
+		t.Errorf("%q: have {%q, %v}", short, have, truncated)
+	}
+	if have, truncated := report.ShortContainerID(clientHostNodeID); truncated || have != clientHostNodeID {
+		t.Errorf("%q: have {%q, %v}", clientHostNodeID, have, truncated)
+	}
+}
+
+func TestNormalizeEndpointNodeID(t *testing.T) {
+	mapped, okMapped := report.NormalizeEndpointNodeID(report.MakeEndpointNodeID("", "", "::ffff:10.0.0.1", "80"))
+	plain, okPlain := report.NormalizeEndpointNodeID(report.MakeEndpointNodeID("", "", "10.0.0.1", "80"))
+	if !okMapped || !okPlain || mapped != plain || plain != ";10.0.0.1;80" {
+		t.Errorf("have %q (%v) and %q (%v)", mapped, okMapped, plain, okPlain)
+	}
+	if have, ok := report.NormalizeEndpointNodeID(report.MakeEndpointNodeID("", "", "fd00::1", "80")); !ok || have != ";fd00::1;80" {
+		t.Errorf("IPv6 endpoint changed: %q (%v)", have, ok)
+	}
+	if have, ok := report.NormalizeEndpointNodeID(clientHostNodeID); ok || have != clientHostNodeID {
+		t.Errorf("%q: have %q (%v)", clientHostNodeID, have, ok)
+	}
+}
+
+func TestSameHostProcesses(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{report.MakeProcessNodeID(clientHostID, "1"), report.MakeProcessNodeID(clientHostID, "2"), true},
+		{report.MakeProcessNodeID(clientHostID, "1"), report.MakeProcessNodeIDWithStart(clientHostID, "2", "1697000000"), true},
+		{report.MakeProcessNodeID(clientHostID, "1"), report.MakeProcessNodeID(serverHostID, "1"), false},
+		{report.MakeProcessNodeID(clientHostID, "1"), clientHostNodeID, false},
+		{report.MakeProcessNodeID(clientHostID, "1"), report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"), false},
+	} {
+		if have := report.SameHostProcesses(tc.a, tc.b); have != tc.want {
+			t.Errorf("SameHostProcesses(%q, %q) = %v, want %v", tc.a, tc.b, have, tc.want)
+		}
+	}
+}
+
+func TestWildcardEndpoint(t *testing.T) {
+	id := report.MakeWildcardEndpointNodeID(serverHostID, "80")
+	if want := serverHostID + ";0.0.0.0;80"; id != want {
+		t.Errorf("want %q, have %q", want, id)
+	}
+	for id, want := range map[string]bool{
+		id: true,
+		report.MakeScopedEndpointNodeID(serverHostID, "0.0.0.0", "80"): true,
+		report.MakeScopedEndpointNodeID(serverHostID, "::", "80"):      true,
+		server80EndpointNodeID:                            false,
+		report.MakeAddressNodeID(serverHostID, "0.0.0.0"): false,
+	} {
+		if have := report.IsWildcardEndpoint(id); have != want {
+			t.Errorf("IsWildcardEndpoint(%q) = %v, want %v", id, have, want)
+		}
+	}
+	if report.MakeWildcardEndpointNodeID(clientHostID, "80") == id {
+		t.Errorf("wildcard endpoints on different hosts share an ID")
+	}
+}
+
+func TestHostField(t *testing.T) {
+	for id, want := range map[string]string{
+		report.MakeProcessNodeID(clientHostID, "42"):                   clientHostID,
+		report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"): clientHostID,
+		clientHostNodeID:       clientHostID,
+		server80EndpointNodeID: "",
+	} {
+		if have, ok := report.HostField(id); !ok || have != want {
+			t.Errorf("HostField(%q) = (%q, %v), want %q", id, have, ok, want)
+		}
+	}
+	if have, ok := report.HostField("nodelimiter"); ok {
+		t.Errorf("unexpected host %q", have)
+	}
+	id := report.MakeProcessNodeID(clientHostID, "42")
+	if allocs := testing.AllocsPerRun(100, func() { report.HostField(id) }); allocs != 0 {
+		t.Errorf("HostField allocated %v times", allocs)
+	}
+}
+
+func BenchmarkHostField(b *testing.B) {
+	id := report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		report.HostField(id)
+	}
+}
+
+func TestTimestampedNodeID(t *testing.T) {
+	for _, base := range []string{clientHostNodeID, server80EndpointNodeID, report.MakeContainerNodeID("abc")} {
+		id := report.MakeTimestampedNodeID(base, 1697000000123456789)
+		have, ts, ok := report.ParseTimestampedNodeID(id)
+		if !ok || have != base || ts != 1697000000123456789 {
+			t.Errorf("%q: parsed as {%q, %d, %v}", id, have, ts, ok)
+		}
+	}
+
+	base, _, _ := report.ParseTimestampedNodeID(report.MakeTimestampedNodeID(clientHostNodeID, 0))
+	if id, tag, ok := report.ParseNodeID(base); !ok || id != clientHostID || tag != "<host>" {
+		t.Errorf("%q: parsed as {%q, %q, %v}", base, id, tag, ok)
+	}
+	if hostID, ok := report.ParseHostNodeID(base); !ok || hostID != clientHostID {
+		t.Errorf("%q: parsed as {%q, %v}", base, hostID, ok)
+	}
+
+	for _, bad := range []string{clientHostNodeID, clientHostNodeID + "@later"} {
+		if _, _, ok := report.ParseTimestampedNodeID(bad); ok {
+			t.Errorf("%q: expected failure", bad)
+		}
+	}
+}
+
+func TestQualifiedID(t *testing.T) {
+	for nodeID, topology := range map[string]string{
+		clientHostNodeID:                  report.Host,
+		server80EndpointNodeID:            report.Endpoint,
+		report.MakeContainerNodeID("a!b"): report.Container,
+	} {
+		id := report.MakeQualifiedID(topology, nodeID)
+		haveTopology, haveNodeID, ok := report.ParseQualifiedID(id)
+		if !ok || haveTopology != topology || haveNodeID != nodeID {
+			t.Errorf("%q: parsed as {%q, %q, %v}", id, haveTopology, haveNodeID, ok)
+		}
+	}
+
+	for _, bad := range []string{clientHostNodeID, "!" + clientHostNodeID} {
+		if _, _, ok := report.ParseQualifiedID(bad); ok {
+			t.Errorf("%q: expected failure", bad)
+		}
+	}
+}
+
+func TestFindCaseDuplicateHosts(t *testing.T) {
+	ids := []string{
+		report.MakeHostNodeID("Host1"),
+		report.MakeHostNodeID("host1"),
+		report.MakeProcessNodeID("HOST1", "42"),
+		report.MakeHostNodeID("host2"),
+		report.MakeProcessNodeID("host2", "1"),
+		report.MakeContainerNodeID("Host1"),
+		serverAddressNodeID,
+	}
+	want := map[string][]string{
+		"host1": {report.MakeProcessNodeID("HOST1", "42"), report.MakeHostNodeID("Host1"), report.MakeHostNodeID("host1")},
+	}
+	if have := report.FindCaseDuplicateHosts(ids); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestGroupAddressesByHost(t *testing.T) {
+	loopback4 := report.MakeAddressNodeID(clientHostID, "127.0.0.1")
+	loopback6 := report.MakeAddressNodeID(clientHostID, "::1")
+	ids := []string{
+		loopback4,
+		loopback6,
+		report.MakeAddressNodeID(serverHostID, "127.0.0.1"),
+		clientAddressNodeID,
+		serverAddressNodeID,
+		clientHostNodeID,
+	}
+
+	want := map[string][]string{
+		clientHostID: {loopback4, loopback6},
+		serverHostID: {report.MakeAddressNodeID(serverHostID, "127.0.0.1")},
+	}
+	if have := report.GroupAddressesByHost(ids, nil); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	lookup := func(address string) (string, bool) {
+		return serverHostID, address == serverAddress
+	}
+	want[serverHostID] = []string{serverAddressNodeID, report.MakeAddressNodeID(serverHostID, "127.0.0.1")}
+	if have := report.GroupAddressesByHost(ids, lookup); !reflect.DeepEqual(want, have) {
+		t.Errorf("with lookup: want %v, have %v", want, have)
+	}
+}
+
+func TestLineageID(t *testing.T) {
+	chain := []string{
+		report.MakeProcessNodeID(clientHostID, "42"),
+		report.MakeContainerNodeID("abc"),
+		clientHostNodeID,
+	}
+	id, err := report.MakeLineageID(chain...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, ok := report.ParseLineageID(id); !ok || !reflect.DeepEqual(chain, have) {
+		t.Errorf("%q: parsed as {%v, %v}", id, have, ok)
+	}
+
+	for _, bad := range [][]string{
+		{},
+		{clientHostNodeID, "garbage"},
+		{report.MakeContainerNodeID("a~b")},
+	} {
+		if id, err := report.MakeLineageID(bad...); err == nil {
+			t.Errorf("%v: expected error, have %q", bad, id)
+		}
+	}
+	if _, ok := report.ParseLineageID(clientHostNodeID + "~garbage"); ok {
+		t.Errorf("expected failure parsing lineage with invalid element")
+	}
+}
+
+func TestIsLocalHostID(t *testing.T) {
+	isLocal := report.IsLocalHostID(clientHostID)
+	for id, want := range map[string]bool{
+		clientHostNodeID: true,
+		report.MakeProcessNodeID(clientHostID, "42"):                   true,
+		report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"): true,
+		client54001EndpointNodeID:                                      false, // public, so unscoped
+		serverHostNodeID:                                               false,
+		report.MakeProcessNodeID(serverHostID, "42"):                   false,
+		"pseudo:uncontained:" + clientHostID:                           false,
+		"in-theinternet":                                               false,
+	} {
+		if have := isLocal(id); have != want {
+			t.Errorf("IsLocalHostID(%q)(%q) = %v, want %v", clientHostID, id, have, want)
+		}
+	}
+	if report.IsLocalHostID("pseudo")("pseudo;theinternet") {
+		t.Errorf("legacy pseudo node ID treated as local")
+	}
+}
+
+func TestSocketNodeID(t *testing.T) {
+	id := report.MakeSocketNodeID(clientHostID, "123456")
+	hostID, inode, ok := report.ParseSocketNodeID(id)
+	if !ok || hostID != clientHostID || inode != "123456" {
+		t.Errorf("%q: parsed as {%q, %q, %v}", id, hostID, inode, ok)
+	}
+
+	process := report.MakeProcessNodeID(clientHostID, "123456")
+	if id == process {
+		t.Errorf("socket and process node IDs collide: %q", id)
+	}
+	if _, _, ok := report.ParseSocketNodeID(process); ok {
+		t.Errorf("%q: parsed as a socket node ID", process)
+	}
+	if _, _, _, ok := report.ParseProcessNodeIDWithStart(id); ok {
+		t.Errorf("%q: parsed as a process node ID", id)
+	}
+	if report.SameHostProcesses(id, process) {
+		t.Errorf("%q: treated as a process node ID", id)
+	}
+}
@@ -0,0 +1,468 @@
+package report
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// NodeIDType identifies the scheme a node ID was built with. For
+// single-component node IDs it is the tag the ID carries.
+type NodeIDType string
+
+// Node ID types
+const (
+	EndpointNodeIDType              NodeIDType = "endpoint"
+	AddressNodeIDType               NodeIDType = "address"
+	ProcessNodeIDType               NodeIDType = "process"
+	ECSServiceNodeIDType            NodeIDType = "ecs_service"
+	OverlayNodeIDType               NodeIDType = "overlay"
+	CgroupNodeIDType                NodeIDType = "cgroup"
+	FlowNodeIDType                  NodeIDType = "flow"
+	SocketNodeIDType                NodeIDType = "socket"
+	HostNodeIDType                  NodeIDType = "host"
+	ContainerNodeIDType             NodeIDType = "container"
+	ContainerImageNodeIDType        NodeIDType = "container_image"
+	PodNodeIDType                   NodeIDType = "pod"
+	ServiceNodeIDType               NodeIDType = "service"
+	DeploymentNodeIDType            NodeIDType = "deployment"
+	ReplicaSetNodeIDType            NodeIDType = "replica_set"
+	DaemonSetNodeIDType             NodeIDType = "daemonset"
+	StatefulSetNodeIDType           NodeIDType = "statefulset"
+	CronJobNodeIDType               NodeIDType = "cronjob"
+	JobNodeIDType                   NodeIDType = "job"
+	NamespaceNodeIDType             NodeIDType = "namespace"
+	ECSTaskNodeIDType               NodeIDType = "ecs_task"
+	SwarmServiceNodeIDType          NodeIDType = "swarm_service"
+	PersistentVolumeNodeIDType      NodeIDType = "persistent_volume"
+	PersistentVolumeClaimNodeIDType NodeIDType = "persistent_volume_claim"
+	StorageClassNodeIDType          NodeIDType = "storage_class"
+	VolumeSnapshotNodeIDType        NodeIDType = "volume_snapshot"
+	VolumeSnapshotDataNodeIDType    NodeIDType = "volume_snapshot_data"
+)
+
+// singleComponentIDMakers maps the types of single-component node IDs to
+// their constructors.
+var singleComponentIDMakers = map[NodeIDType]func(string) string{
+	HostNodeIDType:                  MakeHostNodeID,
+	ContainerNodeIDType:             MakeContainerNodeID,
+	ContainerImageNodeIDType:        MakeContainerImageNodeID,
+	PodNodeIDType:                   MakePodNodeID,
+	ServiceNodeIDType:               MakeServiceNodeID,
+	DeploymentNodeIDType:            MakeDeploymentNodeID,
+	ReplicaSetNodeIDType:            MakeReplicaSetNodeID,
+	DaemonSetNodeIDType:             MakeDaemonSetNodeID,
+	StatefulSetNodeIDType:           MakeStatefulSetNodeID,
+	CronJobNodeIDType:               MakeCronJobNodeID,
+	JobNodeIDType:                   MakeJobNodeID,
+	NamespaceNodeIDType:             MakeNamespaceNodeID,
+	ECSTaskNodeIDType:               MakeECSTaskNodeID,
+	SwarmServiceNodeIDType:          MakeSwarmServiceNodeID,
+	PersistentVolumeNodeIDType:      MakePersistentVolumeNodeID,
+	PersistentVolumeClaimNodeIDType: MakePersistentVolumeClaimNodeID,
+	StorageClassNodeIDType:          MakeStorageClassNodeID,
+	VolumeSnapshotNodeIDType:        MakeVolumeSnapshotNodeID,
+	VolumeSnapshotDataNodeIDType:    MakeVolumeSnapshotDataNodeID,
+}
+
+// FromFields builds a node ID of the given type from its named fields, as
+// found in structured (e.g. JSON) representations of node IDs:
+//
+//   - endpoint: host, address, port and optionally namespace
+//   - address: host, address and optionally namespace
+//   - process: host, pid
+//   - ecs_service: cluster, service
+//   - overlay: peer and optionally prefix
+//   - single-component types: id
+//
+// The ID is the one the matching Make*NodeID function makes from the same
+// fields, except that delimiters in them are escaped, as in NodeID. Missing
+// required fields are an error.
+func FromFields(typ NodeIDType, fields map[string]string) (string, error) {
+	var required []string
+	switch typ {
+	case EndpointNodeIDType:
+		required = []string{"host", "address", "port"}
+	case AddressNodeIDType:
+		required = []string{"host", "address"}
+	case ProcessNodeIDType:
+		required = []string{"host", "pid"}
+	case ECSServiceNodeIDType:
+		required = []string{"cluster", "service"}
+	case OverlayNodeIDType:
+		required = []string{"peer"}
+	default:
+		if _, ok := singleComponentIDMakers[typ]; !ok {
+			return "", fmt.Errorf("unknown node ID type %q", typ)
+		}
+		required = []string{"id"}
+	}
+	for _, key := range required {
+		if _, ok := fields[key]; !ok {
+			return "", fmt.Errorf("missing field %q for %s node ID", key, typ)
+		}
+	}
+
+	if typ == OverlayNodeIDType {
+		return MakeOverlayNodeID(fields["prefix"], fields["peer"]), nil
+	}
+	id := NodeID{Kind: typ}
+	switch typ {
+	case EndpointNodeIDType:
+		id.Scope, id.Netns, id.Name, id.Port = fields["host"], fields["namespace"], fields["address"], fields["port"]
+	case AddressNodeIDType:
+		id.Scope, id.Netns, id.Name = fields["host"], fields["namespace"], fields["address"]
+	case ProcessNodeIDType:
+		id.Scope, id.Name = fields["host"], fields["pid"]
+	case ECSServiceNodeIDType:
+		id.Scope, id.Name = fields["cluster"], fields["service"]
+	default:
+		id.Name = fields["id"]
+	}
+	return id.String(), nil
+}
+
+// ecsServiceNodeID matches the IDs made by MakeECSServiceNodeID from the
+// cluster, as found in the com.amazonaws.ecs.cluster label of task
+// containers, and the service name. ECS cluster and service names are up to
+// 255 letters, digits, hyphens and underscores; the label may hold the ARN
+// of the cluster instead of its name.
+var ecsServiceNodeID = regexp.MustCompile(`^(arn:aws[a-z-]*:ecs:[a-z0-9-]+:[0-9]{12}:cluster/)?[A-Za-z0-9_-]{1,255};[A-Za-z0-9_-]{1,255}$`)
+
+// ClassifyNodeID determines the scheme a node ID was built with, returning
+// false if it isn't recognised, e.g. because it comes from a newer probe.
+//
+// ECS service node IDs have no distinguishing marker, so two-field IDs are
+// only taken to be ECS service node IDs if they are made of an ECS cluster
+// name or ARN and a service name, as allowed by ECS (see ecsServiceNodeID).
+func ClassifyNodeID(id string) (NodeIDType, bool) {
+	if strings.HasPrefix(id, "#") {
+		return OverlayNodeIDType, true
+	}
+	fields := strings.Split(id, ScopeDelim)
+	last := fields[len(fields)-1]
+	switch len(fields) {
+	case 2:
+		if isTag(last) {
+			typ := NodeIDType(last[1 : len(last)-1])
+			if typ == ECSServiceNodeIDType {
+				// Backwards-compatible form, see ParseECSServiceNodeID
+				return ECSServiceNodeIDType, true
+			}
+			if _, ok := singleComponentIDMakers[typ]; ok {
+				return typ, true
+			}
+			return "", false
+		}
+		if AddressIDAddresser(id) != nil {
+			return AddressNodeIDType, true
+		}
+		if isDigits(last) {
+			return ProcessNodeIDType, true
+		}
+		if ecsServiceNodeID.MatchString(id) {
+			return ECSServiceNodeIDType, true
+		}
+	case 3:
+		if last == "<cgroup>" {
+			return CgroupNodeIDType, true
+		}
+		if last == "<socket>" {
+			return SocketNodeIDType, true
+		}
+		if EndpointIDAddresser(id) != nil {
+			return EndpointNodeIDType, true
+		}
+		if isDigits(fields[1]) {
+			// See MakeProcessNodeIDWithStart
+			return ProcessNodeIDType, true
+		}
+	case 6:
+		if last == "<flow>" {
+			return FlowNodeIDType, true
+		}
+	}
+	return "", false
+}
+
+// IsRecognizedNodeID determines whether ClassifyNodeID recognises a node ID,
+// so that IDs of unknown types, e.g. from newer probes during a rolling
+// upgrade, can be skipped rather than mishandled.
+func IsRecognizedNodeID(id string) bool {
+	_, ok := ClassifyNodeID(id)
+	return ok
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeIDTopologies maps node ID types to the topologies their nodes live in.
+var nodeIDTopologies = map[NodeIDType]string{
+	EndpointNodeIDType:              Endpoint,
+	ProcessNodeIDType:               Process,
+	ECSServiceNodeIDType:            ECSService,
+	OverlayNodeIDType:               Overlay,
+	HostNodeIDType:                  Host,
+	ContainerNodeIDType:             Container,
+	ContainerImageNodeIDType:        ContainerImage,
+	PodNodeIDType:                   Pod,
+	ServiceNodeIDType:               Service,
+	DeploymentNodeIDType:            Deployment,
+	ReplicaSetNodeIDType:            ReplicaSet,
+	DaemonSetNodeIDType:             DaemonSet,
+	StatefulSetNodeIDType:           StatefulSet,
+	CronJobNodeIDType:               CronJob,
+	JobNodeIDType:                   Job,
+	NamespaceNodeIDType:             Namespace,
+	ECSTaskNodeIDType:               ECSTask,
+	SwarmServiceNodeIDType:          SwarmService,
+	PersistentVolumeNodeIDType:      PersistentVolume,
+	PersistentVolumeClaimNodeIDType: PersistentVolumeClaim,
+	StorageClassNodeIDType:          StorageClass,
+	VolumeSnapshotNodeIDType:        VolumeSnapshot,
+	VolumeSnapshotDataNodeIDType:    VolumeSnapshotData,
+}
+
+// TopologyForNodeID returns the name of the report topology a node ID
+// belongs in, or false if it isn't recognised or belongs in none.
+func TopologyForNodeID(id string) (string, bool) {
+	typ, ok := ClassifyNodeID(id)
+	if !ok {
+		return "", false
+	}
+	topology, ok := nodeIDTopologies[typ]
+	return topology, ok
+}
+
+// UnknownTopology is the key BucketByTopology files unrecognised IDs under.
+const UnknownTopology = "unknown"
+
+// BucketByTopology groups node IDs by the topology they belong in, as
+// determined by TopologyForNodeID. IDs which belong in no topology are
+// grouped under UnknownTopology.
+func BucketByTopology(ids []string) map[string][]string {
+	buckets := map[string][]string{}
+	for _, id := range ids {
+		topology, ok := TopologyForNodeID(id)
+		if !ok {
+			topology = UnknownTopology
+		}
+		buckets[topology] = append(buckets[topology], id)
+	}
+	return buckets
+}
+
+// CompareNodeIDs orders node IDs by host, then type, then the ID itself,
+// returning -1, 0 or 1 like strings.Compare. IDs without a host sort first,
+// and unrecognised IDs sort first amongst those with the same host.
+func CompareNodeIDs(a, b string) int {
+	hostA, typA := nodeIDSortFields(a)
+	hostB, typB := nodeIDSortFields(b)
+	if c := strings.Compare(hostA, hostB); c != 0 {
+		return c
+	}
+	if c := strings.Compare(string(typA), string(typB)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// stableNodeIDTypes are the node ID types whose IDs survive restarts of the
+// things they identify. Process, socket and flow node IDs are built from
+// PIDs, inodes and ports which don't.
+var stableNodeIDTypes = map[NodeIDType]bool{
+	HostNodeIDType:                  true,
+	ContainerNodeIDType:             true,
+	ContainerImageNodeIDType:        true,
+	PodNodeIDType:                   true,
+	ServiceNodeIDType:               true,
+	DeploymentNodeIDType:            true,
+	ReplicaSetNodeIDType:            true,
+	DaemonSetNodeIDType:             true,
+	StatefulSetNodeIDType:           true,
+	CronJobNodeIDType:               true,
+	JobNodeIDType:                   true,
+	NamespaceNodeIDType:             true,
+	ECSServiceNodeIDType:            true,
+	SwarmServiceNodeIDType:          true,
+	PersistentVolumeNodeIDType:      true,
+	PersistentVolumeClaimNodeIDType: true,
+	StorageClassNodeIDType:          true,
+}
+
+// IsStableIdentity determines whether a node ID identifies the same thing
+// across restarts, and so is worth persisting. Address and endpoint node IDs
+// are stable unless their address is loopback, and endpoint node IDs also
+// need a port outside the default ephemeral range.
+func IsStableIdentity(id string) bool {
+	typ, ok := ClassifyNodeID(id)
+	if !ok {
+		return false
+	}
+	switch typ {
+	case AddressNodeIDType:
+		ip := AddressIDAddresser(id)
+		return ip != nil && !ip.IsLoopback()
+	case EndpointNodeIDType:
+		_, _, port, _, _ := ParseL7EndpointNodeID(id)
+		ip := EndpointIDAddresser(id)
+		return ip != nil && !ip.IsLoopback() && isDigits(port) && !isEphemeralPort(port)
+	}
+	return stableNodeIDTypes[typ]
+}
+
+// NodeIDSetDiff compares two sets of node IDs, returning those only in new
+// (added) and those only in old (removed), each sorted with CompareNodeIDs,
+// for sending incremental updates.
+func NodeIDSetDiff(old, new []string) (added, removed []string) {
+	oldSet := make(map[string]struct{}, len(old))
+	for _, id := range old {
+		oldSet[id] = struct{}{}
+	}
+	newSet := make(map[string]struct{}, len(new))
+	for _, id := range new {
+		newSet[id] = struct{}{}
+	}
+	for id := range newSet {
+		if _, ok := oldSet[id]; !ok {
+			added = append(added, id)
+		}
+	}
+	for id := range oldSet {
+		if _, ok := newSet[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	sortNodeIDs(added)
+	sortNodeIDs(removed)
+	return added, removed
+}
+
+// SortedNodeIDs returns a copy of ids sorted with CompareNodeIDs, so that
+// output listing node IDs is reproducible for the same data.
+func SortedNodeIDs(ids []string) []string {
+	sorted := append([]string(nil), ids...)
+	sortNodeIDs(sorted)
+	return sorted
+}
+
+// RenderNodeIDDiff describes the difference between two sets of node IDs
+// for human review, e.g. in logs: under a line naming each topology affected,
+// in alphabetical order, come "+id" lines for added IDs and then "-id" lines
+// for removed IDs, each sorted with CompareNodeIDs. It is empty if the sets
+// are the same.
+func RenderNodeIDDiff(old, new []string) string {
+	added, removed := NodeIDSetDiff(old, new)
+	addedByTopology, removedByTopology := BucketByTopology(added), BucketByTopology(removed)
+	topologies := []string{}
+	for topology := range addedByTopology {
+		topologies = append(topologies, topology)
+	}
+	for topology := range removedByTopology {
+		if _, ok := addedByTopology[topology]; !ok {
+			topologies = append(topologies, topology)
+		}
+	}
+	sort.Strings(topologies)
+
+	var b strings.Builder
+	for _, topology := range topologies {
+		fmt.Fprintf(&b, "%s:\n", topology)
+		for _, id := range addedByTopology[topology] {
+			fmt.Fprintf(&b, "+%s\n", id)
+		}
+		for _, id := range removedByTopology[topology] {
+			fmt.Fprintf(&b, "-%s\n", id)
+		}
+	}
+	return b.String()
+}
+
+// sortNodeIDs sorts ids as CompareNodeIDs orders them. Classifying an ID is
+// too costly to repeat on every comparison, so each ID's sort fields are
+// worked out once up front.
+func sortNodeIDs(ids []string) {
+	keys := make([]nodeIDSortKey, len(ids))
+	for i, id := range ids {
+		host, typ := nodeIDSortFields(id)
+		keys[i] = nodeIDSortKey{host: host, typ: typ, id: id}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	for i, key := range keys {
+		ids[i] = key.id
+	}
+}
+
+type nodeIDSortKey struct {
+	host string
+	typ  NodeIDType
+	id   string
+}
+
+func (k nodeIDSortKey) less(o nodeIDSortKey) bool {
+	if k.host != o.host {
+		return k.host < o.host
+	}
+	if k.typ != o.typ {
+		return k.typ < o.typ
+	}
+	return k.id < o.id
+}
+
+// SortKey produces a byte key for a node ID such that bytes.Compare orders
+// keys as CompareNodeIDs orders the IDs. It is intended for ordering node
+// IDs in a key-value store, not for display.
+func SortKey(id string) []byte {
+	host, typ := nodeIDSortFields(id)
+	key := make([]byte, 0, len(host)+len(typ)+len(id)+2)
+	key = append(key, host...)
+	key = append(key, 0)
+	key = append(key, typ...)
+	key = append(key, 0)
+	return append(key, id...)
+}
+
+func nodeIDSortFields(id string) (string, NodeIDType) {
+	typ, _ := ClassifyNodeID(id)
+	if scope, kind, ok := nodeIDScope(id); ok && kind == "host" {
+		return scope, typ
+	}
+	return "", typ
+}
+
+// infrastructureNodeIDTypes are the node ID types whose nodes are part of the
+// infrastructure workloads run on, rather than workloads themselves.
+var infrastructureNodeIDTypes = map[NodeIDType]bool{
+	HostNodeIDType:         true,
+	OverlayNodeIDType:      true,
+	StorageClassNodeIDType: true,
+}
+
+// infrastructurePseudoNodes are the kinds of pseudo node (see
+// render.MakePseudoNodeID) which stand for parts of the hosts themselves.
+var infrastructurePseudoNodes = map[string]bool{
+	"uncontained": true, // render.UncontainedID
+	"unmanaged":   true, // render.UnmanagedID
+}
+
+// IsInfrastructureNode determines whether a node ID represents
+// infrastructure, such as a host or the uncontained processes on one, as
+// opposed to a workload such as a container, pod or process.
+func IsInfrastructureNode(id string) bool {
+	if strings.HasPrefix(id, "pseudo:") {
+		kind := strings.SplitN(id[len("pseudo:"):], ":", 2)[0]
+		return infrastructurePseudoNodes[kind]
+	}
+	typ, ok := ClassifyNodeID(id)
+	return ok && infrastructureNodeIDTypes[typ]
+}
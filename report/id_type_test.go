@@ -0,0 +1,275 @@
+package report_test
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestFromFields(t *testing.T) {
+	for _, tc := range []struct {
+		typ    report.NodeIDType
+		fields map[string]string
+		want   string
+	}{
+		{report.EndpointNodeIDType, map[string]string{"host": serverHostID, "address": serverAddress, "port": "80"}, server80EndpointNodeID},
+		{report.EndpointNodeIDType, map[string]string{"host": "host.com", "namespace": "namespaceid", "address": "127.0.0.1", "port": "80"}, report.MakeEndpointNodeID("host.com", "namespaceid", "127.0.0.1", "80")},
+		{report.EndpointNodeIDType, map[string]string{"host": "host.com", "address": "fe80::1%eth0", "port": "80"}, report.MakeEndpointNodeID("host.com", "", "fe80::1%eth0", "80")},
+		{report.AddressNodeIDType, map[string]string{"host": clientHostID, "address": clientAddress}, clientAddressNodeID},
+		{report.AddressNodeIDType, map[string]string{"host": "host.com", "namespace": "4026531993", "address": "127.0.0.1"}, report.MakeNetnsAddressNodeID("host.com", "4026531993", "127.0.0.1")},
+		{report.ProcessNodeIDType, map[string]string{"host": clientHostID, "pid": "42"}, report.MakeProcessNodeID(clientHostID, "42")},
+		{report.ContainerNodeIDType, map[string]string{"id": "abc123"}, report.MakeContainerNodeID("abc123")},
+		{report.HostNodeIDType, map[string]string{"id": clientHostID}, clientHostNodeID},
+	} {
+		have, err := report.FromFields(tc.typ, tc.fields)
+		if err != nil {
+			t.Errorf("%s %v: unexpected error %v", tc.typ, tc.fields, err)
+		} else if have != tc.want {
+			t.Errorf("%s %v: want %q, have %q", tc.typ, tc.fields, tc.want, have)
+		}
+	}
+
+	if _, err := report.FromFields(report.EndpointNodeIDType, map[string]string{"host": serverHostID, "address": serverAddress}); err == nil {
+		t.Errorf("expected error for missing port")
+	}
+	if _, err := report.FromFields(report.ContainerNodeIDType, map[string]string{}); err == nil {
+		t.Errorf("expected error for missing id")
+	}
+	if _, err := report.FromFields(report.NodeIDType("future"), map[string]string{"id": "x"}); err == nil {
+		t.Errorf("expected error for unknown type")
+	}
+}
+
+func TestClassifyNodeID(t *testing.T) {
+	for id, want := range map[string]report.NodeIDType{
+		server80EndpointNodeID: report.EndpointNodeIDType,
+		report.MakeEndpointNodeID(clientHostID, "", "::1", "80"):           report.EndpointNodeIDType,
+		report.MakeContainerScopedEndpointNodeID("abc", "127.0.0.1", "80"): report.EndpointNodeIDType,
+		clientAddressNodeID: report.AddressNodeIDType,
+		report.MakeAddressNodeIDWithName("", "8.8.8.8", "dns.google"):        report.AddressNodeIDType,
+		report.MakeProcessNodeID(clientHostID, "42"):                         report.ProcessNodeIDType,
+		report.MakeECSServiceNodeID("cluster", "service"):                    report.ECSServiceNodeIDType,
+		"my-service;<ecs_service>":                                           report.ECSServiceNodeIDType,
+		report.MakeOverlayNodeID(report.DockerOverlayPeerPrefix, "peer"):     report.OverlayNodeIDType,
+		report.MakeCgroupNodeID(clientHostID, "/system.slice"):               report.CgroupNodeIDType,
+		report.MakeSocketNodeID(clientHostID, "123456"):                      report.SocketNodeIDType,
+		report.MakeFlowNodeID("tcp", clientAddress, "1", serverAddress, "2"): report.FlowNodeIDType,
+		clientHostNodeID:                           report.HostNodeIDType,
+		report.MakeContainerNodeID("abc"):          report.ContainerNodeIDType,
+		report.MakeDaemonSetNodeID("uid"):          report.DaemonSetNodeIDType,
+		report.MakeVolumeSnapshotDataNodeID("uid"): report.VolumeSnapshotDataNodeIDType,
+
+		// The ECS cluster label may hold the cluster ARN
+		report.MakeECSServiceNodeID("arn:aws:ecs:eu-west-1:123456789012:cluster/prod", "web_v2"): report.ECSServiceNodeIDType,
+	} {
+		if have, ok := report.ClassifyNodeID(id); !ok || have != want {
+			t.Errorf("ClassifyNodeID(%q) = (%q, %v), want %q", id, have, ok, want)
+		}
+		if !report.IsRecognizedNodeID(id) {
+			t.Errorf("%q: expected recognised node ID", id)
+		}
+	}
+
+	for _, id := range []string{
+		"abc;<future_type>",
+		"a;b;c;d;<future>",
+		"host;notanaddress;80",
+		"client.host.com;something", // scoped, but neither an address nor a PID
+		"cluster;not a service",
+		"arn:aws:s3:::bucket;service",
+		";service",
+		"cluster;",
+		"nodelimiter",
+		"",
+	} {
+		if typ, ok := report.ClassifyNodeID(id); ok {
+			t.Errorf("ClassifyNodeID(%q) = %q, expected failure", id, typ)
+		}
+		if report.IsRecognizedNodeID(id) {
+			t.Errorf("%q: unexpected recognised node ID", id)
+		}
+	}
+}
+
+func TestBucketByTopology(t *testing.T) {
+	ids := map[string]string{
+		report.Endpoint:              server80EndpointNodeID,
+		report.Process:               report.MakeProcessNodeID(clientHostID, "42"),
+		report.ECSService:            report.MakeECSServiceNodeID("cluster", "service"),
+		report.Overlay:               report.MakeOverlayNodeID(report.WeaveOverlayPeerPrefix, "peer"),
+		report.Host:                  clientHostNodeID,
+		report.Container:             report.MakeContainerNodeID("abc"),
+		report.ContainerImage:        report.MakeContainerImageNodeID("nginx"),
+		report.Pod:                   report.MakePodNodeID("uid"),
+		report.Service:               report.MakeServiceNodeID("uid"),
+		report.Deployment:            report.MakeDeploymentNodeID("uid"),
+		report.ReplicaSet:            report.MakeReplicaSetNodeID("uid"),
+		report.DaemonSet:             report.MakeDaemonSetNodeID("uid"),
+		report.StatefulSet:           report.MakeStatefulSetNodeID("uid"),
+		report.CronJob:               report.MakeCronJobNodeID("uid"),
+		report.Job:                   report.MakeJobNodeID("uid"),
+		report.Namespace:             report.MakeNamespaceNodeID("default"),
+		report.ECSTask:               report.MakeECSTaskNodeID("arn"),
+		report.SwarmService:          report.MakeSwarmServiceNodeID("id"),
+		report.PersistentVolume:      report.MakePersistentVolumeNodeID("uid"),
+		report.PersistentVolumeClaim: report.MakePersistentVolumeClaimNodeID("uid"),
+		report.StorageClass:          report.MakeStorageClassNodeID("uid"),
+		report.VolumeSnapshot:        report.MakeVolumeSnapshotNodeID("uid"),
+		report.VolumeSnapshotData:    report.MakeVolumeSnapshotDataNodeID("uid"),
+	}
+	input := []string{"abc;<future_type>", clientAddressNodeID}
+	want := map[string][]string{report.UnknownTopology: {"abc;<future_type>", clientAddressNodeID}}
+	for topology, id := range ids {
+		input = append(input, id)
+		want[topology] = []string{id}
+	}
+
+	if have := report.BucketByTopology(input); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestIsStableIdentity(t *testing.T) {
+	for id, want := range map[string]bool{
+		report.MakeContainerNodeID("abc"):                              true,
+		report.MakeContainerImageNodeID("nginx"):                       true,
+		report.MakePodNodeID("uid"):                                    true,
+		report.MakeHostNodeID("i-0123456789abcdef0"):                   true,
+		serverAddressNodeID:                                            true,
+		server80EndpointNodeID:                                         true,
+		report.MakeAddressNodeID(clientHostID, "127.0.0.1"):            false,
+		report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"): false,
+		client54001EndpointNodeID:                                      false,
+		report.MakeProcessNodeID(clientHostID, "42"):                   false,
+		"abc;<future_type>":                                            false,
+	} {
+		if have := report.IsStableIdentity(id); have != want {
+			t.Errorf("%q: want %v, have %v", id, want, have)
+		}
+	}
+}
+
+func TestNodeIDSetDiff(t *testing.T) {
+	old := []string{clientHostNodeID, server80EndpointNodeID, report.MakeProcessNodeID(serverHostID, "1"), clientHostNodeID}
+	new := []string{serverHostNodeID, report.MakeProcessNodeID(serverHostID, "2"), clientHostNodeID, report.MakeContainerNodeID("abc")}
+	added, removed := report.NodeIDSetDiff(old, new)
+	if want := []string{report.MakeContainerNodeID("abc"), serverHostNodeID, report.MakeProcessNodeID(serverHostID, "2")}; !reflect.DeepEqual(want, added) {
+		t.Errorf("added: want %v, have %v", want, added)
+	}
+	if want := []string{server80EndpointNodeID, report.MakeProcessNodeID(serverHostID, "1")}; !reflect.DeepEqual(want, removed) {
+		t.Errorf("removed: want %v, have %v", want, removed)
+	}
+
+	if added, removed := report.NodeIDSetDiff(old, old); len(added) != 0 || len(removed) != 0 {
+		t.Errorf("identical sets: added %v, removed %v", added, removed)
+	}
+}
+
+func TestSortedNodeIDs(t *testing.T) {
+	a := []string{serverHostNodeID, client54001EndpointNodeID, report.MakeContainerNodeID("abc"), clientHostNodeID, server80EndpointNodeID}
+	b := []string{client54001EndpointNodeID, clientHostNodeID, server80EndpointNodeID, serverHostNodeID, report.MakeContainerNodeID("abc")}
+	sortedA, sortedB := report.SortedNodeIDs(a), report.SortedNodeIDs(b)
+	if !reflect.DeepEqual(sortedA, sortedB) {
+		t.Errorf("shuffled inputs sorted differently: %v vs %v", sortedA, sortedB)
+	}
+	for i := 1; i < len(sortedA); i++ {
+		if report.CompareNodeIDs(sortedA[i-1], sortedA[i]) > 0 {
+			t.Errorf("%q sorted before %q", sortedA[i-1], sortedA[i])
+		}
+	}
+	if a[0] != serverHostNodeID {
+		t.Errorf("input modified: %v", a)
+	}
+}
+
+func TestRenderNodeIDDiff(t *testing.T) {
+	old := []string{clientHostNodeID, server80EndpointNodeID, report.MakeProcessNodeID(serverHostID, "1")}
+	new := []string{clientHostNodeID, serverHostNodeID, client54001EndpointNodeID, report.MakeProcessNodeID(serverHostID, "2")}
+	want := strings.Join([]string{
+		"endpoint:",
+		"+" + client54001EndpointNodeID,
+		"-" + server80EndpointNodeID,
+		"host:",
+		"+" + serverHostNodeID,
+		"process:",
+		"+" + report.MakeProcessNodeID(serverHostID, "2"),
+		"-" + report.MakeProcessNodeID(serverHostID, "1"),
+	}, "\n") + "\n"
+	if have := report.RenderNodeIDDiff(old, new); have != want {
+		t.Errorf("want:\n%s\nhave:\n%s", want, have)
+	}
+	if have := report.RenderNodeIDDiff(old, old); have != "" {
+		t.Errorf("identical sets: have %q", have)
+	}
+}
+
+func TestSortKey(t *testing.T) {
+	ids := []string{
+		server80EndpointNodeID,
+		client54001EndpointNodeID,
+		report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"),
+		report.MakeEndpointNodeID(serverHostID, "", "127.0.0.1", "80"),
+		report.MakeProcessNodeID(clientHostID, "42"),
+		report.MakeProcessNodeID(serverHostID, "1"),
+		report.MakeProcessNodeID("a", "1"),
+		report.MakeProcessNodeID("ab", "1"),
+		clientHostNodeID,
+		serverHostNodeID,
+		report.MakeContainerNodeID("abc"),
+		report.MakePodNodeID("uid"),
+		"abc;<future_type>",
+	}
+	for _, a := range ids {
+		for _, b := range ids {
+			want := report.CompareNodeIDs(a, b)
+			if have := bytes.Compare(report.SortKey(a), report.SortKey(b)); have != want {
+				t.Errorf("%q vs %q: SortKey gives %d, CompareNodeIDs gives %d", a, b, have, want)
+			}
+		}
+	}
+
+	sorted := append([]string{}, ids...)
+	sort.Slice(sorted, func(i, j int) bool { return report.CompareNodeIDs(sorted[i], sorted[j]) < 0 })
+	want := []string{
+		"abc;<future_type>",
+		report.MakeContainerNodeID("abc"),
+		server80EndpointNodeID,
+		client54001EndpointNodeID,
+		report.MakePodNodeID("uid"),
+		report.MakeProcessNodeID("a", "1"),
+		report.MakeProcessNodeID("ab", "1"),
+		report.MakeEndpointNodeID(clientHostID, "", "127.0.0.1", "80"),
+		clientHostNodeID,
+		report.MakeProcessNodeID(clientHostID, "42"),
+		report.MakeEndpointNodeID(serverHostID, "", "127.0.0.1", "80"),
+		serverHostNodeID,
+		report.MakeProcessNodeID(serverHostID, "1"),
+	}
+	if !reflect.DeepEqual(want, sorted) {
+		t.Errorf("want %v, have %v", want, sorted)
+	}
+}
+
+func TestIsInfrastructureNode(t *testing.T) {
+	for id, want := range map[string]bool{
+		clientHostNodeID:                             true,
+		report.MakeOverlayNodeID("", "peer"):         true,
+		report.MakeStorageClassNodeID("standard"):    true,
+		"pseudo:uncontained:" + clientHostID:         true,
+		"pseudo:unmanaged:peer":                      true,
+		"pseudo:in-theinternet":                      false,
+		report.MakeContainerNodeID("abc"):            false,
+		report.MakePodNodeID("uid"):                  false,
+		report.MakeProcessNodeID(clientHostID, "42"): false,
+		client54001EndpointNodeID:                    false,
+		"abc;<future_type>":                          false,
+	} {
+		if have := report.IsInfrastructureNode(id); have != want {
+			t.Errorf("%q: want %v, have %v", id, want, have)
+		}
+	}
+}
@@ -131,12 +131,14 @@ func mapWrite(m ps.Map, encoder *codec.Encoder, encodeValue func(*codec.Encoder,
 		return
 	}
 	r.EncodeMapStart(m.Size())
-	m.ForEach(func(key string, val interface{}) {
+	// Write in key order, as ForEach's order depends on how the map was built
+	for _, key := range mapKeys(m) {
+		val, _ := m.Lookup(key)
 		z.EncSendContainerState(containerMapKey)
 		r.EncodeString(cUTF8, key)
 		z.EncSendContainerState(containerMapValue)
 		encodeValue(encoder, val)
-	})
+	}
 	z.EncSendContainerState(containerMapEnd)
 }
 
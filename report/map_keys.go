@@ -10,6 +10,12 @@ const (
 	SnoopedDNSNames = "snooped_dns_names"
 	CopyOf          = "copy_of"
 	ConnectionCount = "conn_count"
+	// probe/endpoint HTTP snooper
+	HTTPRequestRate      = "http_request_rate"
+	HTTPLatencyP50       = "http_latency_p50"
+	HTTPLatencyP99       = "http_latency_p99"
+	HTTPStatusCodes      = "http_status_codes"
+	HTTPLatencyHistogram = "http_latency_histogram"
 
 	// probe/process
 	PID     = "pid"
@@ -36,6 +42,7 @@ const (
 	DockerRemoveContainer        = "docker_remove_container"
 	DockerAttachContainer        = "docker_attach_container"
 	DockerExecContainer          = "docker_exec_container"
+	DockerLogsContainer          = "docker_logs_container"
 	DockerContainerName          = "docker_container_name"
 	DockerContainerCommand       = "docker_container_command"
 	DockerContainerPorts         = "docker_container_ports"
@@ -97,6 +104,15 @@ const (
 	KubernetesDescribe             = "kubernetes_describe"
 	KubernetesCordonNode           = "kubernetes_cordon_node"
 	KubernetesUncordonNode         = "kubernetes_uncordon_node"
+	KubernetesDrainNode            = "kubernetes_drain_node"
+	KubernetesNetworkPolicies      = "kubernetes_network_policies"
+	KubernetesNetworkPolicyRules   = "kubernetes_network_policy_rules"
+	KubernetesPolicyDenied         = "kubernetes_policy_denied"
+	KubernetesPolicyUnprotected    = "kubernetes_policy_unprotected"
+	KubernetesServiceMesh          = "kubernetes_service_mesh"
+	KubernetesMeshRequestRate      = "kubernetes_mesh_request_rate"
+	KubernetesMeshErrorRate        = "kubernetes_mesh_error_rate"
+	KubernetesMeshLatencyP99       = "kubernetes_mesh_latency_p99"
 	// probe/awsecs
 	ECSCluster             = "ecs_cluster"
 	ECSCreatedAt           = "ecs_created_at"
@@ -180,6 +196,7 @@ var commonKeys = map[string]string{
 	DockerRemoveContainer:        DockerRemoveContainer,
 	DockerAttachContainer:        DockerAttachContainer,
 	DockerExecContainer:          DockerExecContainer,
+	DockerLogsContainer:          DockerLogsContainer,
 	DockerContainerName:          DockerContainerName,
 	DockerContainerCommand:       DockerContainerCommand,
 	DockerContainerPorts:         DockerContainerPorts,
@@ -4,6 +4,7 @@ package report
 const (
 	DockerLabelPrefix      = "docker_label_"
 	DockerImageLabelPrefix = "docker_image_label_"
+	KubernetesLabelPrefix  = "kubernetes_labels_"
 
 	StateCreated    = "created"
 	StateDead       = "dead"
@@ -45,7 +45,11 @@ func (rep Report) WriteBinary() (*bytes.Buffer, error) {
 	gzwriter := gzipWriterPool.Get().(*gzip.Writer)
 	gzwriter.Reset(w)
 	defer gzipWriterPool.Put(gzwriter)
-	if err := codec.NewEncoder(gzwriter, &codec.MsgpackHandle{}).Encode(&rep); err != nil {
+	// Canonical sorts map keys, so the same report always encodes to the
+	// same bytes
+	handle := &codec.MsgpackHandle{}
+	handle.Canonical = true
+	if err := codec.NewEncoder(gzwriter, handle).Encode(&rep); err != nil {
 		return nil, err
 	}
 	gzwriter.Close() // otherwise the content won't get flushed to the output stream
@@ -74,6 +74,33 @@ func TestBiggerRoundtrip(t *testing.T) {
 	}
 }
 
+func TestWriteBinaryEncodesReproducibly(t *testing.T) {
+	nodes := []report.Node{
+		report.MakeNode("ip-172-20-1-168;10446").WithLatests(map[string]string{"pid": "10446"}),
+		report.MakeNode("ip-172-20-1-168;8901").WithSet("names", report.MakeStringSet("a", "b")),
+		report.MakeNode("fceef9592ec3cf1a8e1d178fdd0de41a;<pod>"),
+		report.MakeNode(";172.20.1.168;41582").WithAdjacent(";10.0.0.1;80"),
+		report.MakeNode("ip-172-20-1-168;<host>"),
+	}
+	r1, r2 := report.MakeReport(), report.MakeReport()
+	r1.ID, r2.ID = "1", "1"
+	for i := range nodes {
+		r1.Process.AddNode(nodes[i])
+		r2.Process.AddNode(nodes[len(nodes)-1-i])
+	}
+	buf1, err := r1.WriteBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf2, err := r2.WriteBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Error("reports with the same nodes encoded differently")
+	}
+}
+
 func TestControlsCompat(t *testing.T) {
 	testData := `{
   "Container": {
@@ -0,0 +1,37 @@
+package report
+
+// NATCorrelator pairs endpoint node IDs which are NAT translations of each
+// other, so that the two sides of a translated connection can be merged
+// into one node.
+//
+// Each side of a pair is keyed by its full node ID, ports included, so
+// concurrent connections from one address through the same NAT stay
+// distinct. It is not safe for concurrent use.
+type NATCorrelator struct {
+	originals map[string]string
+}
+
+// MakeNATCorrelator makes an empty NATCorrelator.
+func MakeNATCorrelator() NATCorrelator {
+	return NATCorrelator{originals: map[string]string{}}
+}
+
+// Add registers translated as a NAT translation of the endpoint original.
+// Node IDs which are not endpoints are ignored.
+func (c NATCorrelator) Add(original, translated string) {
+	if _, _, _, ok := ParseEndpointNodeID(original); !ok {
+		return
+	}
+	if _, _, _, ok := ParseEndpointNodeID(translated); !ok {
+		return
+	}
+	c.originals[original] = original
+	c.originals[translated] = original
+}
+
+// Resolve returns the original endpoint node ID for either side of a
+// registered NAT pair, or false if id is not part of one.
+func (c NATCorrelator) Resolve(id string) (string, bool) {
+	original, ok := c.originals[id]
+	return original, ok
+}
@@ -0,0 +1,42 @@
+package report_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestNATCorrelator(t *testing.T) {
+	var (
+		original   = report.MakeEndpointNodeID(clientHostID, "", "172.17.0.2", "80")
+		translated = report.MakeEndpointNodeID(clientHostID, "", clientAddress, "8080")
+		snatted    = report.MakeEndpointNodeID(clientHostID, "", clientAddress, "40000")
+		snatted2   = report.MakeEndpointNodeID(clientHostID, "", clientAddress, "40001")
+		unrelated  = report.MakeEndpointNodeID(clientHostID, "", clientAddress, "50000")
+	)
+	c := report.MakeNATCorrelator()
+	c.Add(original, translated)
+	// Two concurrent connections from one address through the same NAT
+	c.Add(client54001EndpointNodeID, snatted)
+	c.Add(client54002EndpointNodeID, snatted2)
+	c.Add(clientHostNodeID, translated)
+
+	for id, want := range map[string]string{
+		translated:                original,
+		original:                  original,
+		snatted:                   client54001EndpointNodeID,
+		snatted2:                  client54002EndpointNodeID,
+		client54001EndpointNodeID: client54001EndpointNodeID,
+		client54002EndpointNodeID: client54002EndpointNodeID,
+	} {
+		if have, ok := c.Resolve(id); !ok || have != want {
+			t.Errorf("%q: want %q, have %q (%v)", id, want, have, ok)
+		}
+	}
+
+	for _, id := range []string{server80EndpointNodeID, clientHostNodeID, unrelated} {
+		if have, ok := c.Resolve(id); ok {
+			t.Errorf("%q: unexpectedly resolved to %q", id, have)
+		}
+	}
+}
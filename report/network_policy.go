@@ -0,0 +1,184 @@
+package report
+
+import (
+	"net"
+)
+
+// NetworkPolicy is the part of a Kubernetes NetworkPolicy needed to work out
+// which connections between pods it allows. The Kubernetes probe reports
+// the policies of each namespace, as JSON, on the namespace's node under
+// KubernetesNetworkPolicyRules.
+type NetworkPolicy struct {
+	Namespace   string        `json:"namespace"`
+	Name        string        `json:"name"`
+	PodSelector LabelSelector `json:"podSelector"`
+	// PolicyIngress and PolicyEgress say whether the policy restricts the
+	// ingress and egress of the pods it selects, as its policyTypes do.
+	PolicyIngress bool                `json:"policyIngress,omitempty"`
+	PolicyEgress  bool                `json:"policyEgress,omitempty"`
+	Ingress       []NetworkPolicyRule `json:"ingress,omitempty"`
+	Egress        []NetworkPolicyRule `json:"egress,omitempty"`
+}
+
+// NetworkPolicyRule is an ingress or egress rule of a NetworkPolicy. A rule
+// without peers allows every peer. Ports aren't kept, as connections between
+// pods are rendered without them.
+type NetworkPolicyRule struct {
+	Peers []NetworkPolicyPeer `json:"peers,omitempty"`
+}
+
+// NetworkPolicyPeer is a peer of a NetworkPolicyRule.
+type NetworkPolicyPeer struct {
+	PodSelector       *LabelSelector `json:"podSelector,omitempty"`
+	NamespaceSelector *LabelSelector `json:"namespaceSelector,omitempty"`
+	IPBlock           *IPBlock       `json:"ipBlock,omitempty"`
+}
+
+// IPBlock is a CIDR, less some exceptions within it.
+type IPBlock struct {
+	CIDR   string   `json:"cidr"`
+	Except []string `json:"except,omitempty"`
+}
+
+// LabelSelector selects objects by their labels, as a Kubernetes
+// LabelSelector does. An empty selector selects everything.
+type LabelSelector struct {
+	MatchLabels      map[string]string          `json:"matchLabels,omitempty"`
+	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// LabelSelectorRequirement is a requirement of a LabelSelector. Operator is
+// one of In, NotIn, Exists and DoesNotExist.
+type LabelSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// NetworkPolicyPod is what NetworkPolicy rules look at in a pod.
+type NetworkPolicyPod struct {
+	Namespace       string
+	NamespaceLabels map[string]string
+	Labels          map[string]string
+	IP              net.IP
+}
+
+// Matches says whether the selector selects objects with these labels.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for k, v := range s.MatchLabels {
+		if have, ok := labels[k]; !ok || have != v {
+			return false
+		}
+	}
+	for _, r := range s.MatchExpressions {
+		value, ok := labels[r.Key]
+		switch r.Operator {
+		case "In":
+			if !ok || !contains(r.Values, value) {
+				return false
+			}
+		case "NotIn":
+			if ok && contains(r.Values, value) {
+				return false
+			}
+		case "Exists":
+			if !ok {
+				return false
+			}
+		case "DoesNotExist":
+			if ok {
+				return false
+			}
+		default:
+			// Kubernetes rejects other operators
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains says whether ip is in the block.
+func (b IPBlock) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	_, cidr, err := net.ParseCIDR(b.CIDR)
+	if err != nil || !cidr.Contains(ip) {
+		return false
+	}
+	for _, except := range b.Except {
+		if _, e, err := net.ParseCIDR(except); err == nil && e.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches says whether the peer, of a policy in namespace, is pod.
+func (p NetworkPolicyPeer) Matches(namespace string, pod NetworkPolicyPod) bool {
+	if p.IPBlock != nil {
+		return p.IPBlock.Contains(pod.IP)
+	}
+	if p.NamespaceSelector != nil {
+		if !p.NamespaceSelector.Matches(pod.NamespaceLabels) {
+			return false
+		}
+	} else if pod.Namespace != namespace {
+		return false
+	}
+	return p.PodSelector == nil || p.PodSelector.Matches(pod.Labels)
+}
+
+// Allows says whether the rule, of a policy in namespace, allows pod.
+func (r NetworkPolicyRule) Allows(namespace string, pod NetworkPolicyPod) bool {
+	if len(r.Peers) == 0 {
+		return true
+	}
+	for _, peer := range r.Peers {
+		if peer.Matches(namespace, pod) {
+			return true
+		}
+	}
+	return false
+}
+
+// NetworkPolicyVerdict works out whether policies allow connections from src
+// to dst: dst must be allowed by src's egress policies, and src by dst's
+// ingress policies. Directions no policy restricts allow everything.
+// covered says whether any policy restricts either direction.
+func NetworkPolicyVerdict(policies []NetworkPolicy, src, dst NetworkPolicyPod) (allowed, covered bool) {
+	egress, egressCovered := allowedBy(policies, src, dst, func(np NetworkPolicy) ([]NetworkPolicyRule, bool) {
+		return np.Egress, np.PolicyEgress
+	})
+	ingress, ingressCovered := allowedBy(policies, dst, src, func(np NetworkPolicy) ([]NetworkPolicyRule, bool) {
+		return np.Ingress, np.PolicyIngress
+	})
+	return egress && ingress, egressCovered || ingressCovered
+}
+
+// allowedBy says whether the policies selecting pod allow peer, in the
+// direction rules picks out.
+func allowedBy(policies []NetworkPolicy, pod, peer NetworkPolicyPod, rules func(NetworkPolicy) ([]NetworkPolicyRule, bool)) (allowed, covered bool) {
+	for _, np := range policies {
+		rs, restricts := rules(np)
+		if !restricts || np.Namespace != pod.Namespace || !np.PodSelector.Matches(pod.Labels) {
+			continue
+		}
+		covered = true
+		for _, r := range rs {
+			if r.Allows(np.Namespace, peer) {
+				return true, true
+			}
+		}
+	}
+	return !covered, covered
+}
@@ -0,0 +1,146 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NodeID is a structured node ID. Its String form is built by the
+// Make*NodeID functions, so it is byte for byte the ID they make from the
+// same fields and can be used alongside plain string node IDs and on the
+// wire. The difference is that ScopeDelim and EdgeDelim in its fields are
+// percent-encoded (see escapeIDComponent), rather than corrupting the ID.
+// Nothing else is escaped, so the zone of an IPv6 address such as
+// "fe80::1%eth0" is left as written.
+//
+// NodeID doesn't replace the Make*NodeID functions, which remain the
+// definition of each layout; it covers endpoint, address, process, ECS
+// service and single-component node IDs. Overlay, cgroup, flow and socket
+// node IDs, and process node IDs with start times, are not supported.
+type NodeID struct {
+	// Kind is the scheme the ID is built with
+	Kind NodeIDType
+	// Scope is the host ID, or for ECS services the cluster. As with
+	// MakeAddressNodeID and MakeEndpointNodeID, address and endpoint IDs
+	// are only scoped by it if their address is local, so it is blank when
+	// parsing those of public addresses.
+	Scope string
+	// Netns is the network namespace loopback addresses and endpoints are
+	// scoped by, as well as the host. It is only parsed from scopes ending
+	// in a network namespace ID, as with ParseNetnsAddressNodeID.
+	Netns string
+	// Name is the address, PID, ECS service name, or for single-component
+	// node IDs the ID
+	Name string
+	// Port is the port of endpoint node IDs
+	Port string
+}
+
+// String produces the string form of the node ID, or "" if its Kind is not
+// supported.
+func (id NodeID) String() string {
+	scope, name := escapeIDComponent(id.Scope), escapeIDComponent(id.Name)
+	switch id.Kind {
+	case EndpointNodeIDType:
+		port := escapeIDComponent(id.Port)
+		if strings.HasPrefix(id.Scope, containerScopePrefix) {
+			return MakeScopedEndpointNodeID(scope, name, port)
+		}
+		return MakeEndpointNodeID(scope, escapeIDComponent(id.Netns), name, port)
+	case AddressNodeIDType:
+		return MakeNetnsAddressNodeID(scope, escapeIDComponent(id.Netns), name)
+	case ProcessNodeIDType:
+		return MakeProcessNodeID(scope, name)
+	case ECSServiceNodeIDType:
+		return MakeECSServiceNodeID(scope, name)
+	}
+	if makeID, ok := singleComponentIDMakers[id.Kind]; ok {
+		return makeID(name)
+	}
+	return ""
+}
+
+// ParseTypedNodeID produces a NodeID from the string form of a node ID.
+func ParseTypedNodeID(s string) (NodeID, error) {
+	kind, ok := ClassifyNodeID(s)
+	if !ok {
+		return NodeID{}, &IDParseError{ID: s, Reason: "unrecognised node ID type"}
+	}
+	id := NodeID{Kind: kind}
+	switch kind {
+	case EndpointNodeIDType:
+		id.Scope, id.Name, id.Port, _ = ParseEndpointNodeID(s)
+	case AddressNodeIDType:
+		id.Scope, id.Name, _ = ParseAddressNodeID(s)
+	case ProcessNodeIDType:
+		if strings.Count(s, ScopeDelim) != 1 {
+			return NodeID{}, &IDParseError{ID: s, Reason: "process node IDs with start times are not supported"}
+		}
+		id.Scope, id.Name, _ = ParseProcessNodeID(s)
+	case ECSServiceNodeIDType:
+		id.Scope, id.Name, _ = ParseECSServiceNodeID(s)
+	default:
+		if _, ok := singleComponentIDMakers[kind]; !ok {
+			return NodeID{}, &IDParseError{ID: s, Reason: fmt.Sprintf("%s node IDs are not supported", kind)}
+		}
+		id.Name, _, _ = ParseNodeID(s)
+	}
+	if kind == EndpointNodeIDType || kind == AddressNodeIDType {
+		id.Scope, id.Netns = splitNetnsScope(id.Scope, id.Name)
+	}
+	id.Scope, id.Netns = unescapeIDComponent(id.Scope), unescapeIDComponent(id.Netns)
+	id.Name, id.Port = unescapeIDComponent(id.Name), unescapeIDComponent(id.Port)
+	return id, nil
+}
+
+// splitNetnsScope separates the network namespace from the scope of an
+// address or endpoint node ID, which makeAddressID only adds for loopback
+// addresses which aren't in LocalNetworks.
+func splitNetnsScope(scope, address string) (hostID, netns string) {
+	ip := parseZonedIP(address)
+	if ip == nil || !ip.IsLoopback() || LocalNetworks.Contains(ip) {
+		return scope, ""
+	}
+	pos := strings.LastIndex(scope, "-")
+	if pos <= 0 || !isNetnsID(scope[pos+1:]) {
+		return scope, ""
+	}
+	return scope[:pos], scope[pos+1:]
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (id NodeID) MarshalText() ([]byte, error) {
+	s := id.String()
+	if s == "" {
+		return nil, fmt.Errorf("cannot marshal %s node ID", id.Kind)
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (id *NodeID) UnmarshalText(text []byte) error {
+	parsed, err := ParseTypedNodeID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// idComponentEscaper percent-encodes the delimiters in node ID components.
+// "%" itself is left alone, so that IDs without delimiters in their fields
+// come out as the Make*NodeID functions make them; the price is that a
+// component which already contains "%3B" or "%7C" doesn't survive a round
+// trip.
+var (
+	idComponentEscaper   = strings.NewReplacer(ScopeDelim, "%3B", EdgeDelim, "%7C")
+	idComponentUnescaper = strings.NewReplacer("%3B", ScopeDelim, "%7C", EdgeDelim)
+)
+
+func escapeIDComponent(s string) string {
+	return idComponentEscaper.Replace(s)
+}
+
+func unescapeIDComponent(s string) string {
+	return idComponentUnescaper.Replace(s)
+}
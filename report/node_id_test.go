@@ -0,0 +1,125 @@
+package report_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestNodeID(t *testing.T) {
+	for want, id := range map[string]report.NodeID{
+		server80EndpointNodeID:                        {Kind: report.EndpointNodeIDType, Name: serverAddress, Port: "80"},
+		report.MakeAddressNodeID(clientHostID, "::1"): {Kind: report.AddressNodeIDType, Scope: clientHostID, Name: "::1"},
+		report.MakeProcessNodeID(clientHostID, "42"):  {Kind: report.ProcessNodeIDType, Scope: clientHostID, Name: "42"},
+		report.MakeECSServiceNodeID("cluster", "svc"): {Kind: report.ECSServiceNodeIDType, Scope: "cluster", Name: "svc"},
+		clientHostNodeID:                              {Kind: report.HostNodeIDType, Name: clientHostID},
+		report.MakePodNodeID("uid"):                   {Kind: report.PodNodeIDType, Name: "uid"},
+	} {
+		if have := id.String(); have != want {
+			t.Errorf("%+v: want %q, have %q", id, want, have)
+		}
+		parsed, err := report.ParseTypedNodeID(want)
+		if err != nil {
+			t.Errorf("%q: %v", want, err)
+		} else if !reflect.DeepEqual(id, parsed) {
+			t.Errorf("%q: want %+v, have %+v", want, id, parsed)
+		}
+	}
+
+	for _, bad := range []string{
+		"abc;<future_type>",
+		report.MakeProcessNodeIDWithStart(clientHostID, "42", "123"),
+		report.MakeOverlayNodeID("", "peer"),
+	} {
+		if _, err := report.ParseTypedNodeID(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestNodeIDEscaping(t *testing.T) {
+	id := report.NodeID{Kind: report.ContainerImageNodeIDType, Name: "weird;image|name"}
+	s := id.String()
+	if typ, ok := report.ClassifyNodeID(s); !ok || typ != report.ContainerImageNodeIDType {
+		t.Fatalf("%q: classified as %q (%v)", s, typ, ok)
+	}
+	parsed, err := report.ParseTypedNodeID(s)
+	if err != nil || parsed != id {
+		t.Errorf("%q: parsed as %+v (%v)", s, parsed, err)
+	}
+
+	for _, id := range []report.NodeID{
+		{Kind: report.EndpointNodeIDType, Name: "fe80::1%eth0", Port: "80"},
+		{Kind: report.EndpointNodeIDType, Scope: clientHostID, Name: "::1%lo", Port: "80"},
+		{Kind: report.ContainerImageNodeIDType, Name: "percent%25sign"},
+	} {
+		parsed, err := report.ParseTypedNodeID(id.String())
+		if err != nil || parsed != id {
+			t.Errorf("%q: parsed as %+v (%v)", id.String(), parsed, err)
+		}
+	}
+
+	// Zones are left as written, as the Make*NodeID functions do
+	zoned := report.NodeID{Kind: report.AddressNodeIDType, Name: "fe80::1%eth0"}
+	if want, have := report.MakeAddressNodeID("", "fe80::1%eth0"), zoned.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	zoned = report.NodeID{Kind: report.EndpointNodeIDType, Scope: clientHostID, Name: "::1%lo", Port: "80"}
+	if want, have := report.MakeEndpointNodeID(clientHostID, "", "::1%lo", "80"), zoned.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestNodeIDScoping(t *testing.T) {
+	for want, id := range map[string]report.NodeID{
+		report.MakeAddressNodeID(clientHostID, "8.8.8.8"):                        {Kind: report.AddressNodeIDType, Scope: clientHostID, Name: "8.8.8.8"},
+		report.MakeAddressNodeID(clientHostID, "127.0.0.1"):                      {Kind: report.AddressNodeIDType, Scope: clientHostID, Name: "127.0.0.1"},
+		report.MakeNetnsAddressNodeID(clientHostID, "4026531840", "127.0.0.1"):   {Kind: report.AddressNodeIDType, Scope: clientHostID, Netns: "4026531840", Name: "127.0.0.1"},
+		report.MakeEndpointNodeID(clientHostID, "", "8.8.8.8", "53"):             {Kind: report.EndpointNodeIDType, Scope: clientHostID, Name: "8.8.8.8", Port: "53"},
+		report.MakeEndpointNodeID(clientHostID, "4026531840", "127.0.0.1", "80"): {Kind: report.EndpointNodeIDType, Scope: clientHostID, Netns: "4026531840", Name: "127.0.0.1", Port: "80"},
+		report.MakeContainerScopedEndpointNodeID("abc", "10.0.0.1", "80"):        {Kind: report.EndpointNodeIDType, Scope: "<container>abc", Name: "10.0.0.1", Port: "80"},
+		report.MakeAddressNodeID("ip-10-0-0-1", "127.0.0.1"):                     {Kind: report.AddressNodeIDType, Scope: "ip-10-0-0-1", Name: "127.0.0.1"},
+	} {
+		if have := id.String(); have != want {
+			t.Errorf("%+v: want %q, have %q", id, want, have)
+		}
+		// Public addresses parse without the scope they were made with
+		parsed, err := report.ParseTypedNodeID(want)
+		if err != nil {
+			t.Errorf("%q: %v", want, err)
+		} else if have := parsed.String(); have != want || parsed.Netns != id.Netns {
+			t.Errorf("%q: parsed as %+v, which is %q", want, parsed, have)
+		}
+	}
+}
+
+func TestNodeIDJSON(t *testing.T) {
+	in := []report.NodeID{
+		{Kind: report.EndpointNodeIDType, Name: serverAddress, Port: "80"},
+		{Kind: report.HostNodeIDType, Name: clientHostID},
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var strs []string
+	if err := json.Unmarshal(b, &strs); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{server80EndpointNodeID, clientHostNodeID}; !reflect.DeepEqual(want, strs) {
+		t.Errorf("want %v, have %v", want, strs)
+	}
+	var out []report.NodeID
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("want %+v, have %+v", in, out)
+	}
+
+	if _, err := json.Marshal(report.NodeID{Kind: report.OverlayNodeIDType}); err == nil {
+		t.Errorf("expected error marshalling unsupported node ID")
+	}
+}
@@ -149,9 +149,14 @@ func (n NodeSet) DeepEqual(o NodeSet) bool {
 
 func (n NodeSet) toIntermediate() []Node {
 	intermediate := make([]Node, 0, n.Size())
-	n.ForEach(func(node Node) {
-		intermediate = append(intermediate, node)
-	})
+	if n.psMap == nil {
+		return intermediate
+	}
+	// Encode in a deterministic order, so identical sets encode identically
+	for _, id := range SortedNodeIDs(n.psMap.Keys()) {
+		node, _ := n.psMap.Lookup(id)
+		intermediate = append(intermediate, node.(Node))
+	}
 	return intermediate
 }
 
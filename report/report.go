@@ -359,8 +359,9 @@ func (r *Report) UnsafeMerge(other Report) {
 // UnsafeUnMerge removes any information from r that would be added by merging other.
 // The original is modified.
 func (r *Report) UnsafeUnMerge(other Report) {
-	// TODO: DNS, Sampling, Plugins
+	// TODO: Sampling, Plugins
 	r.Window = r.Window - other.Window
+	r.DNS.UnsafeUnMerge(other.DNS)
 	r.WalkPairedTopologies(&other, func(ourTopology, theirTopology *Topology) {
 		ourTopology.UnsafeUnMerge(*theirTopology)
 	})
@@ -475,6 +476,57 @@ func (r Report) Topology(name string) (Topology, bool) {
 	return Topology{}, false
 }
 
+// clusterScopedNodeIDTypes are the Kubernetes node ID types whose resources
+// belong to no namespace.
+var clusterScopedNodeIDTypes = map[NodeIDType]bool{
+	NamespaceNodeIDType:          true,
+	PersistentVolumeNodeIDType:   true,
+	StorageClassNodeIDType:       true,
+	VolumeSnapshotDataNodeIDType: true,
+}
+
+// namespacedNodeIDTypes are the Kubernetes node ID types whose resources
+// belong to a namespace.
+var namespacedNodeIDTypes = map[NodeIDType]bool{
+	PodNodeIDType:                   true,
+	ServiceNodeIDType:               true,
+	DeploymentNodeIDType:            true,
+	ReplicaSetNodeIDType:            true,
+	DaemonSetNodeIDType:             true,
+	StatefulSetNodeIDType:           true,
+	CronJobNodeIDType:               true,
+	JobNodeIDType:                   true,
+	PersistentVolumeClaimNodeIDType: true,
+	VolumeSnapshotNodeIDType:        true,
+}
+
+// K8sNamespace returns the namespace of the Kubernetes resource with the
+// given node ID. Kubernetes node IDs are built from UIDs, so the namespace
+// is looked up on the node in the report. Cluster-scoped resources have an
+// empty namespace. It returns false for non-Kubernetes node IDs and for
+// namespaced resources not in the report.
+func (r Report) K8sNamespace(id string) (string, bool) {
+	typ, ok := ClassifyNodeID(id)
+	if !ok {
+		return "", false
+	}
+	if clusterScopedNodeIDTypes[typ] {
+		return "", true
+	}
+	if !namespacedNodeIDTypes[typ] {
+		return "", false
+	}
+	topology := r.topology(nodeIDTopologies[typ])
+	if topology == nil {
+		return "", false
+	}
+	node, ok := topology.Nodes[id]
+	if !ok {
+		return "", false
+	}
+	return node.Latest.Lookup(KubernetesNamespace)
+}
+
 // Validate checks the report for various inconsistencies.
 func (r Report) Validate() error {
 	var errs []string
@@ -97,6 +97,34 @@ func TestReportUpgrade(t *testing.T) {
 	}
 }
 
+func TestReportK8sNamespace(t *testing.T) {
+	rpt := report.MakeReport()
+	rpt.Pod.AddNode(report.MakeNodeWith(report.MakePodNodeID("pod"), map[string]string{report.KubernetesNamespace: "ns"}))
+	rpt.Service.AddNode(report.MakeNodeWith(report.MakeServiceNodeID("svc"), map[string]string{report.KubernetesNamespace: "ns"}))
+	rpt.Deployment.AddNode(report.MakeNodeWith(report.MakeDeploymentNodeID("dep"), map[string]string{report.KubernetesNamespace: "other"}))
+	rpt.PersistentVolumeClaim.AddNode(report.MakeNodeWith(report.MakePersistentVolumeClaimNodeID("pvc"), map[string]string{report.KubernetesNamespace: "ns"}))
+
+	for id, want := range map[string]struct {
+		namespace string
+		ok        bool
+	}{
+		report.MakePodNodeID("pod"):                   {"ns", true},
+		report.MakeServiceNodeID("svc"):               {"ns", true},
+		report.MakeDeploymentNodeID("dep"):            {"other", true},
+		report.MakePersistentVolumeClaimNodeID("pvc"): {"ns", true},
+		report.MakePersistentVolumeNodeID("pv"):       {"", true},
+		report.MakeStorageClassNodeID("standard"):     {"", true},
+		report.MakeNamespaceNodeID("ns"):              {"", true},
+		report.MakePodNodeID("missing"):               {"", false},
+		report.MakeContainerNodeID("abc"):             {"", false},
+		report.MakeHostNodeID("host"):                 {"", false},
+	} {
+		if namespace, ok := rpt.K8sNamespace(id); namespace != want.namespace || ok != want.ok {
+			t.Errorf("%q: want {%q, %v}, have {%q, %v}", id, want.namespace, want.ok, namespace, ok)
+		}
+	}
+}
+
 func TestReportUnMerge(t *testing.T) {
 	n1 := report.MakeNodeWith("foo", map[string]string{"foo": "bar"})
 	r1 := makeTestReport()
@@ -116,10 +144,15 @@ func TestReportUnMerge(t *testing.T) {
 
 	// Now test report with two nodes unmerged on report with one
 	r1.Container.AddNode(n1)
+	r1.DNS = report.DNSRecords{"10.0.0.1": {Forward: report.MakeStringSet("a.example.com")}}
 	r2 = r1.Copy()
 	n2 := report.MakeNodeWith("foo2", map[string]string{"ping": "pong"})
 	r2.Container.AddNode(n2)
-	// r2 should be the same as r1 with one extra node
+	r2.DNS = report.DNSRecords{
+		"10.0.0.1": {Forward: report.MakeStringSet("a.example.com")},
+		"10.0.0.2": {Forward: report.MakeStringSet("b.example.com")},
+	}
+	// r2 should be the same as r1 with one extra node and DNS record
 	r2.UnsafeUnMerge(r1)
 	expected = report.Report{
 		ID: r2.ID,
@@ -128,6 +161,9 @@ func TestReportUnMerge(t *testing.T) {
 				"foo2": n2,
 			},
 		},
+		DNS: report.DNSRecords{
+			"10.0.0.2": {Forward: report.MakeStringSet("b.example.com")},
+		},
 	}
 
 	if !s_reflect.DeepEqual(expected, r2) {